@@ -0,0 +1,114 @@
+package exifremovethumbnail
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+// Report is a size breakdown of a JPEG file produced by Analyze.
+type Report struct {
+	// Size is the total file size.
+	Size int64
+	// Segments lists the marker segments before the image data in file order.
+	Segments []SegmentSize
+	// ThumbnailSize is the size of the EXIF thumbnail. It is part of the APP1-Exif segment size.
+	ThumbnailSize int64
+	// ImageDataSize covers the SOS marker through the EOI marker.
+	ImageDataSize int64
+	// TrailerSize counts the bytes after EOI.
+	TrailerSize int64
+}
+
+// SegmentSize describes a single marker segment.
+type SegmentSize struct {
+	// Name is the segment name as used by WithKeepSegments, e.g. "APP1-Exif",
+	// or the marker name such as "DQT", "SOF0" or "COM" for other segments.
+	Name Segment
+	// Offset is the position of the marker in the file.
+	Offset int64
+	// Size includes the marker and the length field.
+	Size int64
+}
+
+// Analyze reports where the bytes of a JPEG file go without modifying anything.
+func Analyze(data []byte) (report Report, err error) {
+	report = Report{Size: int64(len(data))}
+	pos := 2
+	defer recoverPanic(&err, func() int64 { return int64(pos) })
+	if !isJPEG(data) {
+		return report, &FormatError{Code: CodeNotJPEG, Offset: 0, msg: "not a valid JPEG file", err: ErrNotJPEG}
+	}
+	report.Segments = append(report.Segments, SegmentSize{Name: "SOI", Offset: 0, Size: 2})
+
+	const markerAPP1 = spec.APP1
+	const markerSOS = spec.SOS
+
+	for pos < len(data) {
+		// Skip 0xFF fill bytes before the marker.
+		for pos+1 < len(data) && data[pos] == 0xFF && data[pos+1] == 0xFF {
+			pos++
+		}
+		if len(data) < pos+2 {
+			return report, &FormatError{Code: CodeTruncated, Offset: int64(pos), msg: "truncated JPEG marker", err: ErrTruncated}
+		}
+		marker := binary.BigEndian.Uint16(data[pos:])
+		if marker&0xFF00 != 0xFF00 {
+			return report, &FormatError{Code: CodeInvalidMarker, Offset: int64(pos), msg: "invalid JPEG marker"}
+		}
+		if marker == markerSOS {
+			eoi := &eoiTracker{end: -1}
+			eoi.Write(data[pos:])
+			report.ImageDataSize = eoi.n
+			if eoi.end >= 0 {
+				report.ImageDataSize = eoi.end
+				report.TrailerSize = eoi.n - eoi.end
+			}
+			break
+		}
+		if len(data) < pos+4 {
+			return report, &SegmentError{Marker: marker, Offset: int64(pos), Length: -1, Reason: "missing length field"}
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 {
+			return report, &SegmentError{Marker: marker, Offset: int64(pos), Length: length, Reason: fmt.Sprintf("length %d is less than 2", length)}
+		}
+		end := pos + 2 + length
+		if end > len(data) {
+			return report, &SegmentError{Marker: marker, Offset: int64(pos), Length: length, Reason: fmt.Sprintf("length %d overruns the data", length)}
+		}
+		segmentData := data[pos+4 : end]
+		report.Segments = append(report.Segments, SegmentSize{
+			Name:   markerName(marker, segmentData),
+			Offset: int64(pos),
+			Size:   int64(end - pos),
+		})
+		if marker == markerAPP1 && isExifSegment(segmentData) && report.ThumbnailSize == 0 {
+			// removeThumbnailFromExif works in place, so inspect a copy.
+			exifData := append([]byte{}, segmentData...)
+			if _, exifRes, err := removeThumbnailFromExif(exifData, &options{}); err == nil {
+				report.ThumbnailSize = exifRes.thumbnailSize
+			}
+		}
+		pos = end
+	}
+	return report, nil
+}
+
+// markerNames names the non-application markers commonly found before SOS.
+var markerNames = map[uint16]Segment{
+	0xFFC0: "SOF0", 0xFFC1: "SOF1", 0xFFC2: "SOF2", 0xFFC3: "SOF3",
+	0xFFC4: "DHT", 0xFFCC: "DAC", 0xFFDB: "DQT", 0xFFDD: "DRI", 0xFFFE: "COM",
+}
+
+// markerName returns the name of any segment marker.
+func markerName(marker uint16, segmentData []byte) Segment {
+	if isAPPn(marker) {
+		return segmentName(marker, segmentData)
+	}
+	if name, ok := markerNames[marker]; ok {
+		return name
+	}
+	return Segment(fmt.Sprintf("0x%04X", marker))
+}
@@ -0,0 +1,81 @@
+package exifremovethumbnail_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestAnalyze(t *testing.T) {
+	// sumReport はセグメント・画像データ・トレーラーの合計を返す
+	sumReport := func(r exifremovethumbnail.Report) int64 {
+		total := r.ImageDataSize + r.TrailerSize
+		for _, s := range r.Segments {
+			total += s.Size
+		}
+		return total
+	}
+
+	t.Run("サムネイル付きJPEGの内訳", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		original := append([]byte{}, inData...)
+
+		report, err := exifremovethumbnail.Analyze(inData)
+		require.NoError(t, err)
+		require.Equal(t, original, inData, "入力は変更されないべき")
+		require.Equal(t, int64(len(inData)), report.Size)
+		require.Equal(t, report.Size, sumReport(report), "内訳の合計はファイルサイズと一致するべき")
+		require.Equal(t, int64(0), report.TrailerSize)
+
+		var exif *exifremovethumbnail.SegmentSize
+		for i, s := range report.Segments {
+			if s.Name == exifremovethumbnail.SegmentExif {
+				exif = &report.Segments[i]
+			}
+		}
+		require.NotNil(t, exif)
+		require.Equal(t, int64(8587), exif.Size)
+
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, result.ThumbnailSize, report.ThumbnailSize)
+	})
+
+	t.Run("マーカーの名前", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "metadata_none.jpg"))
+		require.NoError(t, err)
+		report, err := exifremovethumbnail.Analyze(inData)
+		require.NoError(t, err)
+		var names []exifremovethumbnail.Segment
+		for _, s := range report.Segments {
+			names = append(names, s.Name)
+		}
+		require.Equal(t, exifremovethumbnail.Segment("SOI"), names[0])
+		require.Contains(t, names, exifremovethumbnail.SegmentJFIF)
+		require.Contains(t, names, exifremovethumbnail.Segment("DQT"))
+		require.Equal(t, int64(0), report.ThumbnailSize)
+	})
+
+	t.Run("EOI後のトレーラー", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "metadata_none.jpg"))
+		require.NoError(t, err)
+		inData = append(inData, []byte("TRAILER")...)
+		report, err := exifremovethumbnail.Analyze(inData)
+		require.NoError(t, err)
+		require.Equal(t, int64(7), report.TrailerSize)
+		require.Equal(t, report.Size, sumReport(report))
+	})
+
+	t.Run("JPEG以外はFormatError", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "actual_png.jpg"))
+		require.NoError(t, err)
+		_, err = exifremovethumbnail.Analyze(inData)
+		_, ok := err.(*exifremovethumbnail.FormatError)
+		require.True(t, ok, "FormatErrorであるべき")
+	})
+}
@@ -0,0 +1,112 @@
+package exifremovethumbnail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// modulePath is the import path of this package, used to find its version.
+const modulePath = "github.com/ideamans/go-exif-remove-thumbnail"
+
+// Attestation records how an image was processed. Persisted next to the image,
+// as JSON for example, it allows to prove later that the file was only
+// metadata-modified by this package: the image data hashes are equal and the
+// file hashes tie the record to the original and the processed file.
+type Attestation struct {
+	// Tool is the module path and ToolVersion its version, "(devel)" when unknown.
+	Tool        string `json:"tool"`
+	ToolVersion string `json:"tool_version"`
+	// Options describes the behavior selected by the options used.
+	Options Behavior `json:"options"`
+	// InputSHA256 and OutputSHA256 are hex SHA-256 digests of the whole files.
+	InputSHA256  string `json:"input_sha256"`
+	OutputSHA256 string `json:"output_sha256"`
+	// InputScanHash and OutputScanHash are the digests of the SOS..EOI regions.
+	InputScanHash  string `json:"input_scan_hash"`
+	OutputScanHash string `json:"output_scan_hash"`
+	// ImageDataUnchanged is true when the scan hashes are equal.
+	ImageDataUnchanged bool `json:"image_data_unchanged"`
+	// ThumbnailRemoved and ThumbnailSize describe the removed thumbnail.
+	ThumbnailRemoved bool  `json:"thumbnail_removed"`
+	ThumbnailSize    int64 `json:"thumbnail_size"`
+	// RemovedTags lists the removed EXIF tags as TagRef.String returns them.
+	RemovedTags []string `json:"removed_tags"`
+	// RemovedSegments maps the names of removed segments to their byte counts.
+	RemovedSegments map[Segment]int64 `json:"removed_segments"`
+}
+
+// ErrAttestationMismatch is returned by Attestation.Verify when the data does
+// not match the record.
+var ErrAttestationMismatch = errors.New("attestation does not match")
+
+// NewAttestation records the processing of input into output, as returned by
+// ExifRemoveThumbnailBytes with result and opts. The scan hashes are computed
+// from the data rather than taken from result.
+func NewAttestation(input, output []byte, result ExifRemoveThumbnailResult, opts ...Option) Attestation {
+	a := Attestation{
+		Tool:             modulePath,
+		ToolVersion:      moduleVersion(),
+		Options:          behaviorOf(newOptions(opts)),
+		InputSHA256:      sha256Hex(input),
+		OutputSHA256:     sha256Hex(output),
+		InputScanHash:    ScanHash(input),
+		OutputScanHash:   ScanHash(output),
+		ThumbnailRemoved: result.HadThumbnail && !newOptions(opts).keepThumbnail,
+		ThumbnailSize:    result.ThumbnailSize,
+		RemovedTags:      make([]string, 0, len(result.RemovedTags)),
+		RemovedSegments:  map[Segment]int64{},
+	}
+	a.ImageDataUnchanged = a.InputScanHash == a.OutputScanHash
+	for _, ref := range result.RemovedTags {
+		a.RemovedTags = append(a.RemovedTags, ref.String())
+	}
+	for name, n := range result.RemovedSegments {
+		a.RemovedSegments[name] = n
+	}
+	return a
+}
+
+// Verify checks that output is the file the attestation was made for and that
+// its image data is the one of the original. input may be nil when the original
+// is no longer available; otherwise it must match as well.
+func (a Attestation) Verify(input, output []byte) error {
+	if !a.ImageDataUnchanged || a.InputScanHash != a.OutputScanHash {
+		return fmt.Errorf("%w: image data was modified", ErrAttestationMismatch)
+	}
+	if sha256Hex(output) != a.OutputSHA256 {
+		return fmt.Errorf("%w: output differs", ErrAttestationMismatch)
+	}
+	if ScanHash(output) != a.OutputScanHash {
+		return fmt.Errorf("%w: output image data differs", ErrAttestationMismatch)
+	}
+	if input != nil && sha256Hex(input) != a.InputSHA256 {
+		return fmt.Errorf("%w: input differs", ErrAttestationMismatch)
+	}
+	return nil
+}
+
+// sha256Hex returns the hex SHA-256 digest of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// moduleVersion returns the version of this module in the running binary.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
@@ -0,0 +1,63 @@
+package exifremovethumbnail_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestAttestation(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	opts := []exifremovethumbnail.Option{exifremovethumbnail.WithRemoveMakerNote(), exifremovethumbnail.WithStripComments()}
+	outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, opts...)
+	require.NoError(t, err)
+	a := exifremovethumbnail.NewAttestation(inData, outData, res, opts...)
+
+	t.Run("処理内容を記録する", func(t *testing.T) {
+		require.Equal(t, "github.com/ideamans/go-exif-remove-thumbnail", a.Tool)
+		require.NotEmpty(t, a.ToolVersion)
+		require.Equal(t, exifremovethumbnail.BehaviorVersion, a.Options.Version)
+		require.False(t, a.Options.KeepMakerNote)
+		require.False(t, a.Options.KeepComments)
+		require.True(t, a.Options.KeepGPS)
+		require.True(t, a.ImageDataUnchanged)
+		require.Equal(t, res.InputScanHash, a.InputScanHash)
+		require.True(t, a.ThumbnailRemoved)
+		require.Equal(t, res.ThumbnailSize, a.ThumbnailSize)
+		require.NotEqual(t, a.InputSHA256, a.OutputSHA256)
+	})
+
+	t.Run("JSONで保存して後から検証できる", func(t *testing.T) {
+		data, err := json.Marshal(a)
+		require.NoError(t, err)
+		var loaded exifremovethumbnail.Attestation
+		require.NoError(t, json.Unmarshal(data, &loaded))
+		require.Equal(t, a, loaded)
+		require.NoError(t, loaded.Verify(inData, outData))
+		require.NoError(t, loaded.Verify(nil, outData), "原本がなくても検証できる")
+	})
+
+	t.Run("別のファイルは検証に失敗する", func(t *testing.T) {
+		tampered := append([]byte{}, outData...)
+		tampered[len(tampered)-10] ^= 0xFF
+		require.ErrorIs(t, a.Verify(inData, tampered), exifremovethumbnail.ErrAttestationMismatch)
+		require.ErrorIs(t, a.Verify(outData, outData), exifremovethumbnail.ErrAttestationMismatch)
+	})
+
+	t.Run("画像データが変わっていれば記録に残る", func(t *testing.T) {
+		// WithConformance が補ったEOIは画像データの変更になる
+		truncated := inData[:len(inData)-2]
+		opts := []exifremovethumbnail.Option{exifremovethumbnail.WithConformance()}
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(truncated, opts...)
+		require.NoError(t, err)
+		a := exifremovethumbnail.NewAttestation(truncated, outData, res, opts...)
+		require.False(t, a.ImageDataUnchanged)
+		require.ErrorIs(t, a.Verify(truncated, outData), exifremovethumbnail.ErrAttestationMismatch)
+	})
+}
@@ -0,0 +1,102 @@
+package exifremovethumbnail
+
+import "fmt"
+
+// ExifBackend rewrites the TIFF structure of EXIF segments: it removes the
+// IFD1 thumbnail and the tags asked for. WithExifBackend plugs one in, so
+// pipelines already built on a full EXIF library, such as an adapter over
+// dsoprea/go-exif, parse EXIF data the same way everywhere. The JPEG segments
+// around the EXIF data are still handled by this package.
+type ExifBackend interface {
+	RewriteExif(tiffData []byte, edit ExifEdit) (ExifRewrite, error)
+}
+
+// ExifBackendFunc adapts a function to the ExifBackend interface.
+type ExifBackendFunc func(tiffData []byte, edit ExifEdit) (ExifRewrite, error)
+
+// RewriteExif calls f(tiffData, edit).
+func (f ExifBackendFunc) RewriteExif(tiffData []byte, edit ExifEdit) (ExifRewrite, error) {
+	return f(tiffData, edit)
+}
+
+// ExifEdit is the change an ExifBackend is asked to make.
+type ExifEdit struct {
+	RemoveThumbnail bool
+	// RemoveGPS asks for the GPS IFD and its pointer in IFD0 to be removed.
+	RemoveGPS bool
+	// RemoveTags lists the tags to remove, by the IFD they are in.
+	RemoveTags []TagRef
+}
+
+// ExifRewrite is the result of an ExifBackend.
+type ExifRewrite struct {
+	// Data is the rewritten TIFF data. It may share the memory of the input.
+	Data []byte
+	// HadThumbnail reports whether IFD1 was present, ThumbnailSize how many
+	// bytes its removal freed.
+	HadThumbnail  bool
+	ThumbnailSize int64
+	// RemovedTags lists the tags removed; the GPS IFD is reported as its
+	// pointer in IFD0.
+	RemovedTags []TagRef
+	// Warnings are anomalies found in the data.
+	Warnings []string
+}
+
+// DefaultExifBackend is the built-in backend used without WithExifBackend.
+// Other backends can fall back to it.
+var DefaultExifBackend ExifBackend = builtinExifBackend{}
+
+type builtinExifBackend struct{}
+
+func (builtinExifBackend) RewriteExif(tiffData []byte, edit ExifEdit) (ExifRewrite, error) {
+	o := &options{keepThumbnail: !edit.RemoveThumbnail, removeGPS: edit.RemoveGPS, removeTags: edit.RemoveTags}
+	out, res, err := removeThumbnailFromExif(append([]byte(exifHeader), tiffData...), o)
+	if err != nil {
+		return ExifRewrite{}, err
+	}
+	return ExifRewrite{
+		Data:          out[len(exifHeader):],
+		HadThumbnail:  res.hadThumbnail,
+		ThumbnailSize: res.thumbnailSize,
+		RemovedTags:   res.removedTags,
+		Warnings:      append(res.warnings, res.notes...),
+	}, nil
+}
+
+// WithExifBackend rewrites EXIF segments of JPEG files with b instead of the
+// built-in code. The thumbnail details and the EXIF summary of the result
+// are still read by this package. A backend shared through options used
+// concurrently must be safe for concurrent use.
+func WithExifBackend(b ExifBackend) Option {
+	return func(o *options) {
+		o.exifBackend = b
+	}
+}
+
+// rewriteExifWithBackend is removeThumbnailFromExif through the backend set
+// by WithExifBackend.
+func rewriteExifWithBackend(exifData []byte, o *options) ([]byte, exifResult, error) {
+	res := exifResult{thumbnail: thumbnailInfo{offset: -1}}
+	if !isExifSegment(exifData) {
+		return exifData, res, fmt.Errorf("invalid EXIF header: %w", ErrNoExif)
+	}
+	// Inspect a copy only to report the thumbnail and the summary.
+	if _, inspected, err := removeThumbnailFromExif(append([]byte{}, exifData...), &options{keepThumbnail: true}); err == nil {
+		res.summary = inspected.summary
+		res.thumbnail = inspected.thumbnail
+	}
+	rewrite, err := o.exifBackend.RewriteExif(exifData[len(exifHeader):], ExifEdit{
+		RemoveThumbnail: !o.keepThumbnail,
+		RemoveGPS:       o.removeGPS,
+		RemoveTags:      o.removeTags,
+	})
+	if err != nil {
+		return exifData, res, err
+	}
+	res.hadThumbnail = rewrite.HadThumbnail
+	res.thumbnailSize = rewrite.ThumbnailSize
+	res.removedTags = rewrite.RemovedTags
+	res.warnings = rewrite.Warnings
+	return compactExif(append([]byte(exifHeader), rewrite.Data...), o), res, nil
+}
@@ -0,0 +1,89 @@
+package exifremovethumbnail_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+	"github.com/ideamans/go-exif-remove-thumbnail/tiff"
+)
+
+func TestExifBackend(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+
+	t.Run("組み込みのバックエンドは指定しないときと同じ", func(t *testing.T) {
+		opts := []exifremovethumbnail.Option{exifremovethumbnail.WithRemoveGPS(), exifremovethumbnail.WithRemoveOwnerInfo()}
+		want, wantRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, opts...)
+		require.NoError(t, err)
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, append(opts, exifremovethumbnail.WithExifBackend(exifremovethumbnail.DefaultExifBackend))...)
+		require.NoError(t, err)
+		require.Equal(t, want, out)
+		require.Equal(t, wantRes.ThumbnailSize, res.ThumbnailSize)
+		require.Equal(t, wantRes.ThumbnailWidth, res.ThumbnailWidth)
+		require.Equal(t, wantRes.RemovedTags, res.RemovedTags)
+		require.Equal(t, wantRes.Exif, res.Exif)
+	})
+
+	t.Run("独自のバックエンドで書き換える", func(t *testing.T) {
+		var edits []exifremovethumbnail.ExifEdit
+		// IFD1へのポインタだけを消す素朴なバックエンド
+		backend := exifremovethumbnail.ExifBackendFunc(func(tiffData []byte, edit exifremovethumbnail.ExifEdit) (exifremovethumbnail.ExifRewrite, error) {
+			edits = append(edits, edit)
+			b, err := tiff.Parse(append([]byte{}, tiffData...))
+			if err != nil {
+				return exifremovethumbnail.ExifRewrite{}, err
+			}
+			ifd0, err := b.ReadIFD(b.IFD0Offset())
+			if err != nil {
+				return exifremovethumbnail.ExifRewrite{}, err
+			}
+			rewrite := exifremovethumbnail.ExifRewrite{Data: b.Data, HadThumbnail: ifd0.Next != 0}
+			if edit.RemoveThumbnail && ifd0.Next != 0 {
+				b.Order.PutUint32(b.Data[ifd0.NextPos():], 0)
+				rewrite.Warnings = append(rewrite.Warnings, "thumbnail unlinked")
+			}
+			return rewrite, nil
+		})
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithExifBackend(backend),
+			exifremovethumbnail.WithRemoveGPS(),
+			exifremovethumbnail.WithRemoveTags(exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFD0, ID: spec.TagArtist}))
+		require.NoError(t, err)
+		require.Equal(t, []exifremovethumbnail.ExifEdit{{
+			RemoveThumbnail: true,
+			RemoveGPS:       true,
+			RemoveTags:      []exifremovethumbnail.TagRef{{IFD: exifremovethumbnail.IFD0, ID: spec.TagArtist}},
+		}}, edits)
+		require.Len(t, out, len(inData))
+		blocks := exifremovethumbnail.FindExifBlocks(out)
+		require.Len(t, blocks, 1)
+		require.False(t, blocks[0].HasThumbnail)
+		require.True(t, res.HadThumbnail)
+		require.Contains(t, res.Warnings, "thumbnail unlinked")
+		// サムネイルの詳細とEXIFの概要はこのパッケージが読む
+		_, wantRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.NotZero(t, res.ThumbnailWidth)
+		require.Equal(t, wantRes.ThumbnailWidth, res.ThumbnailWidth)
+		require.Equal(t, wantRes.Exif, res.Exif)
+		require.Equal(t, exifremovethumbnail.ScanHash(inData), exifremovethumbnail.ScanHash(out))
+	})
+
+	t.Run("バックエンドのエラー", func(t *testing.T) {
+		errBackend := errors.New("backend failed")
+		backend := exifremovethumbnail.ExifBackendFunc(func([]byte, exifremovethumbnail.ExifEdit) (exifremovethumbnail.ExifRewrite, error) {
+			return exifremovethumbnail.ExifRewrite{}, errBackend
+		})
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithExifBackend(backend))
+		require.ErrorIs(t, err, errBackend)
+		var formatErr *exifremovethumbnail.FormatError
+		require.ErrorAs(t, err, &formatErr)
+		require.Equal(t, exifremovethumbnail.CodeInvalidExif, formatErr.Code)
+	})
+}
@@ -0,0 +1,344 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"math/rand"
+	pathpkg "path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Offender describes the removable payload of one file found by TopOffenders.
+type Offender struct {
+	Path string
+	Size int64
+	// Format is FormatJPEG or the name of the registered handler for the file.
+	Format string
+	// Savings is what ExifRemoveThumbnail with the given options would reclaim.
+	Savings int64
+	// ThumbnailSize is the size of the EXIF thumbnail, part of Savings unless it is kept.
+	ThumbnailSize int64
+	// TrailerSize counts the bytes after EOI, which are only removed with WithStripTrailer.
+	TrailerSize int64
+	// Removable is Savings plus TrailerSize, unless Savings already includes it.
+	Removable int64
+}
+
+// TopOffenders walks fsys and returns the n files with the largest removable
+// payload, largest first. Files in formats that are neither JPEG nor handled by a
+// registered FormatHandler are skipped. n <= 0 returns all files.
+// WithModifiedAfter, WithMinFileSize and the related options restrict the walk.
+func TopOffenders(fsys fs.FS, n int, opts ...Option) ([]Offender, error) {
+	var offenders []Offender
+	o := newOptions(opts)
+	err := walkFiles(fsys, o, nil, o.skip, func(path string) error {
+		offender, reason, err := inspectFile(fsys, path, opts)
+		if reason != "" {
+			o.skip(path, reason)
+		} else if err == nil {
+			offenders = append(offenders, offender)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Removable != offenders[j].Removable {
+			return offenders[i].Removable > offenders[j].Removable
+		}
+		return offenders[i].Path < offenders[j].Path
+	})
+	if n > 0 && len(offenders) > n {
+		offenders = offenders[:n]
+	}
+	return offenders, nil
+}
+
+// walkFiles calls fn for every regular file in fsys that passes the filters set
+// by WithExtensions, WithIncludePath, WithExcludePath, WithModifiedAfter,
+// WithModifiedBefore, WithNewerThan, WithOlderThan, WithMinFileSize and
+// WithMaxFileSize, and skip for every file they leave out. Relative filters
+// are resolved once, when the walk starts. Files rejected by match, when it
+// is not nil, are ignored before any filter. With WithLargestFirst the files
+// are collected first and visited largest first.
+func walkFiles(fsys fs.FS, o *options, match func(path string) bool, skip func(path string, reason SkipReason), fn func(path string) error) error {
+	after, before := o.modifiedAfter, o.modifiedBefore
+	now := time.Now()
+	if o.newerThan > 0 {
+		if t := now.Add(-o.newerThan); t.After(after) {
+			after = t
+		}
+	}
+	if o.olderThan > 0 {
+		if t := now.Add(-o.olderThan); before.IsZero() || t.Before(before) {
+			before = t
+		}
+	}
+	needInfo := !after.IsZero() || !before.IsZero() || o.minFileSize > 0 || o.maxFileSize > 0 || o.largestFirst
+	type file struct {
+		path string
+		size int64
+	}
+	var files []file
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if match != nil && !match(path) {
+			return nil
+		}
+		if !o.pathIncluded(path) {
+			skip(path, SkipExcluded)
+			return nil
+		}
+		if !needInfo {
+			return fn(path)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		mtime := info.ModTime()
+		if !after.IsZero() && !mtime.After(after) || !before.IsZero() && !mtime.Before(before) {
+			skip(path, SkipModifiedTime)
+			return nil
+		}
+		if info.Size() < o.minFileSize {
+			skip(path, SkipTooSmall)
+			return nil
+		}
+		if o.maxFileSize > 0 && info.Size() > o.maxFileSize {
+			skip(path, SkipTooLarge)
+			return nil
+		}
+		if o.largestFirst {
+			files = append(files, file{path, info.Size()})
+			return nil
+		}
+		return fn(path)
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].size != files[j].size {
+			return files[i].size > files[j].size
+		}
+		return files[i].path < files[j].path
+	})
+	for _, f := range files {
+		if err := fn(f.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pathIncluded reports whether path passes the filters set by WithExtensions,
+// WithIncludePath and WithExcludePath.
+func (o *options) pathIncluded(path string) bool {
+	for _, re := range o.excludePaths {
+		if re.MatchString(path) {
+			return false
+		}
+	}
+	if len(o.extensions) > 0 {
+		ext := strings.ToLower(pathpkg.Ext(path))
+		found := false
+		for _, e := range o.extensions {
+			if e == ext {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(o.includePaths) == 0 {
+		return true
+	}
+	for _, re := range o.includePaths {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// inspectFile measures the removable payload of the file at path.
+// reason is set when the file is skipped because it is in no supported format
+// or cannot be parsed.
+func inspectFile(fsys fs.FS, path string, opts []Option) (offender Offender, reason SkipReason, err error) {
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return Offender{}, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var formatErr *FormatError
+	switch format := DetectFormat(data); format {
+	case FormatJPEG:
+	case "":
+		return Offender{}, SkipUnsupportedFormat, nil
+	default:
+		// Other formats are measured by running their handler.
+		_, result, err := ExifRemoveThumbnailBytes(data, opts...)
+		if errors.As(err, &formatErr) {
+			return Offender{}, SkipUnreadable, nil
+		} else if err != nil {
+			return Offender{}, "", fmt.Errorf("%s: %w", path, err)
+		}
+		savings := result.BeforeSize - result.AfterSize
+		return Offender{
+			Path:          path,
+			Size:          int64(len(data)),
+			Format:        format,
+			Savings:       savings,
+			ThumbnailSize: result.ThumbnailSize,
+			Removable:     savings,
+		}, "", nil
+	}
+	report, err := Analyze(data)
+	if errors.As(err, &formatErr) {
+		return Offender{}, SkipUnreadable, nil
+	} else if err != nil {
+		return Offender{}, "", fmt.Errorf("%s: %w", path, err)
+	}
+	savings, err := EstimateSavings(bytes.NewReader(data), opts...)
+	if errors.As(err, &formatErr) {
+		return Offender{}, SkipUnreadable, nil
+	} else if err != nil {
+		return Offender{}, "", fmt.Errorf("%s: %w", path, err)
+	}
+	removable := savings
+	if o := newOptions(opts); !o.stripTrailer || o.constantSize {
+		removable += report.TrailerSize
+	}
+	return Offender{
+		Path:          path,
+		Size:          report.Size,
+		Format:        FormatJPEG,
+		Savings:       savings,
+		ThumbnailSize: report.ThumbnailSize,
+		TrailerSize:   report.TrailerSize,
+		Removable:     removable,
+	}, "", nil
+}
+
+// SampleReport extrapolates the savings over all files of a library from a sample.
+type SampleReport struct {
+	// Files is the number of files found; Sampled the number processed.
+	Files   int64
+	Sampled int
+	// JPEGFiles is the number of sampled files that are JPEG.
+	JPEGFiles int
+	// ByFormat and ByExtension break the estimate down by format name
+	// (FormatJPEG, a registered handler, or "" for unsupported files) and by
+	// lower-case file extension such as ".jpg" ("" for none).
+	ByFormat    map[string]GroupEstimate
+	ByExtension map[string]GroupEstimate
+	// SampleSavings is the total savings of the sampled files.
+	SampleSavings int64
+	// EstimatedSavings is the extrapolated total savings over all files.
+	EstimatedSavings int64
+	// Low and High bound the 95% confidence interval of EstimatedSavings.
+	// They equal EstimatedSavings when every file was sampled.
+	Low, High int64
+}
+
+// GroupEstimate extrapolates the savings of the files of one format or extension.
+type GroupEstimate struct {
+	// Sampled is the number of sampled files in the group; SampleSavings their savings.
+	Sampled       int
+	SampleSavings int64
+	// EstimatedFiles and EstimatedSavings extrapolate the group over all files.
+	EstimatedFiles   int64
+	EstimatedSavings int64
+}
+
+// SampleSavings estimates the savings of a whole library without processing
+// every file. It walks fsys once to pick a simple random sample of n files,
+// processes only those with the given options and extrapolates the total.
+// Files in no supported format count as saving nothing. The same seed picks the
+// same sample, so audits can be reproduced. Only the sampled paths are kept in memory.
+// Files excluded by WithModifiedAfter, WithMinFileSize and the related options are not counted.
+func SampleSavings(fsys fs.FS, n int, seed int64, opts ...Option) (SampleReport, error) {
+	var report SampleReport
+	if n <= 0 {
+		return report, fmt.Errorf("sample size must be positive")
+	}
+	rng := rand.New(rand.NewSource(seed))
+	// Reservoir sampling keeps a uniform sample while the number of files is unknown.
+	var sample []string
+	o := newOptions(opts)
+	err := walkFiles(fsys, o, nil, o.skip, func(path string) error {
+		report.Files++
+		if len(sample) < n {
+			sample = append(sample, path)
+		} else if i := rng.Int63n(report.Files); i < int64(n) {
+			sample[i] = path
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	report.Sampled = len(sample)
+	if report.Sampled == 0 {
+		return report, nil
+	}
+	report.ByFormat = map[string]GroupEstimate{}
+	report.ByExtension = map[string]GroupEstimate{}
+	add := func(groups map[string]GroupEstimate, key string, savings int64) {
+		g := groups[key]
+		g.Sampled++
+		g.SampleSavings += savings
+		groups[key] = g
+	}
+	savings := make([]float64, len(sample))
+	for i, path := range sample {
+		offender, reason, err := inspectFile(fsys, path, opts)
+		if err != nil {
+			return report, err
+		}
+		if offender.Format == FormatJPEG {
+			report.JPEGFiles++
+		}
+		if reason != "" {
+			o.skip(path, reason)
+		} else {
+			report.SampleSavings += offender.Savings
+			savings[i] = float64(offender.Savings)
+		}
+		add(report.ByFormat, offender.Format, offender.Savings)
+		add(report.ByExtension, strings.ToLower(pathpkg.Ext(path)), offender.Savings)
+	}
+	N, m := float64(report.Files), float64(report.Sampled)
+	for _, groups := range []map[string]GroupEstimate{report.ByFormat, report.ByExtension} {
+		for key, g := range groups {
+			g.EstimatedFiles = int64(math.Round(N * float64(g.Sampled) / m))
+			g.EstimatedSavings = int64(math.Round(N * float64(g.SampleSavings) / m))
+			groups[key] = g
+		}
+	}
+	mean := float64(report.SampleSavings) / m
+	variance := 0.0
+	for _, s := range savings {
+		variance += (s - mean) * (s - mean)
+	}
+	if m > 1 {
+		variance /= m - 1
+	}
+	// Standard error of the total with the finite population correction.
+	stdErr := N * math.Sqrt(variance/m*(1-m/N))
+	const z95 = 1.96
+	report.EstimatedSavings = int64(math.Round(N * mean))
+	report.Low = max(int64(math.Round(N*mean-z95*stdErr)), report.SampleSavings)
+	report.High = int64(math.Round(N*mean + z95*stdErr))
+	return report, nil
+}
@@ -0,0 +1,419 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestTopOffenders(t *testing.T) {
+	read := func(name string) []byte {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		require.NoError(t, err)
+		return data
+	}
+	fsys := fstest.MapFS{
+		"a/thumbnail.jpg":     {Data: read("thumbnail_embedded.jpg")},
+		"a/none.jpg":          {Data: read("metadata_none.jpg")},
+		"b/trailer.jpg":       {Data: append(read("metadata_none.jpg"), make([]byte, 20000)...)},
+		"b/actual_png.jpg":    {Data: read("actual_png.jpg")},
+		"b/notes/readme.txt":  {Data: []byte("not an image")},
+		"c/metadata_gps.jpeg": {Data: read("metadata_gps.jpg")},
+	}
+
+	t.Run("削減量の大きい順に並ぶ", func(t *testing.T) {
+		offenders, err := exifremovethumbnail.TopOffenders(fsys, 0)
+		require.NoError(t, err)
+		require.Len(t, offenders, 4, "JPEG以外はスキップされる")
+		require.Equal(t, "b/trailer.jpg", offenders[0].Path)
+		require.Equal(t, int64(20000), offenders[0].TrailerSize)
+		require.Equal(t, "a/thumbnail.jpg", offenders[1].Path)
+		require.Greater(t, offenders[1].ThumbnailSize, int64(0))
+		require.Equal(t, offenders[1].Savings, offenders[1].Removable)
+		for i := 1; i < len(offenders); i++ {
+			require.GreaterOrEqual(t, offenders[i-1].Removable, offenders[i].Removable)
+		}
+	})
+
+	t.Run("EOI後のデータを削除する場合は二重に数えない", func(t *testing.T) {
+		offenders, err := exifremovethumbnail.TopOffenders(fsys, 1, exifremovethumbnail.WithStripTrailer())
+		require.NoError(t, err)
+		require.Equal(t, "b/trailer.jpg", offenders[0].Path)
+		require.Equal(t, int64(20000), offenders[0].Savings)
+		require.Equal(t, int64(20000), offenders[0].Removable)
+	})
+
+	t.Run("上位N件だけ返す", func(t *testing.T) {
+		offenders, err := exifremovethumbnail.TopOffenders(fsys, 2)
+		require.NoError(t, err)
+		require.Len(t, offenders, 2)
+	})
+
+	t.Run("オプションが削減量に反映される", func(t *testing.T) {
+		plain, err := exifremovethumbnail.TopOffenders(fsys, 0)
+		require.NoError(t, err)
+		stripped, err := exifremovethumbnail.TopOffenders(fsys, 0, exifremovethumbnail.WithStripAllExif())
+		require.NoError(t, err)
+		savings := func(offenders []exifremovethumbnail.Offender, path string) int64 {
+			for _, o := range offenders {
+				if o.Path == path {
+					return o.Savings
+				}
+			}
+			return -1
+		}
+		require.Greater(t, savings(stripped, "c/metadata_gps.jpeg"), savings(plain, "c/metadata_gps.jpeg"))
+	})
+}
+
+func TestSampleSavings(t *testing.T) {
+	thumbnail, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	none, err := os.ReadFile(filepath.Join("testdata", "metadata_none.jpg"))
+	require.NoError(t, err)
+	savings, err := exifremovethumbnail.EstimateSavings(bytes.NewReader(thumbnail))
+	require.NoError(t, err)
+	require.Greater(t, savings, int64(0))
+
+	uniform := fstest.MapFS{}
+	mixed := fstest.MapFS{}
+	for i := 0; i < 40; i++ {
+		uniform[fmt.Sprintf("photos/%02d.jpg", i)] = &fstest.MapFile{Data: thumbnail}
+		if i%2 == 0 {
+			mixed[fmt.Sprintf("photos/%02d.jpg", i)] = &fstest.MapFile{Data: thumbnail}
+		} else {
+			mixed[fmt.Sprintf("photos/%02d.jpg", i)] = &fstest.MapFile{Data: none}
+		}
+	}
+
+	t.Run("全件を標本にすると正確な値になる", func(t *testing.T) {
+		report, err := exifremovethumbnail.SampleSavings(mixed, 100, 1)
+		require.NoError(t, err)
+		require.Equal(t, int64(40), report.Files)
+		require.Equal(t, 40, report.Sampled)
+		require.Equal(t, 20*savings, report.EstimatedSavings)
+		require.Equal(t, report.EstimatedSavings, report.Low)
+		require.Equal(t, report.EstimatedSavings, report.High)
+	})
+
+	t.Run("一様なライブラリは標本から正確に外挿される", func(t *testing.T) {
+		report, err := exifremovethumbnail.SampleSavings(uniform, 5, 1)
+		require.NoError(t, err)
+		require.Equal(t, 5, report.Sampled)
+		require.Equal(t, 5, report.JPEGFiles)
+		require.Equal(t, 40*savings, report.EstimatedSavings)
+		require.Equal(t, report.Low, report.High)
+	})
+
+	t.Run("信頼区間が推定値を含み同じシードで再現する", func(t *testing.T) {
+		report, err := exifremovethumbnail.SampleSavings(mixed, 10, 7)
+		require.NoError(t, err)
+		require.Equal(t, 10, report.Sampled)
+		require.LessOrEqual(t, report.Low, report.EstimatedSavings)
+		require.GreaterOrEqual(t, report.High, report.EstimatedSavings)
+		require.GreaterOrEqual(t, report.Low, report.SampleSavings)
+
+		again, err := exifremovethumbnail.SampleSavings(mixed, 10, 7)
+		require.NoError(t, err)
+		require.Equal(t, report, again)
+	})
+
+	t.Run("標本サイズは正の値", func(t *testing.T) {
+		_, err := exifremovethumbnail.SampleSavings(mixed, 0, 1)
+		require.Error(t, err)
+	})
+}
+
+func TestModTimeFilter(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	now := time.Now()
+	fsys := fstest.MapFS{
+		"old.jpg":    {Data: data, ModTime: now.Add(-30 * 24 * time.Hour)},
+		"recent.jpg": {Data: data, ModTime: now.Add(-2 * time.Hour)},
+		"new.jpg":    {Data: data, ModTime: now.Add(-time.Minute)},
+	}
+	paths := func(opts ...exifremovethumbnail.Option) []string {
+		offenders, err := exifremovethumbnail.TopOffenders(fsys, 0, opts...)
+		require.NoError(t, err)
+		var paths []string
+		for _, o := range offenders {
+			paths = append(paths, o.Path)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	t.Run("指定がなければ全ファイル", func(t *testing.T) {
+		require.Equal(t, []string{"new.jpg", "old.jpg", "recent.jpg"}, paths())
+	})
+
+	t.Run("日時で絞り込む", func(t *testing.T) {
+		require.Equal(t, []string{"new.jpg", "recent.jpg"}, paths(exifremovethumbnail.WithModifiedAfter(now.Add(-24*time.Hour))))
+		require.Equal(t, []string{"old.jpg"}, paths(exifremovethumbnail.WithModifiedBefore(now.Add(-24*time.Hour))))
+		require.Equal(t, []string{"recent.jpg"}, paths(
+			exifremovethumbnail.WithModifiedAfter(now.Add(-24*time.Hour)),
+			exifremovethumbnail.WithModifiedBefore(now.Add(-time.Hour)),
+		))
+	})
+
+	t.Run("経過時間で絞り込む", func(t *testing.T) {
+		require.Equal(t, []string{"new.jpg"}, paths(exifremovethumbnail.WithNewerThan(time.Hour)))
+		require.Equal(t, []string{"old.jpg", "recent.jpg"}, paths(exifremovethumbnail.WithOlderThan(time.Hour)))
+	})
+
+	t.Run("標本調査の母数にも反映される", func(t *testing.T) {
+		report, err := exifremovethumbnail.SampleSavings(fsys, 10, 1, exifremovethumbnail.WithOlderThan(24*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), report.Files)
+		require.Equal(t, 1, report.Sampled)
+	})
+}
+
+// recordingFS は読み込まれたファイルの順序を記録する
+type recordingFS struct {
+	fstest.MapFS
+	opened []string
+}
+
+func (r *recordingFS) ReadFile(name string) ([]byte, error) {
+	r.opened = append(r.opened, name)
+	return r.MapFS.ReadFile(name)
+}
+
+func TestSizeFilter(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	files := fstest.MapFS{
+		"a_small.jpg":  {Data: data},
+		"b_medium.jpg": {Data: append(append([]byte{}, data...), make([]byte, 100)...)},
+		"c_large.jpg":  {Data: append(append([]byte{}, data...), make([]byte, 20000)...)},
+		"d_tiny.txt":   {Data: []byte("x")},
+	}
+
+	t.Run("しきい値未満のファイルをスキップする", func(t *testing.T) {
+		offenders, err := exifremovethumbnail.TopOffenders(files, 0, exifremovethumbnail.WithMinFileSize(int64(len(data)+1)))
+		require.NoError(t, err)
+		require.Len(t, offenders, 2)
+		require.Equal(t, "c_large.jpg", offenders[0].Path)
+		require.Equal(t, "b_medium.jpg", offenders[1].Path)
+
+		report, err := exifremovethumbnail.SampleSavings(files, 10, 1, exifremovethumbnail.WithMinFileSize(int64(len(data))))
+		require.NoError(t, err)
+		require.Equal(t, int64(3), report.Files, "テキストファイルは母数に含まれない")
+	})
+
+	t.Run("大きいファイルから処理する", func(t *testing.T) {
+		fsys := &recordingFS{MapFS: files}
+		_, err := exifremovethumbnail.TopOffenders(fsys, 0)
+		require.NoError(t, err)
+		require.Equal(t, []string{"a_small.jpg", "b_medium.jpg", "c_large.jpg", "d_tiny.txt"}, fsys.opened, "既定ではパス順")
+
+		fsys.opened = nil
+		_, err = exifremovethumbnail.TopOffenders(fsys, 0, exifremovethumbnail.WithLargestFirst())
+		require.NoError(t, err)
+		require.Equal(t, []string{"c_large.jpg", "b_medium.jpg", "a_small.jpg", "d_tiny.txt"}, fsys.opened)
+	})
+}
+
+func TestPathFilter(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	files := fstest.MapFS{
+		"a.jpg":                 {Data: data},
+		"b.JPEG":                {Data: data},
+		"c.png":                 {Data: []byte("\x89PNG\r\n\x1a\n")},
+		"movie.mp4":             {Data: make([]byte, 200000)},
+		"2024/d.jpg":            {Data: data},
+		"2024/.thumbs/e.jpg":    {Data: data},
+		"2024/large/f.jpg":      {Data: append(append([]byte{}, data...), make([]byte, 20000)...)},
+		"archive/2019/g.jpg":    {Data: data},
+		"archive/2019/notes.md": {Data: []byte("x")},
+	}
+	paths := func(t *testing.T, opts ...exifremovethumbnail.Option) ([]string, map[string]exifremovethumbnail.SkipReason) {
+		skipped := map[string]exifremovethumbnail.SkipReason{}
+		opts = append(opts, exifremovethumbnail.WithSkipHandler(func(path string, reason exifremovethumbnail.SkipReason) {
+			skipped[path] = reason
+		}))
+		offenders, err := exifremovethumbnail.TopOffenders(files, 0, opts...)
+		require.NoError(t, err)
+		var got []string
+		for _, o := range offenders {
+			got = append(got, o.Path)
+		}
+		sort.Strings(got)
+		return got, skipped
+	}
+
+	t.Run("拡張子で絞り込む", func(t *testing.T) {
+		got, skipped := paths(t, exifremovethumbnail.WithExtensions("jpg", ".jpeg"))
+		require.Equal(t, []string{"2024/.thumbs/e.jpg", "2024/d.jpg", "2024/large/f.jpg", "a.jpg", "archive/2019/g.jpg", "b.JPEG"}, got)
+		require.Equal(t, exifremovethumbnail.SkipExcluded, skipped["movie.mp4"])
+		require.Len(t, skipped, 3)
+	})
+
+	t.Run("パスの正規表現で絞り込む", func(t *testing.T) {
+		got, _ := paths(t,
+			exifremovethumbnail.WithIncludePath(regexp.MustCompile(`^2024/`)),
+			exifremovethumbnail.WithIncludePath(regexp.MustCompile(`^a\.`)),
+			exifremovethumbnail.WithExcludePath(regexp.MustCompile(`(^|/)\.`)))
+		require.Equal(t, []string{"2024/d.jpg", "2024/large/f.jpg", "a.jpg"}, got)
+
+		// 除外は拡張子の指定より優先される
+		got, skipped := paths(t,
+			exifremovethumbnail.WithExtensions("jpg"),
+			exifremovethumbnail.WithExcludePath(regexp.MustCompile(`^archive/`)))
+		require.Equal(t, []string{"2024/.thumbs/e.jpg", "2024/d.jpg", "2024/large/f.jpg", "a.jpg"}, got)
+		require.Equal(t, exifremovethumbnail.SkipExcluded, skipped["archive/2019/g.jpg"])
+	})
+
+	t.Run("最大サイズを超えるファイルをスキップする", func(t *testing.T) {
+		got, skipped := paths(t, exifremovethumbnail.WithMaxFileSize(int64(len(data))))
+		require.Equal(t, []string{"2024/.thumbs/e.jpg", "2024/d.jpg", "a.jpg", "archive/2019/g.jpg", "b.JPEG"}, got)
+		require.Equal(t, exifremovethumbnail.SkipTooLarge, skipped["movie.mp4"])
+		require.Equal(t, exifremovethumbnail.SkipTooLarge, skipped["2024/large/f.jpg"])
+
+		report, err := exifremovethumbnail.SampleSavings(files, 10, 1,
+			exifremovethumbnail.WithMaxFileSize(int64(len(data))),
+			exifremovethumbnail.WithExtensions("jpg"))
+		require.NoError(t, err)
+		require.Equal(t, int64(4), report.Files)
+	})
+
+	t.Run("一括処理にも適用される", func(t *testing.T) {
+		fsys := mapWriteFS{fstest.MapFS{}}
+		for name, f := range files {
+			fsys.MapFS[name] = &fstest.MapFile{Data: f.Data}
+		}
+		report, err := exifremovethumbnail.RemoveAll(fsys, "**",
+			exifremovethumbnail.WithExtensions("jpg", "jpeg"),
+			exifremovethumbnail.WithExcludePath(regexp.MustCompile(`(^|/)\.`)))
+		require.NoError(t, err)
+		require.Len(t, report.Files, len(files))
+		require.Equal(t, len(files), report.Scanned)
+		require.Equal(t, 4, report.Skipped)
+		require.Equal(t, 5, report.Changed)
+		require.Equal(t, exifremovethumbnail.SkipExcluded, report.Files["2024/.thumbs/e.jpg"].Result.SkipReason)
+		require.Equal(t, data, fsys.MapFS["2024/.thumbs/e.jpg"].Data)
+	})
+
+	t.Run("パターンに一致しないファイルは報告しない", func(t *testing.T) {
+		skipped := map[string]exifremovethumbnail.SkipReason{}
+		fsys := mapWriteFS{fstest.MapFS{}}
+		for name, f := range files {
+			fsys.MapFS[name] = &fstest.MapFile{Data: f.Data}
+		}
+		report, err := exifremovethumbnail.RemoveAll(fsys, "2024/*.jpg",
+			exifremovethumbnail.WithMinFileSize(int64(len(data)+1)),
+			exifremovethumbnail.WithSkipHandler(func(name string, reason exifremovethumbnail.SkipReason) {
+				skipped[name] = reason
+			}))
+		require.NoError(t, err)
+		require.Equal(t, map[string]exifremovethumbnail.SkipReason{"2024/d.jpg": exifremovethumbnail.SkipTooSmall}, skipped)
+		require.Len(t, report.Files, 1)
+		require.True(t, report.Files["2024/d.jpg"].Result.Skipped)
+	})
+}
+
+func TestSavingsByFormat(t *testing.T) {
+	registerFakeRAW()
+	data, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	jpegSavings, err := exifremovethumbnail.EstimateSavings(bytes.NewReader(data))
+	require.NoError(t, err)
+	fsys := fstest.MapFS{
+		"a.jpg":     {Data: data},
+		"b.JPEG":    {Data: data},
+		"c.raw":     {Data: []byte("FAKERAW-dataTHUMB")},
+		"d.raw":     {Data: []byte("FAKERAW-data")},
+		"notes.txt": {Data: []byte("not an image")},
+	}
+
+	report, err := exifremovethumbnail.SampleSavings(fsys, 10, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.JPEGFiles)
+	require.Equal(t, 2*jpegSavings+5, report.SampleSavings)
+
+	t.Run("フォーマット別の内訳", func(t *testing.T) {
+		require.Equal(t, exifremovethumbnail.GroupEstimate{
+			Sampled: 2, SampleSavings: 2 * jpegSavings, EstimatedFiles: 2, EstimatedSavings: 2 * jpegSavings,
+		}, report.ByFormat[exifremovethumbnail.FormatJPEG])
+		require.Equal(t, exifremovethumbnail.GroupEstimate{
+			Sampled: 2, SampleSavings: 5, EstimatedFiles: 2, EstimatedSavings: 5,
+		}, report.ByFormat["fakeraw"])
+		require.Equal(t, 1, report.ByFormat[""].Sampled, "未対応のファイル")
+	})
+
+	t.Run("拡張子別の内訳は小文字にまとめる", func(t *testing.T) {
+		require.Len(t, report.ByExtension, 4)
+		require.Equal(t, jpegSavings, report.ByExtension[".jpeg"].SampleSavings)
+		require.Equal(t, jpegSavings, report.ByExtension[".jpg"].SampleSavings)
+		require.Equal(t, int64(5), report.ByExtension[".raw"].SampleSavings)
+		require.Equal(t, int64(0), report.ByExtension[".txt"].SampleSavings)
+	})
+
+	t.Run("登録済みフォーマットも削減量の上位に並ぶ", func(t *testing.T) {
+		offenders, err := exifremovethumbnail.TopOffenders(fsys, 0)
+		require.NoError(t, err)
+		require.Len(t, offenders, 4)
+		require.Equal(t, exifremovethumbnail.FormatJPEG, offenders[0].Format)
+		require.Equal(t, "c.raw", offenders[2].Path)
+		require.Equal(t, "fakeraw", offenders[2].Format)
+		require.Equal(t, int64(5), offenders[2].Savings)
+	})
+}
+
+func TestSkipReasons(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	now := time.Now()
+	fsys := fstest.MapFS{
+		"a.jpg":      {Data: data, ModTime: now},
+		"old.jpg":    {Data: data, ModTime: now.Add(-30 * 24 * time.Hour)},
+		"small.jpg":  {Data: data[:100], ModTime: now},
+		"notes.txt":  {Data: bytes.Repeat([]byte("x"), 2000), ModTime: now},
+		"broken.jpg": {Data: append([]byte{0xFF, 0xD8, 0x00, 0x01}, make([]byte, 2000)...), ModTime: now},
+	}
+	opts := func(skipped map[string]exifremovethumbnail.SkipReason) []exifremovethumbnail.Option {
+		return []exifremovethumbnail.Option{
+			exifremovethumbnail.WithMinFileSize(1000),
+			exifremovethumbnail.WithNewerThan(24 * time.Hour),
+			exifremovethumbnail.WithSkipHandler(func(path string, reason exifremovethumbnail.SkipReason) {
+				skipped[path] = reason
+			}),
+		}
+	}
+	want := map[string]exifremovethumbnail.SkipReason{
+		"old.jpg":    exifremovethumbnail.SkipModifiedTime,
+		"small.jpg":  exifremovethumbnail.SkipTooSmall,
+		"notes.txt":  exifremovethumbnail.SkipUnsupportedFormat,
+		"broken.jpg": exifremovethumbnail.SkipUnreadable,
+	}
+
+	t.Run("スキップしたファイルと理由を報告する", func(t *testing.T) {
+		skipped := map[string]exifremovethumbnail.SkipReason{}
+		offenders, err := exifremovethumbnail.TopOffenders(fsys, 0, opts(skipped)...)
+		require.NoError(t, err)
+		require.Len(t, offenders, 1)
+		require.Equal(t, "a.jpg", offenders[0].Path)
+		require.Equal(t, want, skipped)
+	})
+
+	t.Run("標本調査でも報告する", func(t *testing.T) {
+		skipped := map[string]exifremovethumbnail.SkipReason{}
+		report, err := exifremovethumbnail.SampleSavings(fsys, 10, 1, opts(skipped)...)
+		require.NoError(t, err)
+		require.Equal(t, int64(3), report.Files)
+		require.Equal(t, want, skipped)
+	})
+}
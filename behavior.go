@@ -0,0 +1,79 @@
+package exifremovethumbnail
+
+import "slices"
+
+// BehaviorVersion is incremented whenever the default output of the package changes.
+// Integrators can compare it across upgrades and pin options explicitly when it moves.
+const BehaviorVersion = 8
+
+// Behavior lists the behavior-affecting features of the package and their defaults,
+// i.e. what ExifRemoveThumbnailBytes does when called without options.
+type Behavior struct {
+	Version           int    `json:"version"`
+	RemoveThumbnail   bool   `json:"remove_thumbnail"`
+	ThumbnailRemoval  string `json:"thumbnail_removal"`
+	KeepGPS           bool   `json:"keep_gps"`
+	KeepMakerNote     bool   `json:"keep_maker_note"`
+	KeepOwnerInfo     bool   `json:"keep_owner_info"`
+	KeepComments      bool   `json:"keep_comments"`
+	KeepXMP           bool   `json:"keep_xmp"`
+	KeepICC           bool   `json:"keep_icc"`
+	KeepIPTC          bool   `json:"keep_iptc"`
+	KeepTrailer       bool   `json:"keep_trailer"`
+	KeepOtherSegments bool   `json:"keep_other_segments"`
+	CompactExif       bool   `json:"compact_exif"`
+	ConstantSize      bool   `json:"constant_size"`
+	VerifyPixels      bool   `json:"verify_pixels"`
+	ValidateOutput    bool   `json:"validate_output"`
+	WindowSize        int    `json:"window_size"`
+	ParseMode         string `json:"parse_mode"`
+	MaxInputSize      int64  `json:"max_input_size"`
+}
+
+// BehaviorChange records a change of default behavior.
+type BehaviorChange struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+}
+
+// BehaviorChangelog lists every change of default behavior, oldest first.
+var BehaviorChangelog = []BehaviorChange{
+	{Version: 1, Description: "IFD1 offset is set to 0 and the EXIF data is truncated at the IFD1 start"},
+	{Version: 2, Description: "IFD1 and the thumbnail are removed by a TIFF rewrite; data stored after them is kept with offsets fixed"},
+	{Version: 3, Description: "IFD1 and the thumbnail are removed wherever they are located, also before the IFD0 value data"},
+	{Version: 4, Description: "MakerNotes using absolute offsets are never moved; IFD1 data before them is zero-filled instead of removed"},
+	{Version: 5, Description: "an IFD1 pointer that does not lead to a readable IFD1, such as one looping back into another IFD, is dropped and nothing is removed in its place"},
+	{Version: 6, Description: "ThumbnailSize sums the thumbnails of all EXIF APP1 segments; duplicate EXIF segments are a violation in strict mode"},
+	{Version: 7, Description: "extended EXIF split over several full APP1 segments is stitched and processed as one"},
+	{Version: 8, Description: "0xFF fill bytes before markers are skipped and not copied to the output"},
+}
+
+// DefaultBehavior returns the defaults in effect for this version of the package.
+func DefaultBehavior() Behavior {
+	return behaviorOf(newOptions(nil))
+}
+
+// behaviorOf describes the behavior selected by o.
+func behaviorOf(o *options) Behavior {
+	return Behavior{
+		Version:           BehaviorVersion,
+		RemoveThumbnail:   !o.keepThumbnail,
+		ThumbnailRemoval:  "rewrite",
+		KeepGPS:           !o.removeGPS,
+		KeepMakerNote:     !slices.Contains(o.removeTags, TagRef{IFD: IFDExif, ID: tagMakerNote}),
+		KeepOwnerInfo:     !slices.Contains(o.removeTags, TagRef{IFD: IFD0, ID: tagArtist}),
+		KeepComments:      !o.stripComments,
+		KeepXMP:           !o.stripAllExif,
+		KeepICC:           !o.stripICC && o.replaceICC == nil,
+		KeepIPTC:          !o.stripIPTC,
+		KeepTrailer:       !o.stripTrailer || o.constantSize,
+		KeepOtherSegments: len(o.keepSegments) == 0 && len(o.dropSegments) == 0,
+		CompactExif:       o.compactExif,
+		ConstantSize:      o.constantSize,
+		VerifyPixels:      o.verifyPixels,
+		ValidateOutput:    o.validateOutput,
+		WindowSize:        o.windowSize,
+		ParseMode:         o.parseMode.String(),
+		MaxInputSize:      o.maxInputSize,
+	}
+}
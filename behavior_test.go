@@ -0,0 +1,41 @@
+package exifremovethumbnail_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestDefaultBehavior(t *testing.T) {
+	t.Run("既定の動作", func(t *testing.T) {
+		b := exifremovethumbnail.DefaultBehavior()
+		require.Equal(t, exifremovethumbnail.BehaviorVersion, b.Version)
+		require.True(t, b.RemoveThumbnail)
+		require.Equal(t, "rewrite", b.ThumbnailRemoval)
+		require.True(t, b.KeepGPS)
+		require.True(t, b.KeepICC)
+		require.True(t, b.KeepIPTC)
+		require.True(t, b.KeepTrailer)
+		require.False(t, b.VerifyPixels)
+		require.Equal(t, 32*1024, b.WindowSize)
+	})
+
+	t.Run("変更履歴の最後が現在のバージョン", func(t *testing.T) {
+		log := exifremovethumbnail.BehaviorChangelog
+		require.NotEmpty(t, log)
+		require.Equal(t, exifremovethumbnail.BehaviorVersion, log[len(log)-1].Version)
+		for i := 1; i < len(log); i++ {
+			require.Greater(t, log[i].Version, log[i-1].Version)
+		}
+	})
+
+	t.Run("snake_caseのJSON", func(t *testing.T) {
+		data, err := json.Marshal(exifremovethumbnail.DefaultBehavior())
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"remove_thumbnail":true`)
+		require.Contains(t, string(data), `"window_size":32768`)
+	})
+}
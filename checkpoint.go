@@ -0,0 +1,83 @@
+package exifremovethumbnail
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// WithCheckpoint makes RemoveAll record every file it completes in the file
+// at path, one name per line, and skip the files already recorded there, so
+// an interrupted run over a large archive resumes where it left off. The
+// file is created if it does not exist and appended to and synced after each
+// file. Failed files are not recorded and are retried on the next run; a file
+// processed again after a crash is left unchanged, since removal is
+// idempotent. Names containing a newline are never recorded. Delete the file
+// to start over.
+func WithCheckpoint(path string) Option {
+	return func(o *options) {
+		o.checkpoint = path
+	}
+}
+
+// checkpoint is the open checkpoint file of a RemoveAll run.
+type checkpoint struct {
+	f    *os.File
+	done map[string]bool
+}
+
+// openCheckpoint reads the names recorded in the file at path and opens it
+// for appending. A last line without a newline, left by an interrupted
+// write, is ignored and overwritten.
+func openCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	complete := len(data)
+	if i := strings.LastIndexByte(string(data), '\n'); i < len(data)-1 {
+		complete = i + 1
+	}
+	c := &checkpoint{done: map[string]bool{}}
+	for _, name := range strings.SplitAfter(string(data[:complete]), "\n") {
+		if name != "" {
+			c.done[strings.TrimSuffix(name, "\n")] = true
+		}
+	}
+	c.f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	if err := c.f.Truncate(int64(complete)); err != nil {
+		c.f.Close()
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	if _, err := c.f.Seek(int64(complete), 0); err != nil {
+		c.f.Close()
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	return c, nil
+}
+
+// record appends a completed name to the checkpoint and syncs it, so that
+// the name survives a crash of the machine right after the file was written.
+func (c *checkpoint) record(name string) error {
+	if strings.Contains(name, "\n") {
+		return nil
+	}
+	if _, err := c.f.WriteString(name + "\n"); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := c.f.Sync(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	c.done[name] = true
+	return nil
+}
+
+// Close closes the checkpoint file.
+func (c *checkpoint) Close() error {
+	return c.f.Close()
+}
@@ -0,0 +1,108 @@
+package exifremovethumbnail_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+// countingWriter はn回目以降の書き込みに失敗する
+type countingWriter struct {
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.n--; w.n < 0 {
+		return 0, errors.New("interrupted")
+	}
+	return len(p), nil
+}
+
+func TestCheckpoint(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	newFS := func() mapWriteFS {
+		return mapWriteFS{fstest.MapFS{
+			"a.jpg":      {Data: inData},
+			"b/c.jpg":    {Data: inData},
+			"b/d.jpg":    {Data: inData},
+			"e.jpg":      {Data: inData},
+			"broken.jpg": {Data: []byte{0xFF, 0xD8, 0xFF}},
+		}}
+	}
+	readLines := func(t *testing.T, path string) []string {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		sort.Strings(lines)
+		return lines
+	}
+
+	t.Run("中断したところから再開する", func(t *testing.T) {
+		fsys := newFS()
+		cp := filepath.Join(t.TempDir(), "checkpoint")
+		_, err := exifremovethumbnail.RemoveAll(fsys, "**/*.jpg",
+			exifremovethumbnail.WithCheckpoint(cp),
+			exifremovethumbnail.WithResultStream(&countingWriter{n: 2}))
+		require.Error(t, err)
+		require.Len(t, readLines(t, cp), 2)
+
+		skipped := map[string]exifremovethumbnail.SkipReason{}
+		report, err := exifremovethumbnail.RemoveAll(fsys, "**/*.jpg",
+			exifremovethumbnail.WithCheckpoint(cp),
+			exifremovethumbnail.WithSkipHandler(func(name string, reason exifremovethumbnail.SkipReason) {
+				skipped[name] = reason
+			}))
+		require.NoError(t, err)
+		require.Len(t, skipped, 2)
+		for name := range skipped {
+			require.Equal(t, exifremovethumbnail.SkipCompleted, skipped[name])
+			require.Equal(t, exifremovethumbnail.SkipCompleted, report.Files[name].Result.SkipReason)
+		}
+		require.Len(t, report.Files, 5)
+		require.Equal(t, 2, report.Skipped)
+		// 中断したときに処理中だったファイルは書き換え済みで変更がない
+		require.Equal(t, 1, report.Changed)
+		require.Equal(t, 1, report.Failed)
+		for _, name := range []string{"a.jpg", "b/c.jpg", "b/d.jpg", "e.jpg"} {
+			require.Equal(t, want, fsys.MapFS[name].Data, name)
+		}
+		// 失敗したファイルは記録されない
+		require.Equal(t, []string{"a.jpg", "b/c.jpg", "b/d.jpg", "e.jpg"}, readLines(t, cp))
+
+		// 失敗したファイルだけを再び処理する
+		report, err = exifremovethumbnail.RemoveAll(fsys, "**/*.jpg", exifremovethumbnail.WithCheckpoint(cp))
+		require.NoError(t, err)
+		require.Equal(t, 4, report.Skipped)
+		require.Equal(t, 1, report.Failed)
+		require.Error(t, report.Files["broken.jpg"].Err)
+	})
+
+	t.Run("書きかけの行は無視する", func(t *testing.T) {
+		fsys := newFS()
+		cp := filepath.Join(t.TempDir(), "checkpoint")
+		require.NoError(t, os.WriteFile(cp, []byte("a.jpg\nb/c.j"), 0644))
+		report, err := exifremovethumbnail.RemoveAll(fsys, "**/*.jpg", exifremovethumbnail.WithCheckpoint(cp))
+		require.NoError(t, err)
+		require.True(t, report.Files["a.jpg"].Result.Skipped)
+		require.True(t, report.Files["b/c.jpg"].Result.HadThumbnail)
+		require.Equal(t, inData, fsys.MapFS["a.jpg"].Data)
+		require.Equal(t, []string{"a.jpg", "b/c.jpg", "b/d.jpg", "e.jpg"}, readLines(t, cp))
+	})
+
+	t.Run("チェックポイントを開けない", func(t *testing.T) {
+		_, err := exifremovethumbnail.RemoveAll(newFS(), "**/*.jpg",
+			exifremovethumbnail.WithCheckpoint(filepath.Join(t.TempDir(), "missing", "checkpoint")))
+		require.Error(t, err)
+	})
+}
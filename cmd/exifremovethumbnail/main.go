@@ -0,0 +1,407 @@
+// Command exifremovethumbnail removes embedded thumbnails from JPEG EXIF metadata.
+//
+// Usage:
+//
+//	exifremovethumbnail -in input.jpg -out output.jpg [-policy policy.yaml] [-json] [-verify] [-verify-image-data] [-validate-output] [-mmap] [-retained] [-conform] [-strict|-lenient]
+//	exifremovethumbnail policy validate policy.yaml
+//	exifremovethumbnail policy explain policy.yaml sample.jpg
+//	exifremovethumbnail analyze input.jpg
+//	exifremovethumbnail hash FILE...
+//	exifremovethumbnail top [-n 20] [-policy policy.yaml] [-after T] [-before T] [-newer-than D] [-older-than D] [-min-size N] [-max-size N] [-ext jpg,jpeg] [-include RE] [-exclude RE] [-largest-first] DIR
+//	exifremovethumbnail sample [-n 1000] [-seed 1] [-policy policy.yaml] [-after T] [-before T] [-newer-than D] [-older-than D] [-min-size N] [-max-size N] [-ext jpg,jpeg] [-include RE] [-exclude RE] [-largest-first] DIR
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "policy" {
+		if err := runPolicy(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "top" {
+		if err := runTop(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "sample" {
+		if err := runSample(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "hash" {
+		if err := runHash(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "analyze" {
+		if err := runAnalyze(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	in := flag.String("in", "", "input JPEG file")
+	out := flag.String("out", "", "output JPEG file")
+	policyPath := flag.String("policy", "", "policy file (JSON or YAML)")
+	jsonOutput := flag.Bool("json", false, "print the result as JSON")
+	verify := flag.Bool("verify", false, "fail unless input and output decode to identical pixels")
+	verifyImageData := flag.Bool("verify-image-data", false, "fail unless the image data from SOS on is byte-identical")
+	validateOutput := flag.Bool("validate-output", false, "fail unless the output header and EXIF can be read back")
+	mmap := flag.Bool("mmap", false, "map the input file into memory instead of reading it")
+	retained := flag.Bool("retained", false, "list the EXIF tags remaining in the output")
+	conform := flag.Bool("conform", false, "adjust the output for strict decoders and legacy clients")
+	strict := flag.Bool("strict", false, "reject any spec violation")
+	lenient := flag.Bool("lenient", false, "recover from damaged input and report it as warnings")
+	flag.Parse()
+
+	if *in == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts []exifremovethumbnail.Option
+	if *policyPath != "" {
+		policy, err := exifremovethumbnail.LoadPolicyFile(*policyPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		opts = append(opts, exifremovethumbnail.WithPolicy(policy))
+	}
+
+	if *verify {
+		opts = append(opts, exifremovethumbnail.WithVerifyPixels())
+	}
+	if *verifyImageData {
+		opts = append(opts, exifremovethumbnail.WithVerifyImageDataUnchanged())
+	}
+	if *validateOutput {
+		opts = append(opts, exifremovethumbnail.WithValidateOutput())
+	}
+	if *mmap {
+		opts = append(opts, exifremovethumbnail.WithMmap())
+	}
+	if *retained {
+		opts = append(opts, exifremovethumbnail.WithRetainedTagsReport())
+	}
+	if *conform {
+		opts = append(opts, exifremovethumbnail.WithConformance())
+	}
+	switch {
+	case *strict && *lenient:
+		fmt.Fprintln(os.Stderr, "-strict and -lenient cannot be combined")
+		os.Exit(2)
+	case *strict:
+		opts = append(opts, exifremovethumbnail.WithParseMode(exifremovethumbnail.ParseStrict))
+	case *lenient:
+		opts = append(opts, exifremovethumbnail.WithParseMode(exifremovethumbnail.ParseLenient))
+	}
+
+	result, err := exifremovethumbnail.ExifRemoveThumbnail(*in, *out, opts...)
+	var formatErr *exifremovethumbnail.FormatError
+	if err != nil && *jsonOutput && errors.As(err, &formatErr) {
+		data, _ := json.Marshal(map[string]any{"error": formatErr})
+		fmt.Println(string(data))
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *jsonOutput {
+		data, err := json.Marshal(result)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("thumbnail removed: %v, %d -> %d bytes\n", result.HadThumbnail, result.BeforeSize, result.AfterSize)
+	for _, tag := range result.RetainedTags {
+		fmt.Printf("retained: %s\n", tag)
+	}
+}
+
+// runPolicy handles the "policy validate" and "policy explain" subcommands.
+func runPolicy(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: exifremovethumbnail policy validate POLICY | policy explain POLICY SAMPLE")
+	}
+	policy, err := exifremovethumbnail.LoadPolicyFile(args[1])
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "validate":
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("invalid policy: %w", err)
+		}
+		fmt.Println("policy is valid")
+		return nil
+	case "explain":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: exifremovethumbnail policy explain POLICY SAMPLE")
+		}
+		data, err := os.ReadFile(args[2])
+		if err != nil {
+			return fmt.Errorf("failed to read sample file: %w", err)
+		}
+		ex, err := exifremovethumbnail.Explain(policy, data)
+		if err != nil {
+			return err
+		}
+		for _, action := range ex.Actions {
+			fmt.Println(action)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown policy subcommand %q", args[0])
+}
+
+// runAnalyze prints the size breakdown of a JPEG file.
+func runAnalyze(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: exifremovethumbnail analyze FILE")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read input file: %w", err)
+	}
+	report, err := exifremovethumbnail.Analyze(data)
+	if err != nil {
+		return err
+	}
+	for _, s := range report.Segments {
+		fmt.Printf("%-16s %10d bytes at %d\n", s.Name, s.Size, s.Offset)
+	}
+	fmt.Printf("%-16s %10d bytes (inside APP1-Exif)\n", "thumbnail", report.ThumbnailSize)
+	fmt.Printf("%-16s %10d bytes\n", "image data", report.ImageDataSize)
+	fmt.Printf("%-16s %10d bytes\n", "trailer", report.TrailerSize)
+	fmt.Printf("%-16s %10d bytes\n", "total", report.Size)
+	return nil
+}
+
+// runHash prints the scan hash and the pixel hash of each file, for regression
+// gates comparing a pipeline's outputs with its inputs.
+func runHash(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: exifremovethumbnail hash FILE...")
+	}
+	for _, path := range args {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read input file: %w", err)
+		}
+		pixels, err := exifremovethumbnail.PixelHash(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Printf("%s  scan:%s  pixels:%s\n", path, exifremovethumbnail.ScanHash(data), pixels)
+	}
+	return nil
+}
+
+// runTop prints the files with the largest removable payload under a directory.
+func runTop(args []string) error {
+	fset := flag.NewFlagSet("top", flag.ContinueOnError)
+	n := fset.Int("n", 20, "number of files to list (0 for all)")
+	policyPath := fset.String("policy", "", "policy file (JSON or YAML)")
+	timeFilter := timeFilterFlags(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 1 {
+		return fmt.Errorf("usage: exifremovethumbnail top [-n N] [-policy POLICY] [-after T] [-before T] [-newer-than D] [-older-than D] [-min-size N] [-max-size N] [-ext jpg,jpeg] [-include RE] [-exclude RE] [-largest-first] DIR")
+	}
+	opts, err := timeFilter()
+	if err != nil {
+		return err
+	}
+	if *policyPath != "" {
+		policy, err := exifremovethumbnail.LoadPolicyFile(*policyPath)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, exifremovethumbnail.WithPolicy(policy))
+	}
+	skipOpt, printSkips := skipCounter()
+	opts = append(opts, skipOpt)
+	offenders, err := exifremovethumbnail.TopOffenders(os.DirFS(fset.Arg(0)), *n, opts...)
+	if err != nil {
+		return err
+	}
+	for _, o := range offenders {
+		fmt.Printf("%10d  %s (thumbnail %d, trailer %d)\n", o.Removable, o.Path, o.ThumbnailSize, o.TrailerSize)
+	}
+	printSkips()
+	return nil
+}
+
+// runSample estimates the savings over a directory from a random sample of its files.
+func runSample(args []string) error {
+	fset := flag.NewFlagSet("sample", flag.ContinueOnError)
+	n := fset.Int("n", 1000, "number of files to sample")
+	seed := fset.Int64("seed", 1, "random seed choosing the sample")
+	policyPath := fset.String("policy", "", "policy file (JSON or YAML)")
+	timeFilter := timeFilterFlags(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 1 {
+		return fmt.Errorf("usage: exifremovethumbnail sample [-n N] [-seed S] [-policy POLICY] [-after T] [-before T] [-newer-than D] [-older-than D] [-min-size N] [-max-size N] [-ext jpg,jpeg] [-include RE] [-exclude RE] [-largest-first] DIR")
+	}
+	opts, err := timeFilter()
+	if err != nil {
+		return err
+	}
+	if *policyPath != "" {
+		policy, err := exifremovethumbnail.LoadPolicyFile(*policyPath)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, exifremovethumbnail.WithPolicy(policy))
+	}
+	skipOpt, printSkips := skipCounter()
+	opts = append(opts, skipOpt)
+	report, err := exifremovethumbnail.SampleSavings(os.DirFS(fset.Arg(0)), *n, *seed, opts...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("sampled %d of %d files (%d JPEG), %d bytes saved in sample\n",
+		report.Sampled, report.Files, report.JPEGFiles, report.SampleSavings)
+	fmt.Printf("estimated savings: %d bytes (95%% CI %d..%d)\n", report.EstimatedSavings, report.Low, report.High)
+	formats := make([]string, 0, len(report.ByFormat))
+	for format := range report.ByFormat {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	for _, format := range formats {
+		g := report.ByFormat[format]
+		if format == "" {
+			format = "unsupported"
+		}
+		fmt.Printf("  %-12s ~%d files, ~%d bytes\n", format, g.EstimatedFiles, g.EstimatedSavings)
+	}
+	printSkips()
+	return nil
+}
+
+// skipCounter returns an option counting the files skipped by the directory
+// subcommands by reason, and a function printing the counts to stderr.
+func skipCounter() (exifremovethumbnail.Option, func()) {
+	counts := map[exifremovethumbnail.SkipReason]int{}
+	opt := exifremovethumbnail.WithSkipHandler(func(path string, reason exifremovethumbnail.SkipReason) {
+		counts[reason]++
+	})
+	return opt, func() {
+		reasons := make([]string, 0, len(counts))
+		for reason := range counts {
+			reasons = append(reasons, string(reason))
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Fprintf(os.Stderr, "skipped %d files: %s\n", counts[exifremovethumbnail.SkipReason(reason)], reason)
+		}
+	}
+}
+
+// timeFilterFlags defines the modification time, size and path filters of the
+// directory subcommands and returns a function converting them into options after parsing.
+// Times are RFC 3339 or a plain date; durations use time.ParseDuration syntax.
+func timeFilterFlags(fset *flag.FlagSet) func() ([]exifremovethumbnail.Option, error) {
+	after := fset.String("after", "", "only files modified after this time")
+	before := fset.String("before", "", "only files modified before this time")
+	newerThan := fset.Duration("newer-than", 0, "only files modified within this duration")
+	olderThan := fset.Duration("older-than", 0, "only files not modified within this duration")
+	minSize := fset.Int64("min-size", 0, "skip files smaller than this many bytes")
+	maxSize := fset.Int64("max-size", 0, "skip files larger than this many bytes")
+	exts := fset.String("ext", "", "only files with these comma-separated extensions")
+	include := fset.String("include", "", "only files whose path matches this regular expression")
+	exclude := fset.String("exclude", "", "skip files whose path matches this regular expression")
+	largestFirst := fset.Bool("largest-first", false, "process the largest files first")
+	return func() ([]exifremovethumbnail.Option, error) {
+		var opts []exifremovethumbnail.Option
+		if *after != "" {
+			t, err := parseTime(*after)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, exifremovethumbnail.WithModifiedAfter(t))
+		}
+		if *before != "" {
+			t, err := parseTime(*before)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, exifremovethumbnail.WithModifiedBefore(t))
+		}
+		if *newerThan > 0 {
+			opts = append(opts, exifremovethumbnail.WithNewerThan(*newerThan))
+		}
+		if *olderThan > 0 {
+			opts = append(opts, exifremovethumbnail.WithOlderThan(*olderThan))
+		}
+		if *minSize > 0 {
+			opts = append(opts, exifremovethumbnail.WithMinFileSize(*minSize))
+		}
+		if *maxSize > 0 {
+			opts = append(opts, exifremovethumbnail.WithMaxFileSize(*maxSize))
+		}
+		if *exts != "" {
+			opts = append(opts, exifremovethumbnail.WithExtensions(strings.Split(*exts, ",")...))
+		}
+		if *include != "" {
+			re, err := regexp.Compile(*include)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -include: %w", err)
+			}
+			opts = append(opts, exifremovethumbnail.WithIncludePath(re))
+		}
+		if *exclude != "" {
+			re, err := regexp.Compile(*exclude)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -exclude: %w", err)
+			}
+			opts = append(opts, exifremovethumbnail.WithExcludePath(re))
+		}
+		if *largestFirst {
+			opts = append(opts, exifremovethumbnail.WithLargestFirst())
+		}
+		return opts, nil
+	}
+}
+
+// parseTime accepts RFC 3339 times and plain dates in local time.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,123 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+// hammer は fn を多数のgoroutineから同時に呼び、最初のエラーを返す
+func hammer(t *testing.T, n int, fn func(i int) error) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := fn(i); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+// go test -race で実行すると、共有した入力や内部状態への競合を検出する
+func TestConcurrentUse(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	original := append([]byte{}, inData...)
+	opts := []exifremovethumbnail.Option{exifremovethumbnail.WithRemoveGPS(), exifremovethumbnail.WithIntegrityCheck()}
+	want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, opts...)
+	require.NoError(t, err)
+	p := exifremovethumbnail.NewProcessor(opts...)
+
+	t.Run("同じ入力を共有して処理する", func(t *testing.T) {
+		hammer(t, 64, func(i int) error {
+			var out []byte
+			var err error
+			switch i % 4 {
+			case 0:
+				out, _, err = exifremovethumbnail.ExifRemoveThumbnailBytes(inData, opts...)
+			case 1:
+				out, _, err = p.ProcessBytes(inData)
+			case 2:
+				var buf bytes.Buffer
+				_, err = p.Process(&buf, bytes.NewReader(inData))
+				out = buf.Bytes()
+			case 3:
+				r := exifremovethumbnail.NewReader(bytes.NewReader(inData), exifremovethumbnail.WithRemoveGPS())
+				out, err = io.ReadAll(r)
+				r.Close()
+			}
+			if err == nil && !bytes.Equal(want, out) {
+				err = fmt.Errorf("goroutine %d: output differs", i)
+			}
+			return err
+		})
+		require.Equal(t, original, inData, "入力は書き換えない")
+	})
+
+	t.Run("読み取り専用のAPIを並行して呼ぶ", func(t *testing.T) {
+		hammer(t, 64, func(i int) error {
+			var err error
+			switch i % 5 {
+			case 0:
+				_, err = exifremovethumbnail.Analyze(inData)
+			case 1:
+				_, _, err = exifremovethumbnail.DetectThumbnail(bytes.NewReader(inData))
+			case 2:
+				if len(exifremovethumbnail.FindExifBlocks(inData)) != 1 {
+					err = fmt.Errorf("goroutine %d: EXIF block not found", i)
+				}
+			case 3:
+				_, err = exifremovethumbnail.EstimateSavings(bytes.NewReader(inData))
+			case 4:
+				_, err = exifremovethumbnail.Explain(exifremovethumbnail.DefaultPolicy, inData)
+			}
+			return err
+		})
+		require.Equal(t, original, inData, "入力は書き換えない")
+	})
+
+	t.Run("ファイルとオーバーレイを並行して処理する", func(t *testing.T) {
+		root := t.TempDir()
+		for i := 0; i < 16; i++ {
+			require.NoError(t, os.WriteFile(filepath.Join(root, fmt.Sprintf("%02d.jpg", i)), inData, 0644))
+		}
+		ov := exifremovethumbnail.Overlay{Root: root, Dir: t.TempDir()}
+		out := t.TempDir()
+		hammer(t, 32, func(i int) error {
+			name := fmt.Sprintf("%02d.jpg", i%16)
+			if i < 16 {
+				_, err := ov.Process(name, opts...)
+				return err
+			}
+			_, err := p.ProcessFile(filepath.Join(root, name), filepath.Join(out, name))
+			return err
+		})
+		for i := 0; i < 16; i++ {
+			name := fmt.Sprintf("%02d.jpg", i)
+			for _, dir := range []string{ov.Dir, out} {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, err)
+				require.Equal(t, want, got)
+			}
+		}
+		_, err := exifremovethumbnail.TopOffenders(os.DirFS(root), 0)
+		require.NoError(t, err)
+	})
+}
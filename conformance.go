@@ -0,0 +1,76 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/jpegseg"
+)
+
+// conform adjusts a rewritten JPEG for strict decoders and returns the new data
+// with a note for every change made. The JFIF APP0 segment is moved directly after
+// SOI and the EXIF APP1 segment directly after it, as Windows Imaging Component
+// ignores EXIF found elsewhere, and a missing EOI marker is appended, which libjpeg
+// reports as premature end of data. Data that cannot be split into segments is
+// returned unchanged.
+func conform(data []byte) ([]byte, []string) {
+	segments, sos, err := jpegseg.Split(data)
+	if err != nil {
+		return data, nil
+	}
+	jfif, exif := -1, -1
+	for i, s := range segments {
+		switch segmentName(s.Marker, s.Payload()) {
+		case SegmentJFIF:
+			if jfif < 0 {
+				jfif = i
+			}
+		case SegmentExif:
+			if exif < 0 {
+				exif = i
+			}
+		}
+	}
+	var order []int
+	if jfif >= 0 {
+		order = append(order, jfif)
+	}
+	if exif >= 0 {
+		order = append(order, exif)
+	}
+	for i := range segments {
+		if i != jfif && i != exif {
+			order = append(order, i)
+		}
+	}
+
+	var notes []string
+	out := make([]byte, 0, len(data)+2)
+	out = append(out, data[:2]...)
+	for n, i := range order {
+		if n != i && notes == nil {
+			notes = append(notes, "conformance: JFIF and EXIF segments moved to the front")
+		}
+		out = append(out, segments[i].Data...)
+	}
+	out = append(out, data[sos:]...)
+	if sos < len(data) && !bytes.Contains(data[sos:], []byte{0xFF, 0xD9}) {
+		out = append(out, 0xFF, 0xD9)
+		notes = append(notes, "conformance: missing EOI marker appended")
+	}
+	return out, notes
+}
+
+// applyConformance runs conform on the output of a rewrite and updates result.
+func applyConformance(output []byte, result *ExifRemoveThumbnailResult) []byte {
+	out, notes := conform(output)
+	if notes == nil {
+		return output
+	}
+	result.Warnings = append(result.Warnings, notes...)
+	result.AfterSize = int64(len(out))
+	if len(out) > len(output) && result.OutputScanHash != "" {
+		// Only an appended EOI changes the SOS..EOI region.
+		result.OutputScanHash = ScanHash(out)
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestConformance(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	// SOI, APP0-JFIF (2..20), APP1-Exif (20..8607), DQT...
+	app0, exif, rest := inData[2:20], inData[20:8607], inData[8607:]
+
+	t.Run("適合済みの入力はそのまま", func(t *testing.T) {
+		want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		got, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithConformance())
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+		require.Empty(t, res.Warnings)
+	})
+
+	t.Run("JFIFとEXIFを先頭に並べ替える", func(t *testing.T) {
+		// COM、EXIF、JFIF の順に並べた入力
+		com := []byte{0xFF, 0xFE, 0x00, 0x07, 'h', 'e', 'l', 'l', 'o'}
+		var input []byte
+		input = append(input, 0xFF, 0xD8)
+		input = append(input, com...)
+		input = append(input, exif...)
+		input = append(input, app0...)
+		input = append(input, rest...)
+
+		plain, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(input)
+		require.NoError(t, err)
+		require.Equal(t, com, plain[2:2+len(com)])
+
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(input, exifremovethumbnail.WithConformance())
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Len(t, out, len(plain))
+		require.Equal(t, int64(len(out)), res.AfterSize)
+		require.Equal(t, app0, out[2:20])
+		require.Equal(t, []byte{0xFF, 0xE1}, out[20:22])
+		require.Equal(t, "Exif\x00\x00", string(out[24:30]))
+		exifEnd := 22 + int(binary.BigEndian.Uint16(out[22:24]))
+		require.Equal(t, com, out[exifEnd:exifEnd+len(com)])
+		require.Contains(t, res.Warnings, "conformance: JFIF and EXIF segments moved to the front")
+
+		report, err := exifremovethumbnail.Analyze(out)
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.SegmentJFIF, report.Segments[1].Name)
+		require.Equal(t, exifremovethumbnail.SegmentExif, report.Segments[2].Name)
+	})
+
+	t.Run("欠けたEOIを補う", func(t *testing.T) {
+		input := inData[:len(inData)-2]
+		plain, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(input)
+		require.NoError(t, err)
+		require.False(t, bytes.HasSuffix(plain, []byte{0xFF, 0xD9}))
+
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(input, exifremovethumbnail.WithConformance())
+		require.NoError(t, err)
+		require.Equal(t, append(append([]byte{}, plain...), 0xFF, 0xD9), out)
+		require.Equal(t, int64(len(out)), res.AfterSize)
+		require.Contains(t, res.Warnings, "conformance: missing EOI marker appended")
+
+		// 補った後の出力は完全な入力の出力と同じスキャンハッシュになる
+		_, full, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, full.OutputScanHash, res.OutputScanHash)
+	})
+}
@@ -0,0 +1,58 @@
+package exifremovethumbnail
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csvHeader is the header row written by BatchReport.WriteCSV.
+var csvHeader = []string{"path", "before_size", "after_size", "thumbnail_size", "skip_reason", "error"}
+
+// WriteCSV writes the files of the report to w as CSV, one row per file
+// sorted by path, under the header path, before_size, after_size,
+// thumbnail_size, skip_reason, error, so that a run can be opened in a
+// spreadsheet. Sizes are in bytes and left empty for skipped files, which
+// have their SkipReason instead; error is empty for files processed without
+// one. File names and messages starting with =, +, -, @, a tab or a carriage
+// return are prefixed with ' so that a spreadsheet does not evaluate them as
+// formulas.
+func (r BatchReport) WriteCSV(w io.Writer) error {
+	paths := make([]string, 0, len(r.Files))
+	for path := range r.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		f := r.Files[path]
+		var msg string
+		if f.Err != nil {
+			msg = f.Err.Error()
+		}
+		row := []string{csvText(path), "", "", "", string(f.Result.SkipReason), csvText(msg)}
+		if !f.Result.Skipped {
+			row[1] = strconv.FormatInt(f.Result.BeforeSize, 10)
+			row[2] = strconv.FormatInt(f.Result.AfterSize, 10)
+			row[3] = strconv.FormatInt(f.Result.ThumbnailSize, 10)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvText neutralizes a cell that a spreadsheet would read as a formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestBatchReportCSV(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	fsys := mapWriteFS{fstest.MapFS{
+		"b.jpg":        {Data: inData},
+		"a, comma.jpg": {Data: want},
+		"c.jpg":        {Data: []byte{0xFF, 0xD8, 0xFF}},
+		"d.jpg":        {Data: []byte("not an image")},
+	}}
+	report, err := exifremovethumbnail.RemoveAll(fsys, "*.jpg")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+
+	t.Run("見出しとパス順の行", func(t *testing.T) {
+		require.Len(t, records, 5)
+		require.Equal(t, []string{"path", "before_size", "after_size", "thumbnail_size", "skip_reason", "error"}, records[0])
+		// カンマを含むパスも正しく引用される
+		require.Equal(t, []string{"a, comma.jpg", strconv.Itoa(len(want)), strconv.Itoa(len(want)), "0", "", ""}, records[1])
+		require.Equal(t, []string{"b.jpg", strconv.Itoa(len(inData)), strconv.Itoa(len(want)), strconv.FormatInt(res.ThumbnailSize, 10), "", ""}, records[2])
+	})
+
+	t.Run("エラーのメッセージ", func(t *testing.T) {
+		require.Equal(t, "c.jpg", records[3][0])
+		require.Equal(t, report.Files["c.jpg"].Err.Error(), records[3][5])
+	})
+
+	t.Run("スキップしたファイルはサイズの代わりに理由", func(t *testing.T) {
+		require.Equal(t, []string{"d.jpg", "", "", "", "unsupported_format", ""}, records[4])
+	})
+
+	t.Run("数式として評価されるセルを無効にする", func(t *testing.T) {
+		report := exifremovethumbnail.BatchReport{Files: map[string]exifremovethumbnail.FileResult{
+			"=HYPERLINK(1).jpg": {},
+			"+1.jpg":            {},
+			"-1.jpg":            {Err: errors.New("@SUM(A1)")},
+			"a=1.jpg":           {},
+			"\tcmd.jpg":         {},
+			"\rcmd.jpg":         {},
+		}}
+		var buf bytes.Buffer
+		require.NoError(t, report.WriteCSV(&buf))
+		records, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		var paths []string
+		for _, r := range records[1:] {
+			paths = append(paths, r[0])
+		}
+		require.Equal(t, []string{"'\tcmd.jpg", "'\rcmd.jpg", "'+1.jpg", "'-1.jpg", "'=HYPERLINK(1).jpg", "a=1.jpg"}, paths)
+		require.Equal(t, "'@SUM(A1)", records[4][5])
+	})
+
+	t.Run("空のレポートは見出しだけ", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, exifremovethumbnail.BatchReport{}.WriteCSV(&buf))
+		require.Equal(t, "path,before_size,after_size,thumbnail_size,skip_reason,error\n", buf.String())
+	})
+}
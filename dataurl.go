@@ -0,0 +1,109 @@
+package exifremovethumbnail
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// base64Encodings lists the accepted base64 alphabets, with and without padding.
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+}
+
+// decodeBase64 decodes s in any of the accepted base64 alphabets.
+// The decoded size is checked against the options before anything is allocated.
+func decodeBase64(s string, o *options) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+	if o.maxInputSize > 0 && int64(base64.RawStdEncoding.DecodedLen(len(strings.TrimRight(s, "=")))) > o.maxInputSize {
+		return nil, ErrInputTooLarge
+	}
+	var err error
+	for _, enc := range base64Encodings {
+		var data []byte
+		if data, err = enc.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+	return nil, &FormatError{Code: CodeInvalidEncoding, Offset: -1, msg: "invalid base64 data: " + err.Error()}
+}
+
+// ExifRemoveThumbnailBase64 is ExifRemoveThumbnailBytes for base64-encoded data,
+// as found in JSON APIs. Standard and URL-safe alphabets are accepted with or
+// without padding, and whitespace is ignored. The output uses the standard
+// alphabet with padding. WithMaxInputSize applies to the decoded size and is
+// checked before decoding.
+func ExifRemoveThumbnailBase64(s string, opts ...Option) (string, ExifRemoveThumbnailResult, error) {
+	data, err := decodeBase64(s, newOptions(opts))
+	if err != nil {
+		return "", ExifRemoveThumbnailResult{}, err
+	}
+	outputData, result, err := ExifRemoveThumbnailBytes(data, opts...)
+	if err != nil {
+		return "", result, err
+	}
+	return base64.StdEncoding.EncodeToString(outputData), result, nil
+}
+
+// ExifRemoveThumbnailDataURI is ExifRemoveThumbnailBytes for a data: URI such as
+// "data:image/jpeg;base64,...", as used by web frontends. Base64 and
+// percent-encoded URIs are accepted; the media type must be an image type.
+// The returned URI is base64-encoded and declares image/jpeg for JPEG data,
+// so mislabeled input is corrected; data of other registered formats keeps
+// its media type. WithMaxInputSize applies to the decoded size.
+func ExifRemoveThumbnailDataURI(uri string, opts ...Option) (string, ExifRemoveThumbnailResult, error) {
+	o := newOptions(opts)
+	rest, ok := strings.CutPrefix(uri, "data:")
+	if !ok {
+		return "", ExifRemoveThumbnailResult{}, &FormatError{Code: CodeInvalidEncoding, Offset: -1, msg: "not a data URI"}
+	}
+	header, payload, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", ExifRemoveThumbnailResult{}, &FormatError{Code: CodeInvalidEncoding, Offset: -1, msg: "data URI without data"}
+	}
+	params := strings.Split(header, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+	if !strings.HasPrefix(mediaType, "image/") {
+		return "", ExifRemoveThumbnailResult{}, &FormatError{Code: CodeUnsupported, Offset: -1, msg: fmt.Sprintf("unsupported media type %q", params[0])}
+	}
+	isBase64 := false
+	var kept []string
+	for _, p := range params[1:] {
+		if strings.EqualFold(strings.TrimSpace(p), "base64") {
+			isBase64 = true
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	var data []byte
+	if isBase64 {
+		var err error
+		if data, err = decodeBase64(payload, o); err != nil {
+			return "", ExifRemoveThumbnailResult{}, err
+		}
+	} else {
+		if o.maxInputSize > 0 && int64(len(payload)) > 3*o.maxInputSize {
+			return "", ExifRemoveThumbnailResult{}, ErrInputTooLarge
+		}
+		unescaped, err := url.PathUnescape(payload)
+		if err != nil {
+			return "", ExifRemoveThumbnailResult{}, &FormatError{Code: CodeInvalidEncoding, Offset: -1, msg: "invalid data URI: " + err.Error()}
+		}
+		data = []byte(unescaped)
+	}
+	outputData, result, err := ExifRemoveThumbnailBytes(data, opts...)
+	if err != nil {
+		return "", result, err
+	}
+	if result.Format == FormatJPEG {
+		mediaType = "image/jpeg"
+	}
+	header = strings.Join(append([]string{mediaType}, kept...), ";")
+	return "data:" + header + ";base64," + base64.StdEncoding.EncodeToString(outputData), result, nil
+}
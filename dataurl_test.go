@@ -0,0 +1,78 @@
+package exifremovethumbnail_test
+
+import (
+	"encoding/base64"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestDataURI(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	encoded := base64.StdEncoding.EncodeToString(inData)
+
+	t.Run("base64文字列", func(t *testing.T) {
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailBase64(encoded)
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, base64.StdEncoding.EncodeToString(want), out)
+	})
+
+	t.Run("URLセーフでパディングなしのbase64", func(t *testing.T) {
+		out, _, err := exifremovethumbnail.ExifRemoveThumbnailBase64(base64.RawURLEncoding.EncodeToString(inData))
+		require.NoError(t, err)
+		require.Equal(t, base64.StdEncoding.EncodeToString(want), out)
+	})
+
+	t.Run("data URI", func(t *testing.T) {
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailDataURI("data:image/jpeg;base64," + encoded)
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, "data:image/jpeg;base64,"+base64.StdEncoding.EncodeToString(want), out)
+	})
+
+	t.Run("メディアタイプを実際の形式に直す", func(t *testing.T) {
+		out, _, err := exifremovethumbnail.ExifRemoveThumbnailDataURI("data:image/png;name=photo.jpg;base64," + encoded)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(out, "data:image/jpeg;name=photo.jpg;base64,"))
+	})
+
+	t.Run("パーセントエンコードのdata URI", func(t *testing.T) {
+		out, _, err := exifremovethumbnail.ExifRemoveThumbnailDataURI("data:image/jpeg," + url.PathEscape(string(inData)))
+		require.NoError(t, err)
+		require.Equal(t, "data:image/jpeg;base64,"+base64.StdEncoding.EncodeToString(want), out)
+	})
+
+	t.Run("画像以外や不正な入力はFormatError", func(t *testing.T) {
+		var formatErr *exifremovethumbnail.FormatError
+		for _, uri := range []string{
+			"data:text/plain;base64," + encoded,
+			"https://example.com/photo.jpg",
+			"data:image/jpeg;base64",
+			"data:image/jpeg;base64,!!!",
+		} {
+			_, _, err := exifremovethumbnail.ExifRemoveThumbnailDataURI(uri)
+			require.ErrorAs(t, err, &formatErr, uri)
+		}
+	})
+
+	t.Run("デコード前にサイズ上限を確認する", func(t *testing.T) {
+		limit := exifremovethumbnail.WithMaxInputSize(int64(len(inData)) - 1)
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBase64(encoded, limit)
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInputTooLarge)
+		_, _, err = exifremovethumbnail.ExifRemoveThumbnailDataURI("data:image/jpeg;base64,"+encoded, limit)
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInputTooLarge)
+
+		_, _, err = exifremovethumbnail.ExifRemoveThumbnailBase64(encoded, exifremovethumbnail.WithMaxInputSize(int64(len(inData))))
+		require.NoError(t, err)
+	})
+}
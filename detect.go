@@ -0,0 +1,108 @@
+package exifremovethumbnail
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+// ThumbnailInfo describes an EXIF thumbnail found by DetectThumbnail.
+type ThumbnailInfo struct {
+	// Size is the number of bytes removing the thumbnail reclaims from the EXIF data.
+	Size        int64
+	Width       int
+	Height      int
+	Compression ThumbnailCompression
+	// Offset is the byte offset of the thumbnail image in the file (-1 if unknown).
+	Offset int64
+}
+
+// DetectThumbnail reports whether the JPEG file read from r has an EXIF thumbnail.
+// Only the marker headers up to SOS and the EXIF segments are read; other
+// segment payloads are skipped and the image data is never touched.
+func DetectThumbnail(r io.ReaderAt) (info ThumbnailInfo, found bool, err error) {
+	const markerAPP1 = spec.APP1
+	const markerSOS = spec.SOS
+
+	pos := int64(2)
+	defer recoverPanic(&err, func() int64 { return pos })
+
+	header := make([]byte, 4)
+	if _, err := r.ReadAt(header[:2], 0); err != nil || binary.BigEndian.Uint16(header) != spec.SOI {
+		return ThumbnailInfo{}, false, &FormatError{Code: CodeNotJPEG, Offset: 0, msg: "not a valid JPEG file", err: ErrNotJPEG}
+	}
+	for {
+		if _, err := r.ReadAt(header[:2], pos); err != nil {
+			if err == io.EOF {
+				return ThumbnailInfo{}, false, nil
+			}
+			return ThumbnailInfo{}, false, fmt.Errorf("failed to read marker: %w", err)
+		}
+		marker := binary.BigEndian.Uint16(header)
+		if marker == 0xFFFF {
+			// A 0xFF fill byte before the marker.
+			pos++
+			continue
+		}
+		if marker&0xFF00 != 0xFF00 {
+			return ThumbnailInfo{}, false, &FormatError{Code: CodeInvalidMarker, Offset: pos, msg: "invalid JPEG marker"}
+		}
+		if marker == markerSOS {
+			return ThumbnailInfo{}, false, nil
+		}
+		if _, err := r.ReadAt(header[2:4], pos+2); err != nil {
+			if truncated(err) {
+				return ThumbnailInfo{}, false, &SegmentError{Marker: marker, Offset: pos, Length: -1, Reason: "missing length field"}
+			}
+			return ThumbnailInfo{}, false, fmt.Errorf("failed to read segment length: %w", err)
+		}
+		segmentLength := int64(binary.BigEndian.Uint16(header[2:4]))
+		if segmentLength < 2 {
+			return ThumbnailInfo{}, false, &SegmentError{Marker: marker, Offset: pos, Length: int(segmentLength),
+				Reason: fmt.Sprintf("length %d is less than 2", segmentLength)}
+		}
+		if marker == markerAPP1 {
+			segmentData := make([]byte, segmentLength-2)
+			if _, err := r.ReadAt(segmentData, pos+4); err != nil {
+				if truncated(err) {
+					return ThumbnailInfo{}, false, &SegmentError{Marker: marker, Offset: pos, Length: int(segmentLength),
+						Reason: fmt.Sprintf("length %d overruns the data", segmentLength)}
+				}
+				return ThumbnailInfo{}, false, fmt.Errorf("failed to read segment data: %w", err)
+			}
+			if isExifSegment(segmentData) {
+				_, exifRes, err := removeThumbnailFromExif(segmentData, &options{})
+				if err != nil {
+					return ThumbnailInfo{}, false, &FormatError{Code: CodeInvalidExif, Offset: pos, msg: "failed to read EXIF thumbnail: " + err.Error(), err: err}
+				}
+				if exifRes.hadThumbnail {
+					info := ThumbnailInfo{
+						Size:        exifRes.thumbnailSize,
+						Width:       exifRes.thumbnail.width,
+						Height:      exifRes.thumbnail.height,
+						Compression: exifRes.thumbnail.compression,
+						Offset:      -1,
+					}
+					if exifRes.thumbnail.offset >= 0 {
+						info.Offset = pos + 4 + int64(len(exifHeader)) + exifRes.thumbnail.offset
+					}
+					return info, true, nil
+				}
+			}
+		}
+		pos += 2 + segmentLength
+	}
+}
+
+// DetectThumbnailFile is DetectThumbnail for the file at path.
+func DetectThumbnailFile(path string) (ThumbnailInfo, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ThumbnailInfo{}, false, fmt.Errorf("failed to read input file: %w", err)
+	}
+	defer f.Close()
+	return DetectThumbnail(f)
+}
@@ -0,0 +1,68 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+// recordingReaderAt は読まれた最大位置を記録する
+type recordingReaderAt struct {
+	r    *bytes.Reader
+	last int64
+}
+
+func (r *recordingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.r.ReadAt(p, off)
+	r.last = max(r.last, off+int64(n))
+	return n, err
+}
+
+func TestDetectThumbnail(t *testing.T) {
+	t.Run("サムネイルを検出しSOS以降は読まない", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+
+		r := &recordingReaderAt{r: bytes.NewReader(inData)}
+		info, ok, err := exifremovethumbnail.DetectThumbnail(r)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, result.ThumbnailSize, info.Size)
+		require.Equal(t, 160, info.Width)
+		require.Equal(t, 120, info.Height)
+		require.Equal(t, exifremovethumbnail.ThumbnailJPEG, info.Compression)
+		require.Equal(t, result.ThumbnailOffset, info.Offset)
+		require.Less(t, r.last, int64(10000), "画像データまで読むべきではない")
+	})
+
+	t.Run("サムネイルなし", func(t *testing.T) {
+		for _, name := range []string{"thumbnail_none.jpg", "metadata_full_exif.jpg"} {
+			inData, err := os.ReadFile(filepath.Join("testdata", name))
+			require.NoError(t, err)
+			r := &recordingReaderAt{r: bytes.NewReader(inData)}
+			_, ok, err := exifremovethumbnail.DetectThumbnail(r)
+			require.NoError(t, err)
+			require.False(t, ok, name)
+			require.Less(t, r.last, int64(len(inData)), name)
+		}
+	})
+
+	t.Run("ファイルから検出", func(t *testing.T) {
+		_, ok, err := exifremovethumbnail.DetectThumbnailFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+
+	t.Run("フォーマットエラー", func(t *testing.T) {
+		_, _, err := exifremovethumbnail.DetectThumbnailFile(filepath.Join("testdata", "actual_png.jpg"))
+		_, ok := err.(*exifremovethumbnail.FormatError)
+		require.True(t, ok, "FormatErrorであるべき")
+	})
+}
@@ -0,0 +1,57 @@
+package exifremovethumbnail
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrInsufficientSpace is returned by CheckFreeSpace and Overlay.Preflight when
+// the destination file system cannot hold the output.
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// freeSpace returns the bytes available to the user on the file system holding
+// dir, or -1 if it cannot be determined on this platform. Tests replace it.
+var freeSpace = statFreeSpace
+
+// RequiredSpace returns the space the processed files take, the sum of Size
+// minus Savings, for offenders found by TopOffenders with n <= 0.
+func RequiredSpace(offenders []Offender) int64 {
+	var need int64
+	for _, off := range offenders {
+		need += off.Size - off.Savings
+	}
+	return need
+}
+
+// CheckFreeSpace fails with ErrInsufficientSpace unless the file system holding
+// dir has more than need bytes available. dir does not have to exist yet; its
+// nearest existing parent is checked. On platforms where the free space cannot
+// be determined the check passes.
+func CheckFreeSpace(dir string, need int64) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	free, err := freeSpace(dir)
+	if err != nil {
+		return fmt.Errorf("failed to get free space of %s: %w", dir, err)
+	}
+	if free >= 0 && free <= need {
+		return fmt.Errorf("%w: %s has %d bytes available, %d needed", ErrInsufficientSpace, dir, free, need)
+	}
+	return nil
+}
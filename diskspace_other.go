@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package exifremovethumbnail
+
+// statFreeSpace reports that the free space is unknown on this platform.
+func statFreeSpace(dir string) (int64, error) {
+	return -1, nil
+}
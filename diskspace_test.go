@@ -0,0 +1,75 @@
+package exifremovethumbnail_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestFreeSpace(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+
+	// setup は原本二つのツリーとまだ存在しないオーバーレイを用意する
+	setup := func(t *testing.T) exifremovethumbnail.Overlay {
+		ov := exifremovethumbnail.Overlay{Root: t.TempDir(), Dir: filepath.Join(t.TempDir(), "overlay", "nested")}
+		require.NoError(t, os.WriteFile(filepath.Join(ov.Root, "a.jpg"), inData, 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(ov.Root, "b.jpg"), inData, 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(ov.Root, "notes.txt"), []byte("not an image"), 0644))
+		return ov
+	}
+
+	t.Run("監査結果から処理後の合計サイズを見積もる", func(t *testing.T) {
+		ov := setup(t)
+		offenders, err := exifremovethumbnail.TopOffenders(os.DirFS(ov.Root), 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(2*len(want)), exifremovethumbnail.RequiredSpace(offenders))
+	})
+
+	t.Run("空き容量が足りなければ処理前に失敗する", func(t *testing.T) {
+		ov := setup(t)
+		var checked string
+		defer exifremovethumbnail.SetFreeSpace(func(dir string) (int64, error) {
+			checked = dir
+			return int64(2*len(want)) - 1, nil
+		})()
+		err := ov.Preflight()
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInsufficientSpace)
+		require.Equal(t, filepath.Dir(filepath.Dir(ov.Dir)), checked, "存在する最も近い親を調べるべき")
+	})
+
+	t.Run("空き容量が十分なら成功する", func(t *testing.T) {
+		ov := setup(t)
+		defer exifremovethumbnail.SetFreeSpace(func(dir string) (int64, error) {
+			return int64(2*len(want)) + 1, nil
+		})()
+		require.NoError(t, ov.Preflight())
+	})
+
+	t.Run("サムネイルを残すポリシーでは必要な容量が増える", func(t *testing.T) {
+		ov := setup(t)
+		defer exifremovethumbnail.SetFreeSpace(func(dir string) (int64, error) {
+			return int64(2*len(want)) + 1, nil
+		})()
+		require.ErrorIs(t, ov.Preflight(exifremovethumbnail.WithPolicy(exifremovethumbnail.Policy{})), exifremovethumbnail.ErrInsufficientSpace)
+	})
+
+	t.Run("空き容量が分からなければ確認を省く", func(t *testing.T) {
+		defer exifremovethumbnail.SetFreeSpace(func(dir string) (int64, error) {
+			return -1, nil
+		})()
+		require.NoError(t, exifremovethumbnail.CheckFreeSpace(t.TempDir(), math.MaxInt64))
+	})
+
+	t.Run("実際のファイルシステムを調べる", func(t *testing.T) {
+		require.NoError(t, exifremovethumbnail.CheckFreeSpace(t.TempDir(), 0))
+		require.ErrorIs(t, exifremovethumbnail.CheckFreeSpace(t.TempDir(), math.MaxInt64), exifremovethumbnail.ErrInsufficientSpace)
+	})
+}
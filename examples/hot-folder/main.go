@@ -0,0 +1,139 @@
+// Command hot-folder watches a directory and removes EXIF thumbnails from the
+// JPEG files dropped into it.
+//
+// Usage:
+//
+//	hot-folder -in inbox -out outbox [-done done] [-failed failed] [-interval 5s] [-settle 10s] [-policy policy.json]
+//
+// The inbox is polled rather than watched through OS notifications, which
+// keeps the example portable and works on network shares. A file is picked
+// up once it has not been modified for the settle time, so half-copied files
+// are left alone. The cleaned file is written to the outbox and the original
+// is moved to the done directory; files that cannot be processed are moved to
+// the failed directory. Without -done, originals are removed once processed.
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func main() {
+	in := flag.String("in", "", "directory to watch")
+	out := flag.String("out", "", "directory receiving the cleaned files")
+	done := flag.String("done", "", "directory receiving the originals (removed if empty)")
+	failed := flag.String("failed", "", "directory receiving the files that failed (default: IN/failed)")
+	interval := flag.Duration("interval", 5*time.Second, "how often the directory is scanned")
+	settle := flag.Duration("settle", 10*time.Second, "how long a file must be unmodified before it is processed")
+	policyPath := flag.String("policy", "", "policy file (JSON)")
+	flag.Parse()
+
+	if *in == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *failed == "" {
+		*failed = filepath.Join(*in, "failed")
+	}
+	for _, dir := range []string{*out, *done, *failed} {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	opts := []exifremovethumbnail.Option{exifremovethumbnail.WithVerifyImageDataUnchanged()}
+	if *policyPath != "" {
+		policy, err := exifremovethumbnail.LoadPolicyFile(*policyPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, exifremovethumbnail.WithPolicy(policy))
+	}
+
+	f := folder{in: *in, out: *out, done: *done, failed: *failed, settle: *settle, opts: opts}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	log.Printf("watching %s", *in)
+	for {
+		f.scan(time.Now())
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// folder holds the directories and options of a hot folder.
+type folder struct {
+	in, out, done, failed string
+	settle                time.Duration
+	opts                  []exifremovethumbnail.Option
+}
+
+// scan processes every settled JPEG file in the inbox.
+func (f folder) scan(now time.Time) {
+	entries, err := os.ReadDir(f.in)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !isJPEGName(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) < f.settle {
+			continue
+		}
+		f.process(e.Name(), info.Size())
+	}
+}
+
+// process cleans one file and moves the original out of the inbox.
+func (f folder) process(name string, size int64) {
+	src := filepath.Join(f.in, name)
+	if err := exifremovethumbnail.CheckFreeSpace(f.out, size); err != nil {
+		// Leave the file in the inbox and try again on the next scan.
+		log.Printf("%s: %v", name, err)
+		return
+	}
+	result, err := exifremovethumbnail.ExifRemoveThumbnail(src, filepath.Join(f.out, name), f.opts...)
+	if err != nil {
+		log.Printf("%s: %v", name, err)
+		if err := os.Rename(src, filepath.Join(f.failed, name)); err != nil {
+			log.Print(err)
+		}
+		return
+	}
+	log.Printf("%s: %d -> %d bytes", name, result.BeforeSize, result.AfterSize)
+	if f.done == "" {
+		err = os.Remove(src)
+	} else {
+		err = os.Rename(src, filepath.Join(f.done, name))
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Print(err)
+	}
+}
+
+// isJPEGName reports whether name has a JPEG file extension.
+func isJPEGName(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".jpe":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+// Command s3-sweeper removes EXIF thumbnails from the JPEG objects of a bucket.
+//
+// Usage:
+//
+//	s3-sweeper -bucket DIR [-prefix photos/] [-dest cleaned/] [-dry-run] [-policy policy.json]
+//
+// The sweeper is written against the small Bucket interface below so that it
+// builds without a cloud SDK. The bundled implementation serves a local
+// directory, such as a bucket mounted with mountpoint-s3 or s3fs, or a copy
+// for trying the sweep out. To talk to S3 directly, implement Bucket with
+// aws-sdk-go-v2: List with ListObjectsV2, ReaderAt with ranged GetObject
+// requests ("Range: bytes=off-end"), Get with GetObject and Put with
+// PutObject.
+//
+// Each object is first checked with DetectThumbnail through ReaderAt, which
+// only reads the headers in front of the image data, so objects without a
+// thumbnail cost a few small range requests instead of a full download. Only
+// objects with a thumbnail are downloaded, cleaned and uploaded, either over
+// the original key or under -dest.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+// Bucket is the subset of an object store the sweeper needs.
+type Bucket interface {
+	// List calls fn for every object whose key starts with prefix.
+	List(ctx context.Context, prefix string, fn func(key string, size int64) error) error
+	// ReaderAt returns a reader serving ranged reads of the object.
+	ReaderAt(ctx context.Context, key string) io.ReaderAt
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+func main() {
+	dir := flag.String("bucket", "", "directory standing in for the bucket")
+	prefix := flag.String("prefix", "", "only sweep keys starting with this prefix")
+	dest := flag.String("dest", "", "prefix the cleaned objects are written under (default: overwrite)")
+	dryRun := flag.Bool("dry-run", false, "only report the objects with a thumbnail")
+	policyPath := flag.String("policy", "", "policy file (JSON)")
+	flag.Parse()
+
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	opts := []exifremovethumbnail.Option{exifremovethumbnail.WithVerifyImageDataUnchanged()}
+	if *policyPath != "" {
+		policy, err := exifremovethumbnail.LoadPolicyFile(*policyPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, exifremovethumbnail.WithPolicy(policy))
+	}
+
+	s := sweeper{bucket: dirBucket(*dir), dest: *dest, dryRun: *dryRun, opts: opts}
+	if err := s.sweep(context.Background(), *prefix); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d objects, %d with a thumbnail, %d bytes reclaimed\n", s.objects, s.found, s.saved)
+}
+
+// sweeper holds the settings and totals of a sweep.
+type sweeper struct {
+	bucket Bucket
+	dest   string
+	dryRun bool
+	opts   []exifremovethumbnail.Option
+
+	objects, found int
+	saved          int64
+}
+
+// sweep cleans every JPEG object under prefix. Errors on single objects are
+// logged and do not stop the sweep.
+func (s *sweeper) sweep(ctx context.Context, prefix string) error {
+	return s.bucket.List(ctx, prefix, func(key string, size int64) error {
+		if !isJPEGKey(key) {
+			return nil
+		}
+		s.objects++
+		info, found, err := exifremovethumbnail.DetectThumbnail(s.bucket.ReaderAt(ctx, key))
+		if err != nil {
+			log.Printf("%s: %v", key, err)
+			return nil
+		}
+		if !found {
+			return nil
+		}
+		s.found++
+		if s.dryRun {
+			fmt.Printf("%10d  %s\n", info.Size, key)
+			s.saved += info.Size
+			return nil
+		}
+		if err := s.clean(ctx, key); err != nil {
+			log.Printf("%s: %v", key, err)
+		}
+		return nil
+	})
+}
+
+// clean downloads, cleans and uploads one object.
+func (s *sweeper) clean(ctx context.Context, key string) error {
+	data, err := s.bucket.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	out, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(data, s.opts...)
+	if err != nil {
+		return err
+	}
+	if result.Unchanged || result.Skipped {
+		return nil
+	}
+	if err := s.bucket.Put(ctx, s.dest+key, out); err != nil {
+		return err
+	}
+	s.saved += result.BeforeSize - result.AfterSize
+	fmt.Printf("%10d  %s\n", result.BeforeSize-result.AfterSize, key)
+	return nil
+}
+
+// isJPEGKey reports whether key has a JPEG file extension.
+func isJPEGKey(key string) bool {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".jpg", ".jpeg", ".jpe":
+		return true
+	}
+	return false
+}
+
+// dirBucket is a Bucket backed by a local directory. Keys are slash-separated
+// paths relative to it.
+type dirBucket string
+
+func (b dirBucket) List(ctx context.Context, prefix string, fn func(key string, size int64) error) error {
+	return fs.WalkDir(os.DirFS(string(b)), ".", func(key string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(key, info.Size())
+	})
+}
+
+func (b dirBucket) ReaderAt(ctx context.Context, key string) io.ReaderAt {
+	return fileReaderAt(b.path(key))
+}
+
+func (b dirBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	return os.ReadFile(b.path(key))
+}
+
+func (b dirBucket) Put(ctx context.Context, key string, data []byte) error {
+	p := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	// Write a temporary file and rename it, as an object store replaces an
+	// object atomically.
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (b dirBucket) path(key string) string {
+	return filepath.Join(string(b), filepath.FromSlash(key))
+}
+
+// fileReaderAt opens the file for every read, like a ranged GET request.
+type fileReaderAt string
+
+func (f fileReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return file.ReadAt(p, off)
+}
@@ -0,0 +1,76 @@
+// Command upload-proxy is a reverse proxy that removes EXIF thumbnails from
+// JPEG uploads before they reach the backend.
+//
+// Usage:
+//
+//	upload-proxy -listen :8080 -backend http://localhost:9000 [-policy policy.json] [-max-size 52428800]
+//
+// Request bodies sent as image/jpeg are streamed through NewReader, so an
+// upload is never held in memory as a whole; other requests are forwarded
+// untouched. A body that turns out not to be a valid JPEG, or is larger than
+// -max-size, aborts the request and the client receives 502 Bad Gateway.
+package main
+
+import (
+	"flag"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to listen on")
+	backend := flag.String("backend", "", "URL of the backend receiving the uploads")
+	policyPath := flag.String("policy", "", "policy file (JSON)")
+	maxSize := flag.Int64("max-size", 50<<20, "largest upload accepted, in bytes")
+	flag.Parse()
+
+	if *backend == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	target, err := url.Parse(*backend)
+	if err != nil {
+		log.Fatalf("invalid backend URL: %v", err)
+	}
+	opts := []exifremovethumbnail.Option{exifremovethumbnail.WithMaxInputSize(*maxSize)}
+	if *policyPath != "" {
+		policy, err := exifremovethumbnail.LoadPolicyFile(*policyPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, exifremovethumbnail.WithPolicy(policy))
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		if !isJPEG(req) {
+			return
+		}
+		// The cleaned size is unknown until the whole body is read, so the
+		// upload is forwarded with chunked encoding.
+		req.Body = exifremovethumbnail.NewReader(req.Body, opts...)
+		req.ContentLength = -1
+		req.Header.Del("Content-Length")
+		req.Header.Del("Content-MD5")
+	}
+
+	log.Printf("forwarding %s to %s", *listen, target)
+	log.Fatal(http.ListenAndServe(*listen, proxy))
+}
+
+// isJPEG reports whether req carries a JPEG image as its body.
+func isJPEG(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && (mediaType == "image/jpeg" || mediaType == "image/pjpeg")
+}
@@ -1,182 +1,1223 @@
 // Package exifremovethumbnail provides functions to remove embedded thumbnails from JPEG EXIF metadata.
 // It preserves other EXIF data and outputs a new JPEG file without the thumbnail.
+//
+// Every function, and every method of Processor and Overlay, may be called
+// from many goroutines at once. State is kept per call; input slices are never
+// modified, so goroutines may share them; the only shared state, the pooled
+// buffers and the registries of RegisterFormat, is synchronized. Callbacks
+// passed as options, such as WithSkipHandler, must be safe for concurrent use
+// when the options are shared. Overlay.Promote must not run while files are
+// processed into the same overlay.
 package exifremovethumbnail
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"os"
+	"time"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+	"github.com/ideamans/go-exif-remove-thumbnail/tiff"
 )
 
 // ExifRemoveThumbnailResult is the result of thumbnail removal from a JPEG file.
 // HadThumbnail is true if the original image contained a thumbnail.
 // BeforeSize and AfterSize are the file sizes before and after processing.
 // ThumbnailSize is the size of the removed thumbnail in bytes (0 if none).
+// RemovedTags lists the tags removed by options that were actually present.
+// RemovedSegments maps the names of removed segments to their total size in bytes.
+// HadIPTC is true if the original image contained IPTC-NAA data in APP13,
+// and IPTCSize is the size of that data in bytes.
 type ExifRemoveThumbnailResult struct {
-	HadThumbnail  bool
-	BeforeSize    int64
-	AfterSize     int64
-	ThumbnailSize int64
+	// Format is FormatJPEG or the name of the registered handler that processed the input.
+	Format          string
+	HadThumbnail    bool
+	BeforeSize      int64
+	AfterSize       int64
+	ThumbnailSize   int64
+	RemovedTags     []TagRef
+	RemovedSegments map[Segment]int64
+	HadIPTC         bool
+	IPTCSize        int64
+	// ThumbnailWidth, ThumbnailHeight and ThumbnailCompression describe the
+	// thumbnail found in IFD1. ThumbnailOffset is its byte offset in the input
+	// (-1 if unknown). They are zero values when there is no thumbnail.
+	ThumbnailWidth       int
+	ThumbnailHeight      int
+	ThumbnailCompression ThumbnailCompression
+	ThumbnailOffset      int64
+	// Exif summarizes camera make/model, orientation and capture date of the input.
+	Exif ExifSummary
+	// Warnings lists non-fatal anomalies found while parsing, such as trailing
+	// data after EOI or suspicious offsets. Processing still succeeded.
+	Warnings []string
+	// PeakBufferedBytes is the largest number of bytes held in memory at once
+	// for a segment or the copy window, useful for capacity planning.
+	PeakBufferedBytes int64
+	// InputScanHash and OutputScanHash are hex SHA-256 digests of the SOS..EOI
+	// region (from the SOS marker through EOI) of the input and the output.
+	// Equal values show the entropy-coded image data was preserved byte for byte.
+	// They are empty when no SOS marker was reached.
+	InputScanHash  string
+	OutputScanHash string
+	// RetainedTags lists the EXIF tags left in the output when WithRetainedTagsReport is set.
+	RetainedTags []TagRef
+	// RiskyMakerNote is true when the input holds a MakerNote that relies on absolute
+	// offsets into the EXIF data and is kept by the options.
+	RiskyMakerNote bool
+	// Skipped is true when WithSkipRiskyMakerNote left the file unchanged, and
+	// SkipReason tells why.
+	Skipped    bool
+	SkipReason SkipReason
+	// Truncated is true when the input ends early: before the image data, inside
+	// a segment or without an EOI marker. See WithAllowTruncated.
+	Truncated bool
+	// Unchanged is true when the input needed no change, as when it was already
+	// processed: the output is the input itself and was never rebuilt, and
+	// ExifRemoveThumbnail does not rewrite a file onto itself. It is not
+	// detected with WithConformance or WithFaultInjection.
+	Unchanged bool
+	// ExifSegments is the number of EXIF APP1 segments in the input. More than
+	// one is a spec violation reported in Warnings; every segment is processed
+	// unless WithDedupeExif is set.
+	ExifSegments int
+}
+
+// Sentinel errors classifying failures. A *FormatError wraps the matching one,
+// so callers can branch with errors.Is instead of matching messages.
+var (
+	// ErrNotJPEG means the input is not JPEG data and no registered format handles it.
+	ErrNotJPEG = errors.New("not a valid JPEG file")
+	// ErrNoExif means EXIF data was expected but not found.
+	ErrNoExif = errors.New("no EXIF data")
+	// ErrTruncated means the data ends in the middle of a structure. It is
+	// the error of the tiff package, which reports short TIFF headers with it.
+	ErrTruncated = tiff.ErrTruncated
+	// ErrInvalidIFD means an IFD of the EXIF data cannot be parsed.
+	ErrInvalidIFD = tiff.ErrInvalidIFD
+)
+
+// ErrorCode is a machine-readable category of a FormatError, stable across
+// releases so that services can aggregate failures in telemetry.
+type ErrorCode string
+
+// Error codes of FormatError.
+const (
+	CodeNotJPEG              ErrorCode = "not_jpeg"
+	CodeInvalidMarker        ErrorCode = "invalid_marker"
+	CodeInvalidSegmentLength ErrorCode = "invalid_segment_length"
+	CodeTruncated            ErrorCode = "truncated"
+	CodeInvalidExif          ErrorCode = "invalid_exif"
+	CodeStrictViolation      ErrorCode = "strict_violation"
+	CodeInvalidEncoding      ErrorCode = "invalid_encoding"
+	CodeUnsupported          ErrorCode = "unsupported"
+	CodeInternal             ErrorCode = "internal"
+)
+
+// Error codes of failures other than FormatError, as classified by ErrorClass.
+const (
+	CodeIO            ErrorCode = "io"
+	CodeInputTooLarge ErrorCode = "input_too_large"
+	CodeVerification  ErrorCode = "verification_failed"
+	CodeOther         ErrorCode = "other"
+)
+
+// ErrorClass returns the category of an error returned by this package: the
+// Code of a FormatError, CodeInputTooLarge for ErrInputTooLarge,
+// CodeVerification for ErrImageDataChanged and ErrInvalidOutput, CodeIO for
+// file system errors and CodeOther for anything else. It returns "" for nil.
+func ErrorClass(err error) ErrorCode {
+	var formatErr *FormatError
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &formatErr):
+		return formatErr.Code
+	case errors.Is(err, ErrInputTooLarge):
+		return CodeInputTooLarge
+	case errors.Is(err, ErrImageDataChanged), errors.Is(err, ErrInvalidOutput):
+		return CodeVerification
+	case errors.As(err, &pathErr), errors.As(err, &linkErr):
+		return CodeIO
+	}
+	return CodeOther
 }
 
 // FormatError represents an error due to invalid or unsupported file format.
+// It wraps one of the sentinel errors such as ErrNotJPEG when one applies.
 type FormatError struct {
-	msg string
+	Code ErrorCode
+	// Offset is the position in the input where parsing failed, or -1 if the
+	// failure is not tied to a position.
+	Offset int64
+	msg    string
+	err    error
 }
 
 func (e *FormatError) Error() string {
 	return e.msg
 }
 
+func (e *FormatError) Unwrap() error {
+	return e.err
+}
+
+// SegmentError reports a marker segment with a malformed length field, such as
+// a length below 2 or a segment overrunning the end of the data.
+// It unwraps to a *FormatError.
+type SegmentError struct {
+	Marker uint16
+	// Offset is the position of the marker in the input.
+	Offset int64
+	// Length is the value of the length field, or -1 if it is missing.
+	Length int
+	Reason string
+}
+
+func (e *SegmentError) Error() string {
+	return fmt.Sprintf("invalid segment 0x%04X at offset %d: %s", e.Marker, e.Offset, e.Reason)
+}
+
+func (e *SegmentError) Unwrap() error {
+	// Lengths of 0 and 1 are invalid in themselves; any other length was cut short.
+	if e.Length < 0 || e.Length >= 2 {
+		return &FormatError{Code: CodeTruncated, Offset: e.Offset, msg: e.Error(), err: ErrTruncated}
+	}
+	return &FormatError{Code: CodeInvalidSegmentLength, Offset: e.Offset, msg: e.Error()}
+}
+
+// truncated reports whether err means that the data ended early.
+func truncated(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
 // ExifRemoveThumbnailBytes removes the EXIF thumbnail from JPEG data in memory.
 // It returns the modified JPEG data and information about the operation.
 // If no thumbnail exists, HadThumbnail will be false.
-func ExifRemoveThumbnailBytes(inputData []byte) ([]byte, ExifRemoveThumbnailResult, error) {
+// When nothing needs to change, Unchanged or Skipped is set in the result and
+// the returned slice is inputData itself rather than a copy.
+// Non-JPEG data is handed to a handler registered with RegisterFormat, if any detects it.
+func ExifRemoveThumbnailBytes(inputData []byte, opts ...Option) ([]byte, ExifRemoveThumbnailResult, error) {
+	return removeThumbnailBytes(inputData, newOptions(opts))
+}
+
+// removeThumbnailBytes is ExifRemoveThumbnailBytes with resolved options.
+func removeThumbnailBytes(inputData []byte, o *options) ([]byte, ExifRemoveThumbnailResult, error) {
+	if o.maxInputSize > 0 && int64(len(inputData)) > o.maxInputSize {
+		return nil, ExifRemoveThumbnailResult{BeforeSize: int64(len(inputData))}, ErrInputTooLarge
+	}
+	if !isJPEG(inputData) {
+		if h := lookupFormat(inputData); h != nil {
+			outputData, result, err := h.RemoveThumbnail(inputData)
+			result.Format = h.Name()
+			return outputData, result, err
+		}
+	}
+	start := time.Now()
+	var outputData []byte
 	var result ExifRemoveThumbnailResult
+	var err error
+	if o.mayBeUnchanged(inputData) {
+		// Compare the output with the input instead of building it; it is
+		// only built in a second pass when it turns out to differ.
+		m := &matchWriter{ref: inputData}
+		result, err = removeThumbnail(m, bytes.NewReader(inputData), o)
+		if err == nil && m.n == len(inputData) {
+			outputData = inputData
+			result.Unchanged = true
+		} else if errors.Is(err, errOutputDiffers) {
+			err = nil
+		}
+	}
+	if outputData == nil && err == nil {
+		output := bytes.NewBuffer(make([]byte, 0, len(inputData)))
+		result, err = removeThumbnail(output, bytes.NewReader(inputData), o)
+		outputData = output.Bytes()
+	}
 	result.BeforeSize = int64(len(inputData))
+	if err != nil {
+		return nil, result, err
+	}
+	if result.Skipped {
+		result.AfterSize = result.BeforeSize
+		return inputData, result, nil
+	}
+	if o.checkIntegrity {
+		if err := checkIntegrity(inputData, outputData, result, o); err != nil {
+			return nil, result, err
+		}
+	}
+	if o.conformance {
+		outputData = applyConformance(outputData, &result)
+	}
+	if o.verifyImageData {
+		// Hash the bytes actually returned rather than trusting the copy loop.
+		if got := ScanHash(outputData); got != result.InputScanHash {
+			return nil, result, fmt.Errorf("%w: input %s, output %s", ErrImageDataChanged, result.InputScanHash, got)
+		}
+	}
+	if o.validateOutput {
+		if err := validateOutput(outputData); err != nil {
+			return nil, result, err
+		}
+	}
+	if o.verifyPixels {
+		if reason := o.skipVerify(inputData, time.Since(start)); reason != "" {
+			result.Warnings = append(result.Warnings, "pixel verification skipped: "+reason)
+		} else if err := VerifyPixels(inputData, outputData); err != nil {
+			return nil, result, err
+		}
+	}
+	return outputData, result, nil
+}
+
+// countingWriter counts the bytes written and keeps the first write error.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+// ErrInputTooLarge is returned when the input exceeds the size set by WithMaxInputSize.
+var ErrInputTooLarge = errors.New("input exceeds the maximum size")
+
+// countingReader counts the bytes read.
+// When limit is positive, reading past limit bytes fails with ErrInputTooLarge.
+// Bytes given back with unread are returned again by the next reads.
+type countingReader struct {
+	r       io.Reader
+	n       int64
+	limit   int64
+	pending []byte
+}
+
+// unread pushes p back in front of the data not read yet.
+func (cr *countingReader) unread(p []byte) {
+	cr.pending = append(append([]byte{}, p...), cr.pending...)
+	cr.n -= int64(len(p))
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	if len(cr.pending) > 0 {
+		n := copy(p, cr.pending)
+		cr.pending = cr.pending[n:]
+		cr.n += int64(n)
+		return n, nil
+	}
+	if cr.limit > 0 {
+		// Read one byte beyond the limit to tell an input of exactly limit bytes from a larger one.
+		p = p[:min(int64(len(p)), cr.limit-cr.n+1)]
+	}
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	if cr.limit > 0 && cr.n > cr.limit {
+		cr.n--
+		return n - 1, ErrInputTooLarge
+	}
+	return n, err
+}
+
+// eoiTracker watches the copied image data for the first EOI marker.
+// end is the number of bytes up to and including EOI, or -1 if not seen yet.
+// When h is set, the bytes up to and including EOI are hashed.
+type eoiTracker struct {
+	n      int64
+	end    int64
+	prevFF bool
+	h      hash.Hash
+}
+
+func (e *eoiTracker) Write(p []byte) (int, error) {
+	if e.end < 0 {
+		upto := len(p)
+		if i := eoiIndex(p, e.prevFF); i >= 0 {
+			e.end = e.n + int64(i) + 1
+			upto = i + 1
+		} else if len(p) > 0 {
+			e.prevFF = p[len(p)-1] == 0xFF
+		}
+		if e.h != nil {
+			e.h.Write(p[:upto])
+		}
+	}
+	e.n += int64(len(p))
+	return len(p), nil
+}
+
+// untilEOIWriter passes the copied image data on up to and including the
+// first EOI marker and drops the rest, for WithStripTrailer.
+type untilEOIWriter struct {
+	w      io.Writer
+	done   bool
+	prevFF bool
+}
 
-	const markerSOI = 0xFFD8
-	const markerAPP1 = 0xFFE1
-	const markerSOS = 0xFFDA
+func (u *untilEOIWriter) Write(p []byte) (int, error) {
+	if u.done {
+		return len(p), nil
+	}
+	upto := len(p)
+	if i := eoiIndex(p, u.prevFF); i >= 0 {
+		u.done = true
+		upto = i + 1
+	} else if len(p) > 0 {
+		u.prevFF = p[len(p)-1] == 0xFF
+	}
+	if _, err := u.w.Write(p[:upto]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
 
-	if len(inputData) < 2 || binary.BigEndian.Uint16(inputData[0:2]) != markerSOI {
-		return nil, result, &FormatError{"not a valid JPEG file"}
+// eoiIndex returns the index of the 0xD9 byte of the first EOI marker in p, or
+// -1 if there is none. prevFF tells whether the byte before p was 0xFF. Only
+// the 0xFF bytes are visited, which bytes.IndexByte finds without looking at
+// every byte of the image data in turn.
+func eoiIndex(p []byte, prevFF bool) int {
+	if prevFF && len(p) > 0 && p[0] == 0xD9 {
+		return 0
+	}
+	for from := 0; ; {
+		i := bytes.IndexByte(p[from:], 0xFF)
+		if i < 0 {
+			return -1
+		}
+		i += from
+		if i+1 < len(p) && p[i+1] == 0xD9 {
+			return i + 1
+		}
+		from = i + 1
+	}
+}
+
+// removeThumbnail streams JPEG data from r to w, removing the EXIF thumbnail on the way.
+// Only one segment is held in memory at a time; everything after SOS is copied as is.
+// A panic in the parsing code is returned as a *FormatError.
+func removeThumbnail(w io.Writer, r io.Reader, o *options) (result ExifRemoveThumbnailResult, err error) {
+	const markerSOI = spec.SOI
+	const markerAPP1 = spec.APP1
+	const markerSOS = spec.SOS
+	const markerCOM = spec.COM
+
+	switch o.fault {
+	case FaultRead:
+		r = &faultReader{r: r, n: o.faultAfter}
+	case FaultWrite:
+		w = &faultWriter{w: w, n: o.faultAfter}
+	}
+	// Headers are read without a lookahead buffer so that at most one segment
+	// or one copy window is held in memory at a time.
+	reader := &countingReader{r: r, limit: o.maxInputSize}
+	output := &countingWriter{w: w}
+	defer recoverPanic(&err, func() int64 {
+		result.BeforeSize = reader.n
+		result.AfterSize = output.n
+		return reader.n
+	})
+	scratch := segmentPool.Get().(*[]byte)
+	defer segmentPool.Put(scratch)
+	track := func(n int) {
+		result.PeakBufferedBytes = max(result.PeakBufferedBytes, int64(n))
+	}
+	// finish fills in the sizes known so far, also on error.
+	finish := func(err error) (ExifRemoveThumbnailResult, error) {
+		result.BeforeSize = reader.n
+		result.AfterSize = output.n
+		if err == nil && output.err != nil {
+			err = fmt.Errorf("failed to write output: %w", output.err)
+		}
+		return result, err
 	}
 
-	output := &bytes.Buffer{}
-	reader := bytes.NewReader(inputData)
 	soi := make([]byte, 2)
-	reader.Read(soi)
+	if _, err := io.ReadFull(reader, soi); err != nil && !truncated(err) {
+		return finish(fmt.Errorf("failed to read marker: %w", err))
+	} else if err != nil || binary.BigEndian.Uint16(soi) != markerSOI {
+		return finish(&FormatError{Code: CodeNotJPEG, Offset: 0, msg: "not a valid JPEG file", err: ErrNotJPEG})
+	}
 	output.Write(soi)
+	result.Format = FormatJPEG
 
 	thumbnailSize := int64(0)
 	foundThumbnail := false
+	wroteOrientation := false
+	wroteICC := false
+	metadataSize := int64(0)
+	// strictErr is the first spec violation found in ParseStrict.
+	var strictErr error
+	// violation records a spec violation as a warning, or as the error in ParseStrict.
+	violation := func(msg string) {
+		result.Warnings = append(result.Warnings, msg)
+		if o.parseMode == ParseStrict && strictErr == nil {
+			strictErr = &FormatError{Code: CodeStrictViolation, Offset: -1, msg: "strict: " + msg}
+		}
+	}
+	// recoverTruncation is set when input ending early is processed as far as it goes.
+	recoverTruncation := o.parseMode == ParseLenient || o.allowTruncated
+	// truncation records that the input ends early: as a warning with
+	// WithAllowTruncated, which accepts it in every parse mode, else as a violation.
+	truncation := func(msg string) {
+		result.Truncated = true
+		if o.allowTruncated {
+			result.Warnings = append(result.Warnings, msg)
+			return
+		}
+		violation(msg)
+	}
+	// noteExif records the summary and thumbnail of an EXIF segment whose payload starts at payloadStart.
+	noteExif := func(exifRes exifResult, payloadStart int64) {
+		if result.Exif == (ExifSummary{}) {
+			result.Exif = exifRes.summary
+		}
+		for _, w := range exifRes.warnings {
+			violation(w)
+		}
+		result.Warnings = append(result.Warnings, exifRes.notes...)
+		if !exifRes.hadThumbnail {
+			return
+		}
+		// With several EXIF segments the sizes add up; the details are of the first thumbnail.
+		thumbnailSize += exifRes.thumbnailSize
+		if foundThumbnail {
+			return
+		}
+		foundThumbnail = true
+		result.ThumbnailWidth = exifRes.thumbnail.width
+		result.ThumbnailHeight = exifRes.thumbnail.height
+		result.ThumbnailCompression = exifRes.thumbnail.compression
+		result.ThumbnailOffset = -1
+		if off := exifRes.thumbnail.offset; off >= 0 {
+			// In extended EXIF every continuation segment adds its marker, length and header.
+			const chunk = int64(maxSegmentPayload - len(exifHeader))
+			result.ThumbnailOffset = payloadStart + int64(len(exifHeader)) + off + off/chunk*int64(4+len(exifHeader))
+		}
+	}
+	// writeExif writes an EXIF segment and notes the tags it retains.
+	writeExif := func(marker uint16, exifData []byte) {
+		writeSegment(output, marker, exifData)
+		if o.reportRetained {
+			if t, err := parseTIFF(exifData[len(exifHeader):]); err == nil {
+				result.RetainedTags = append(result.RetainedTags, t.tagRefs()...)
+			}
+		}
+	}
+	dropped := func(name Segment, segmentData []byte) {
+		if result.RemovedSegments == nil {
+			result.RemovedSegments = map[Segment]int64{}
+		}
+		result.RemovedSegments[name] += int64(len(segmentData) + 4)
+	}
 
-	for {
+	for output.err == nil && strictErr == nil {
+		markerOffset := reader.n
 		var marker uint16
 		err := binary.Read(reader, binary.BigEndian, &marker)
 		if err == io.EOF {
+			truncation("JPEG data ends before the image data")
 			break
 		}
+		if err == io.ErrUnexpectedEOF && !recoverTruncation {
+			result.Truncated = true
+			return finish(&FormatError{Code: CodeTruncated, Offset: markerOffset, msg: "truncated JPEG marker", err: ErrTruncated})
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return finish(fmt.Errorf("failed to read marker: %w", err))
+		}
 		if err != nil {
-			return nil, result, fmt.Errorf("failed to read marker: %w", err)
+			truncation("truncated JPEG marker dropped")
+			break
+		}
+		if marker == 0xFFFF {
+			// Any number of 0xFF fill bytes may precede a marker; they are not copied.
+			marker, err = skipFillBytes(reader)
+			if truncated(err) && recoverTruncation {
+				truncation("truncated JPEG marker dropped")
+				break
+			}
+			if truncated(err) {
+				result.Truncated = true
+				return finish(&FormatError{Code: CodeTruncated, Offset: markerOffset, msg: "truncated JPEG marker", err: ErrTruncated})
+			}
+			if err != nil {
+				return finish(fmt.Errorf("failed to read marker: %w", err))
+			}
+		}
+		if o.parseMode == ParseLenient && (marker&0xFF00 != 0xFF00 || marker == 0xFF00 || marker == 0xFFFF) {
+			var garbage int
+			marker, garbage, err = resyncMarker(reader, marker)
+			if err != nil && !truncated(err) {
+				return finish(fmt.Errorf("failed to read marker: %w", err))
+			}
+			if err != nil {
+				truncation("no JPEG marker found before the end of data")
+				break
+			}
+			if garbage > 0 {
+				violation(fmt.Sprintf("%d bytes of garbage before marker 0x%04X", garbage, marker))
+			}
 		}
 		if marker&0xFF00 != 0xFF00 {
-			return nil, result, &FormatError{"invalid JPEG marker"}
+			return finish(&FormatError{Code: CodeInvalidMarker, Offset: markerOffset, msg: "invalid JPEG marker"})
+		}
+		if o.replaceICC != nil && !wroteICC && !isAPPn(marker) && !result.Skipped {
+			// No ICC segment was found among the application segments; insert it after them.
+			writeICCSegments(output, o.replaceICC)
+			wroteICC = true
 		}
 		if marker == markerSOS {
-			binary.Write(output, binary.BigEndian, marker)
-			remaining, _ := io.ReadAll(reader)
-			output.Write(remaining)
+			// The SOS..EOI region is hashed on both sides: eoi sees the bytes as
+			// read from the input, outScan the bytes as written to the output.
+			eoi := &eoiTracker{end: -1, h: sha256.New()}
+			outScan := &eoiTracker{end: -1, h: sha256.New()}
+			if pad := reader.n - 2 - output.n; o.constantSize && pad > 0 {
+				output.Write(padding(pad))
+			}
+			sos := []byte{0xFF, 0xDA}
+			eoi.Write(sos)
+			io.MultiWriter(output, outScan).Write(sos)
+			track(o.windowSize)
+			var scanOutput io.Writer = io.MultiWriter(output, outScan)
+			stripTrailer := o.stripTrailer && !o.constantSize
+			if stripTrailer {
+				scanOutput = &untilEOIWriter{w: scanOutput}
+			}
+			window, release := getWindow(o.windowSize)
+			_, err := io.CopyBuffer(scanOutput, io.TeeReader(reader, eoi), window)
+			release()
+			if err != nil && output.err == nil {
+				return finish(fmt.Errorf("failed to read image data: %w", err))
+			}
+			result.InputScanHash = hex.EncodeToString(eoi.h.Sum(nil))
+			result.OutputScanHash = hex.EncodeToString(outScan.h.Sum(nil))
+			if eoi.end < 0 {
+				truncation("missing EOI marker")
+			} else if eoi.n > eoi.end {
+				violation(fmt.Sprintf("%d bytes of trailing data after EOI", eoi.n-eoi.end))
+				if stripTrailer {
+					if result.RemovedSegments == nil {
+						result.RemovedSegments = map[Segment]int64{}
+					}
+					result.RemovedSegments[SegmentTrailer] += eoi.n - eoi.end
+				}
+			}
 			break
 		}
+		markerOffset = reader.n - 2
 		var segmentLength uint16
 		err = binary.Read(reader, binary.BigEndian, &segmentLength)
+		if err != nil && truncated(err) && !recoverTruncation {
+			result.Truncated = true
+			return finish(&SegmentError{Marker: marker, Offset: markerOffset, Length: -1, Reason: "missing length field"})
+		}
+		if err != nil && truncated(err) {
+			truncation(fmt.Sprintf("truncated segment 0x%04X dropped", marker))
+			break
+		}
 		if err != nil {
-			return nil, result, fmt.Errorf("failed to read segment length: %w", err)
+			return finish(fmt.Errorf("failed to read segment length: %w", err))
+		}
+		if segmentLength < 2 {
+			if o.parseMode != ParseLenient {
+				return finish(&SegmentError{Marker: marker, Offset: markerOffset, Length: int(segmentLength),
+					Reason: fmt.Sprintf("length %d is less than 2", segmentLength)})
+			}
+			violation(fmt.Sprintf("invalid segment length %d of marker 0x%04X skipped", segmentLength, marker))
+			continue
+		}
+		if isAPPn(marker) || marker == markerCOM {
+			metadataSize += int64(segmentLength - 2)
 		}
-		segmentData := make([]byte, segmentLength-2)
+		if err := o.limits.checkSegment(marker, int(segmentLength-2), metadataSize); err != nil {
+			return finish(err)
+		}
+		// Segments are read into a pooled buffer; every segment is written out
+		// before the next one is read, and anything kept is copied.
+		segmentData := (*scratch)[:segmentLength-2]
+		track(len(segmentData))
+		payloadStart := reader.n
 		_, err = io.ReadFull(reader, segmentData)
+		if err != nil && truncated(err) && !recoverTruncation {
+			result.Truncated = true
+			return finish(&SegmentError{Marker: marker, Offset: markerOffset, Length: int(segmentLength),
+				Reason: fmt.Sprintf("length %d overruns the data", segmentLength)})
+		}
 		if err != nil {
-			return nil, result, fmt.Errorf("failed to read segment data: %w", err)
+			if truncated(err) {
+				truncation(fmt.Sprintf("truncated segment 0x%04X dropped", marker))
+				break
+			}
+			return finish(fmt.Errorf("failed to read segment data: %w", err))
+		}
+		if o.parseMode == ParseLenient && marker == markerAPP1 {
+			if n, ok := looseExifHeader(segmentData); ok {
+				violation(fmt.Sprintf("non-standard EXIF header %q normalized", segmentData[:n]))
+				segmentData = append([]byte(exifHeader), segmentData[n:]...)
+				payloadStart += int64(n - len(exifHeader))
+			}
+		}
+		// Extended EXIF: a full EXIF segment may continue in the APP1 segments that follow.
+		if marker == markerAPP1 && isExifSegment(segmentData) && len(segmentData) == maxSegmentPayload {
+			for {
+				more, ok := readExifContinuation(reader)
+				if !ok {
+					break
+				}
+				metadataSize += int64(len(more))
+				if err := o.limits.checkSegment(marker, len(more), metadataSize); err != nil {
+					return finish(err)
+				}
+				segmentData = append(segmentData, more[len(exifHeader):]...)
+				track(len(segmentData))
+				if len(more) < maxSegmentPayload {
+					break
+				}
+			}
+		}
+		duplicateExif := false
+		if marker == markerAPP1 && isExifSegment(segmentData) {
+			if err := o.limits.checkExif(segmentData); err != nil {
+				return finish(err)
+			}
+			result.ExifSegments++
+			if result.ExifSegments > 1 {
+				duplicateExif = true
+				violation(fmt.Sprintf("duplicate EXIF segment at offset %d", markerOffset))
+			}
 		}
-		if marker == markerAPP1 && len(segmentData) > 6 && string(segmentData[0:6]) == "Exif\x00\x00" {
-			modifiedExif, hadThumb, thumbSize, err := removeThumbnailFromExif(segmentData)
+		if result.Skipped {
+			writeSegment(output, marker, segmentData)
+			continue
+		}
+		if len(o.visitors) > 0 {
+			visited, keep, err := o.visitSegment(marker, markerOffset, segmentData)
 			if err != nil {
-				return nil, result, &FormatError{"failed to remove EXIF thumbnail: " + err.Error()}
+				return finish(err)
+			}
+			if !keep {
+				dropped(markerName(marker, segmentData), segmentData)
+				continue
+			}
+			segmentData = visited
+		}
+		if duplicateExif && o.dedupeExif {
+			dropped(SegmentExif, segmentData)
+			continue
+		}
+		if o.stripComments && marker == markerCOM {
+			dropped("COM", segmentData)
+			continue
+		}
+		if isAPPn(marker) && o.dropSegment(segmentName(marker, segmentData)) {
+			dropped(segmentName(marker, segmentData), segmentData)
+			continue
+		}
+		if marker == markerAPP13 && isPhotoshopSegment(segmentData) {
+			resources, err := parsePhotoshopResources(segmentData)
+			if err == nil && iptcSize(resources) > 0 {
+				result.HadIPTC = true
+				result.IPTCSize += iptcSize(resources)
+				if o.stripIPTC {
+					stripped, remaining := stripIPTC(segmentData, resources)
+					if !remaining {
+						dropped(SegmentPhotoshop, segmentData)
+						continue
+					}
+					segmentData = stripped
+				}
+			}
+		}
+		if marker == markerAPP2 && isICCSegment(segmentData) && (o.stripICC || o.replaceICC != nil) {
+			dropped(SegmentICC, segmentData)
+			if o.replaceICC != nil && !wroteICC {
+				writeICCSegments(output, o.replaceICC)
+				wroteICC = true
+			}
+			continue
+		}
+		if o.stripAllExif && marker == markerAPP1 && isExifSegment(segmentData) {
+			dropped(SegmentExif, segmentData)
+			// The whole segment goes away; inspect it only to report the thumbnail.
+			if _, exifRes, err := removeThumbnailFromExif(segmentData, &options{}); err == nil {
+				noteExif(exifRes, payloadStart)
+			}
+			if o.keepOrientation && !wroteOrientation {
+				if t, orientation, ok := exifOrientation(segmentData); ok {
+					writeExif(marker, buildOrientationExif(t.Order, orientation))
+					wroteOrientation = true
+				}
+			}
+			continue
+		}
+		if o.stripAllExif && (marker == markerAPP1 && isXMPSegment(segmentData) ||
+			marker == markerAPP2 && isICCSegment(segmentData) && !o.keepICC) {
+			dropped(segmentName(marker, segmentData), segmentData)
+			continue
+		}
+		if marker == markerAPP1 && isExifSegment(segmentData) && riskyMakerNote(segmentData, o) {
+			result.RiskyMakerNote = true
+			if o.skipRisky {
+				result.Skipped = true
+				result.SkipReason = SkipRiskyMakerNote
+				result.Warnings = append(result.Warnings, "skipped: risky MakerNote relying on absolute offsets")
+				// Inspect a copy only to report the thumbnail that stays in place.
+				if _, exifRes, err := removeThumbnailFromExif(append([]byte{}, segmentData...), &options{keepThumbnail: true}); err == nil {
+					noteExif(exifRes, payloadStart)
+				}
+				writeExif(marker, segmentData)
+				continue
+			}
+		}
+		if marker == markerAPP1 && isExifSegment(segmentData) {
+			// The segment is modified in place, so keep the original for lenient recovery.
+			var original []byte
+			if o.parseMode == ParseLenient {
+				original = append([]byte{}, segmentData...)
 			}
-			if hadThumb {
-				foundThumbnail = true
-				thumbnailSize = thumbSize
+			modifiedExif, exifRes, err := removeThumbnailFromExif(segmentData, o)
+			if err != nil && o.parseMode == ParseLenient {
+				violation("EXIF segment kept unchanged: " + err.Error())
+				writeSegment(output, marker, original)
+				continue
 			}
-			binary.Write(output, binary.BigEndian, marker)
-			binary.Write(output, binary.BigEndian, uint16(len(modifiedExif)+2))
-			output.Write(modifiedExif)
+			if err != nil {
+				return finish(&FormatError{Code: CodeInvalidExif, Offset: markerOffset, msg: "failed to remove EXIF thumbnail: " + err.Error(), err: err})
+			}
+			noteExif(exifRes, payloadStart)
+			result.RemovedTags = append(result.RemovedTags, exifRes.removedTags...)
+			writeExif(marker, modifiedExif)
 		} else {
-			binary.Write(output, binary.BigEndian, marker)
-			binary.Write(output, binary.BigEndian, segmentLength)
-			output.Write(segmentData)
+			writeSegment(output, marker, segmentData)
 		}
 	}
-	outputData := output.Bytes()
-	result.AfterSize = int64(len(outputData))
 	result.HadThumbnail = foundThumbnail
 	result.ThumbnailSize = thumbnailSize
-	return outputData, result, nil
+	return finish(strictErr)
 }
 
 // ExifRemoveThumbnail removes the EXIF thumbnail from a JPEG image at inputPath and writes the result to outputPath.
 // It returns information about the operation and an error if the process fails.
-func ExifRemoveThumbnail(inputPath, outputPath string) (ExifRemoveThumbnailResult, error) {
-	inputData, err := os.ReadFile(inputPath)
+// The output is written to a temporary file next to outputPath and renamed into
+// place, so a failure never leaves a partial output file.
+//
+// JPEG files are streamed from inputPath to the output, so memory use does not
+// grow with the size of the image data. WithIntegrityCheck, WithConformance,
+// WithValidateOutput and WithVerifyPixels examine the whole output and make
+// the file be read into memory instead, as do files of other formats.
+func ExifRemoveThumbnail(inputPath, outputPath string, opts ...Option) (ExifRemoveThumbnailResult, error) {
+	return removeThumbnailFile(inputPath, outputPath, newOptions(opts))
+}
+
+// removeThumbnailFile is ExifRemoveThumbnail with resolved options.
+func removeThumbnailFile(inputPath, outputPath string, o *options) (ExifRemoveThumbnailResult, error) {
+	if limit := o.maxInputSize; limit > 0 {
+		if info, err := os.Stat(inputPath); err == nil && info.Size() > limit {
+			return ExifRemoveThumbnailResult{}, fmt.Errorf("%s: %w", inputPath, ErrInputTooLarge)
+		}
+	}
+	if o.streamable() {
+		in, err := os.Open(inputPath)
+		if err != nil {
+			return ExifRemoveThumbnailResult{}, fmt.Errorf("failed to read input file: %w", err)
+		}
+		defer in.Close()
+		header := make([]byte, detectHeaderSize)
+		n, _ := in.ReadAt(header, 0)
+		if isJPEG(header[:n]) || lookupFormat(header[:n]) == nil {
+			return streamFile(in, inputPath, outputPath, o)
+		}
+	}
+	inputData, release, err := readInput(inputPath, o)
 	if err != nil {
 		return ExifRemoveThumbnailResult{}, fmt.Errorf("failed to read input file: %w", err)
 	}
-	
-	outputData, result, err := ExifRemoveThumbnailBytes(inputData)
+	defer release()
+
+	if h, ok := lookupFormat(inputData).(patchHandler); ok && !isJPEG(inputData) {
+		return patchFile(h, inputData, inputPath, outputPath, o)
+	}
+	outputData, result, err := removeThumbnailBytes(inputData, o)
 	if err != nil {
 		return result, err
 	}
-	
-	if err := os.WriteFile(outputPath, outputData, 0644); err != nil {
+
+	if (result.Unchanged || result.Skipped) && sameFile(inputPath, outputPath) {
+		// Nothing to write back; re-running over a processed archive is nearly free.
+		return result, nil
+	}
+	if err := writeFile(outputPath, outputData, o); err != nil {
+		return result, fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	return result, nil
+}
+
+// patchFile is ExifRemoveThumbnail for formats handled by h: the output is
+// written from inputData and the patches, so the unchanged parts such as the
+// media data are not copied in memory.
+func patchFile(h patchHandler, inputData []byte, inputPath, outputPath string, o *options) (ExifRemoveThumbnailResult, error) {
+	patches, result, err := h.patches(inputData)
+	result.Format = h.Name()
+	if err != nil {
+		return result, err
+	}
+	if len(patches) == 0 && sameFile(inputPath, outputPath) {
+		return result, nil
+	}
+	err = writeFileFrom(outputPath, o, func(w io.Writer) error {
+		return writePatched(w, inputData, patches)
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return result, nil
+}
+
+// errSkippedFile stops the writing pass of streamFile when the file is
+// skipped, so that the input is copied instead.
+var errSkippedFile = errors.New("file skipped")
+
+// streamable reports whether ExifRemoveThumbnail may stream the file: none of
+// the options examining the whole output is set.
+func (o *options) streamable() bool {
+	return !o.checkIntegrity && !o.conformance && !o.validateOutput && !o.verifyPixels
+}
+
+// streamFile is ExifRemoveThumbnail for JPEG files read from in. Like
+// ExifRemoveThumbnailBytes, it first compares the output with the input
+// without writing anything, and only writes the output when it differs.
+func streamFile(in *os.File, inputPath, outputPath string, o *options) (ExifRemoveThumbnailResult, error) {
+	info, err := in.Stat()
+	if err != nil {
+		return ExifRemoveThumbnailResult{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+	size := info.Size()
+	var src io.ReaderAt = in
+	if o.mmap {
+		if data, unmap, err := mapFile(in, size); err == nil {
+			defer unmap()
+			src = bytes.NewReader(data)
+		}
+	}
+	// copyInput writes the input to the output as is, unless they are the same file.
+	copyInput := func(result ExifRemoveThumbnailResult) (ExifRemoveThumbnailResult, error) {
+		result.BeforeSize = size
+		result.AfterSize = size
+		if sameFile(inputPath, outputPath) {
+			return result, nil
+		}
+		err := writeFileFrom(outputPath, o, func(w io.Writer) error {
+			_, err := io.Copy(w, io.NewSectionReader(src, 0, size))
+			return err
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to write output file: %w", err)
+		}
+		return result, nil
+	}
+
+	if o.fault == 0 && len(o.visitors) == 0 {
+		m := &fileMatchWriter{ref: src}
+		result, err := removeThumbnailAt(m, src, size, o)
+		if err == nil && m.n == size {
+			result.Unchanged = true
+			return copyInput(result)
+		}
+		// A shorter output, such as one cut at a truncated segment, matches
+		// the input as far as it goes but still has to be written.
+		if err != nil && !errors.Is(err, errOutputDiffers) {
+			result.BeforeSize = size
+			return result, err
+		}
+	}
+
+	var result ExifRemoveThumbnailResult
+	var procErr error
+	err = writeFileFrom(outputPath, o, func(w io.Writer) error {
+		result, procErr = removeThumbnailAt(w, src, size, o)
+		if procErr == nil && result.Skipped {
+			procErr = errSkippedFile
+		}
+		return procErr
+	})
+	result.BeforeSize = size
+	switch {
+	case errors.Is(procErr, errSkippedFile):
+		return copyInput(result)
+	case procErr != nil:
+		return result, procErr
+	case err != nil:
 		return result, fmt.Errorf("failed to write output file: %w", err)
 	}
-	
 	return result, nil
 }
 
-// removeThumbnailFromExif removes thumbnail from EXIF segment data
-func removeThumbnailFromExif(exifData []byte) ([]byte, bool, int64, error) {
-	if len(exifData) < 6 || string(exifData[0:6]) != "Exif\x00\x00" {
-		return exifData, false, 0, fmt.Errorf("invalid EXIF header")
-	}
-	// Simple implementation: just set IFD1 offset to 0
-	// TIFF header starts from byte 6
-	pos := 6
-	if len(exifData) < pos+8 {
-		return exifData, false, 0, fmt.Errorf("invalid TIFF header")
-	}
-	byteOrder := binary.BigEndian.Uint16(exifData[pos : pos+2])
-	littleEndian := byteOrder == 0x4949
-	var readUint16 func([]byte) uint16
-	var readUint32 func([]byte) uint32
-	if littleEndian {
-		readUint16 = func(b []byte) uint16 { return binary.LittleEndian.Uint16(b) }
-		readUint32 = func(b []byte) uint32 { return binary.LittleEndian.Uint32(b) }
-	} else {
-		readUint16 = func(b []byte) uint16 { return binary.BigEndian.Uint16(b) }
-		readUint32 = func(b []byte) uint32 { return binary.BigEndian.Uint32(b) }
-	}
-	ifd0Offset := int(readUint32(exifData[pos+4 : pos+8]))
-	ifd0Pos := pos + ifd0Offset
-	if len(exifData) < ifd0Pos+2 {
-		return exifData, false, 0, fmt.Errorf("invalid IFD0")
-	}
-	entryCount := int(readUint16(exifData[ifd0Pos : ifd0Pos+2]))
-	ifd1OffsetPos := ifd0Pos + 2 + entryCount*12
-	if len(exifData) < ifd1OffsetPos+4 {
-		return exifData, false, 0, fmt.Errorf("invalid IFD1 offset")
-	}
-	ifd1Offset := int(readUint32(exifData[ifd1OffsetPos : ifd1OffsetPos+4]))
-	if ifd1Offset == 0 {
-		return exifData, false, 0, nil
-	}
-	// Estimate thumbnail size: from IFD1 start to end of EXIF data
-	thumbStart := pos + ifd1Offset
-	thumbSize := int64(len(exifData) - thumbStart)
+// recoverPanic converts a panic into a *FormatError stored in *err, so that a
+// single corrupt file cannot take down a long-running worker. It must be deferred
+// directly. offset is called only after a panic and returns the input position reached.
+func recoverPanic(err *error, offset func() int64) {
+	if p := recover(); p != nil {
+		off := offset()
+		*err = &FormatError{Code: CodeInternal, Offset: off, msg: fmt.Sprintf("internal error at input offset %d: %v", off, p)}
+	}
+}
+
+// skipFillBytes reads past 0xFF fill bytes, the first of which has been read
+// as the high byte of a marker, and returns the marker that follows them.
+func skipFillBytes(r io.Reader) (uint16, error) {
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if b[0] != 0xFF {
+			return 0xFF00 | uint16(b[0]), nil
+		}
+	}
+}
+
+// resyncMarker skips bytes until a marker is found, starting with the two bytes
+// already read as marker. It returns the marker and the number of skipped bytes,
+// not counting 0xFF fill bytes, which are allowed before any marker.
+func resyncMarker(r io.Reader, marker uint16) (uint16, int, error) {
+	garbage := 0
+	b := make([]byte, 1)
+	for marker&0xFF00 != 0xFF00 || marker == 0xFF00 || marker == 0xFFFF {
+		if marker>>8 != 0xFF {
+			garbage++
+		}
+		if _, err := io.ReadFull(r, b); err != nil {
+			return marker, garbage, err
+		}
+		marker = marker<<8 | uint16(b[0])
+	}
+	return marker, garbage, nil
+}
+
+// maxSegmentPayload is the largest payload a marker segment can hold.
+const maxSegmentPayload = 0xFFFF - 2
+
+// readExifContinuation reads the next segment if it continues an extended EXIF
+// segment: an APP1 segment with the EXIF header whose data does not start a TIFF
+// header of its own, which would make it a separate EXIF segment. Otherwise
+// everything read is given back to r and ok is false.
+func readExifContinuation(r *countingReader) (payload []byte, ok bool) {
+	header := make([]byte, 4)
+	n, err := io.ReadFull(r, header)
+	if err != nil || binary.BigEndian.Uint16(header) != spec.APP1 || binary.BigEndian.Uint16(header[2:]) < 2 {
+		r.unread(header[:n])
+		return nil, false
+	}
+	payload = make([]byte, binary.BigEndian.Uint16(header[2:])-2)
+	n, err = io.ReadFull(r, payload)
+	if err != nil || !isExifSegment(payload) || isTIFFHeader(payload[len(exifHeader):]) {
+		r.unread(append(header, payload[:n]...))
+		return nil, false
+	}
+	return payload, true
+}
+
+// isTIFFHeader reports whether data starts with a TIFF header.
+func isTIFFHeader(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*"))
+}
+
+// writeSegment writes a marker segment with its length field. EXIF payloads
+// too large for one segment are written as extended EXIF: the data continues in
+// further segments of the same marker, each starting with the EXIF header.
+func writeSegment(w io.Writer, marker uint16, payload []byte) {
+	if len(payload) > maxSegmentPayload && isExifSegment(payload) {
+		writeSegment(w, marker, payload[:maxSegmentPayload])
+		for rest := payload[maxSegmentPayload:]; len(rest) > 0; {
+			n := min(len(rest), maxSegmentPayload-len(exifHeader))
+			writeSegment(w, marker, append([]byte(exifHeader), rest[:n]...))
+			rest = rest[n:]
+		}
+		return
+	}
+	binary.Write(w, binary.BigEndian, marker)
+	binary.Write(w, binary.BigEndian, uint16(len(payload)+2))
+	w.Write(payload)
+}
+
+// isExifSegment reports whether an APP1 payload holds EXIF data.
+func isExifSegment(segmentData []byte) bool {
+	return len(segmentData) > len(exifHeader) && string(segmentData[0:len(exifHeader)]) == exifHeader
+}
+
+// looseExifHeader recognizes APP1 payloads written by some phones whose EXIF
+// header deviates from "Exif\x00\x00": the second NUL is missing or replaced,
+// as in "Exif\x00\xFF". It returns the length of the header before the TIFF data.
+func looseExifHeader(segmentData []byte) (int, bool) {
+	if isExifSegment(segmentData) || !bytes.HasPrefix(segmentData, []byte("Exif\x00")) {
+		return 0, false
+	}
+	for _, n := range []int{5, 6} {
+		if len(segmentData) > n && isTIFFHeader(segmentData[n:]) {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// isXMPSegment reports whether an APP1 payload holds XMP data.
+func isXMPSegment(segmentData []byte) bool {
+	const xmpHeader = "http://ns.adobe.com/xap/1.0/\x00"
+	return len(segmentData) >= len(xmpHeader) && string(segmentData[0:len(xmpHeader)]) == xmpHeader
+}
+
+// isICCSegment reports whether an APP2 payload holds an ICC profile chunk.
+func isICCSegment(segmentData []byte) bool {
+	return len(segmentData) >= len(iccHeader) && string(segmentData[0:len(iccHeader)]) == iccHeader
+}
+
+// exifResult describes what removeThumbnailFromExif changed in an EXIF segment.
+type exifResult struct {
+	hadThumbnail  bool
+	thumbnailSize int64
+	thumbnail     thumbnailInfo
+	summary       ExifSummary
+	removedTags   []TagRef
+	// warnings are anomalies of the input; notes are remarks on the output.
+	warnings []string
+	notes    []string
+}
+
+// removeThumbnailFromExif removes thumbnail from EXIF segment data.
+// Tags selected by the options are removed in the same pass.
+// exifData is modified in place and the returned slice shares its memory.
+func removeThumbnailFromExif(exifData []byte, o *options) ([]byte, exifResult, error) {
+	if o.exifBackend != nil {
+		return rewriteExifWithBackend(exifData, o)
+	}
+	var res exifResult
+	if !isExifSegment(exifData) {
+		return exifData, res, fmt.Errorf("invalid EXIF header: %w", ErrNoExif)
+	}
+	// TIFF header starts right after the EXIF header
+	pos := len(exifHeader)
+	t, err := parseTIFF(exifData[pos:])
+	if err != nil {
+		return exifData, res, err
+	}
+	res.summary = t.summary()
+	if len(o.removeTags) > 0 {
+		res.removedTags, err = t.removeTags(o.removeTags)
+		if err != nil {
+			return exifData, res, err
+		}
+	}
+	if o.removeGPS {
+		removed, err := t.removeGPS()
+		if err != nil {
+			return exifData, res, err
+		}
+		if removed {
+			res.removedTags = append(res.removedTags, TagRef{IFD: IFD0, ID: tagGPSIFD})
+		}
+	}
+	ifd0, err := t.ReadIFD(t.IFD0Offset())
+	if err != nil {
+		return exifData, res, fmt.Errorf("invalid IFD0: %w", err)
+	}
+	if ifd0.Next == 0 {
+		return compactExif(exifData, o), res, nil
+	}
+	dirs, err := t.ifds()
+	if err != nil {
+		return exifData, res, fmt.Errorf("invalid IFD0: %w", err)
+	}
+	ifd1, hasIFD1 := dirs[IFD1]
+	delete(dirs, IFD1)
+	// thumbnailSpans are the ranges owned by IFD1: the directory, its values and the thumbnail image.
+	var thumbnailSpans []span
+	// Without IFD1 only the pointer is dropped and the data is kept.
+	switch _, err := t.ReadIFD(ifd0.Next); {
+	case hasIFD1:
+		res.thumbnail = t.thumbnailInfo(ifd1)
+		thumbnailSpans = t.dirSpans(ifd1)
+	case err == nil:
+		res.thumbnail.offset = -1
+		res.warnings = append(res.warnings, fmt.Sprintf("IFD1 offset %d loops back into another IFD", ifd0.Next))
+	case ifd0.Next < len(t.Data):
+		res.thumbnail.offset = -1
+		res.warnings = append(res.warnings, fmt.Sprintf("IFD1 offset %d does not point to a valid IFD", ifd0.Next))
+	default:
+		res.thumbnail.offset = -1
+		res.warnings = append(res.warnings, fmt.Sprintf("IFD1 offset %d is outside the EXIF data", ifd0.Next))
+	}
+	live := t.liveSpans(dirs)
+	if info := res.thumbnail; info.offset >= 0 && info.size > 0 {
+		end := info.offset + info.size
+		if end > int64(len(t.Data)) {
+			res.warnings = append(res.warnings, fmt.Sprintf("thumbnail at offset %d (%d bytes) overruns the EXIF data", info.offset, info.size))
+		} else {
+			thumbnailSpans = append(thumbnailSpans, span{int(info.offset), int(end)})
+			unknown := 0
+			for _, u := range subtractSpans(span{int(end), len(t.Data)}, mergeSpans(append(append([]span{}, live...), thumbnailSpans...))) {
+				// A single byte is word-alignment padding.
+				if u.end-u.start > 1 {
+					unknown += u.end - u.start
+				}
+			}
+			if unknown > 0 {
+				res.warnings = append(res.warnings, fmt.Sprintf("%d bytes of unknown data after the thumbnail", unknown))
+			}
+		}
+	}
+	// A pointer looping back or leading out of the data has no thumbnail
+	// behind it; it is only reported in the warnings.
+	res.hadThumbnail = hasIFD1
+	if o.keepThumbnail {
+		return exifData, res, nil
+	}
 	// Set IFD1 offset to 0
-	result := make([]byte, len(exifData))
-	copy(result, exifData)
-	if littleEndian {
-		binary.LittleEndian.PutUint32(result[ifd1OffsetPos:], 0)
-	} else {
-		binary.BigEndian.PutUint32(result[ifd1OffsetPos:], 0)
-	}
-	// Remove data after IFD1
-	if thumbStart < len(result) {
-		result = result[:thumbStart]
-	}
-	return result, true, thumbSize, nil
+	t.Order.PutUint32(t.Data[ifd0.NextPos():], 0)
+	// Remove the IFD1 ranges wherever they are, plus everything from the IFD1
+	// start that the remaining IFDs do not reference. Referenced data is moved
+	// up and its offsets fixed.
+	if hasIFD1 && ifd0.Next < len(t.Data) {
+		thumbnailSpans = append(thumbnailSpans, span{ifd0.Next, len(t.Data)})
+	}
+	var cuts []span
+	for _, s := range mergeSpans(thumbnailSpans) {
+		cuts = append(cuts, subtractSpans(s, live)...)
+	}
+	cuts = alignCuts(cuts, len(t.Data))
+	for _, c := range cuts {
+		res.thumbnailSize += int64(c.end - c.start)
+	}
+	cuts, pinned := t.pinnedCuts(dirs, cuts)
+	if len(pinned) > 0 {
+		// Blank the data in place instead so that the MakerNote does not move.
+		blanked := 0
+		for _, c := range pinned {
+			clear(t.Data[c.start:c.end])
+			blanked += c.end - c.start
+		}
+		res.notes = append(res.notes, fmt.Sprintf("MakerNote kept in place; %d bytes zero-filled instead of removed", blanked))
+	}
+	tiff := t.cut(dirs, cuts)
+	return compactExif(append(exifData[:pos], tiff...), o), res, nil
 }
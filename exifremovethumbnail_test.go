@@ -2,10 +2,20 @@ package exifremovethumbnail_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"image/jpeg"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rwcarlsen/goexif/exif"
 	_ "github.com/rwcarlsen/goexif/mknote"
@@ -110,3 +120,1599 @@ func TestFormatError(t *testing.T) {
 	_, err = exifremovethumbnail.ExifRemoveThumbnail(file, out)
 	require.Error(t, err)
 }
+
+func TestSegmentError(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	cases := []struct {
+		name   string
+		data   []byte
+		length int
+	}{
+		{"セグメント長が2未満", append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01}, inData[2:]...), 1},
+		{"セグメント長がデータを超える", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00, 'E', 'x', 'i', 'f'}, 0x1000},
+		{"セグメント長がない", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10}, -1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			check := func(err error) {
+				var segErr *exifremovethumbnail.SegmentError
+				require.ErrorAs(t, err, &segErr)
+				require.Equal(t, uint16(0xFFE1), segErr.Marker)
+				require.Equal(t, int64(2), segErr.Offset)
+				require.Equal(t, c.length, segErr.Length)
+				var formatErr *exifremovethumbnail.FormatError
+				require.ErrorAs(t, err, &formatErr, "FormatErrorとしても扱えるべき")
+			}
+			_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(c.data)
+			check(err)
+			_, err = exifremovethumbnail.Analyze(c.data)
+			check(err)
+			_, _, err = exifremovethumbnail.DetectThumbnail(bytes.NewReader(c.data))
+			check(err)
+		})
+	}
+
+	t.Run("空のAPPセグメントはそのまま残す", func(t *testing.T) {
+		withEmpty := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x02, 0xFF, 0xED, 0x00, 0x02}, inData[2:]...)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withEmpty, exifremovethumbnail.WithStripIPTC())
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, withEmpty[:10], outData[:10])
+	})
+}
+
+func TestIFDLoop(t *testing.T) {
+	// ifd0Next はIFD0の次IFDポインタのペイロード内の位置を返す
+	ifd0Next := func(entries int) int { return 6 + 8 + 2 + entries*12 }
+
+	t.Run("IFD0を指すIFD1ポインタ", func(t *testing.T) {
+		payload := testTIFF{ifd0: []testEntry{asciiEntry(0x010F, "TestMaker")}}.exifPayload()
+		binary.BigEndian.PutUint32(payload[ifd0Next(1):], 8)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(jpegWithExif(t, payload))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), res.ThumbnailSize)
+		require.False(t, res.HadThumbnail, "ループしたポインタの先にサムネイルはない")
+		require.Equal(t, []string{"IFD1 offset 8 loops back into another IFD"}, res.Warnings)
+
+		_, again, err := exifremovethumbnail.ExifRemoveThumbnailBytes(outData)
+		require.NoError(t, err)
+		require.False(t, again.HadThumbnail, "ループしたポインタは切られるべき")
+		require.Equal(t, "TestMaker", again.Exif.Make)
+	})
+
+	t.Run("データの外を指すIFD1ポインタ", func(t *testing.T) {
+		payload := testTIFF{ifd0: []testEntry{asciiEntry(0x010F, "TestMaker")}}.exifPayload()
+		binary.BigEndian.PutUint32(payload[ifd0Next(1):], 0xFFFF)
+		for _, opts := range [][]exifremovethumbnail.Option{nil, {exifremovethumbnail.WithPolicy(exifremovethumbnail.Policy{})}} {
+			_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(jpegWithExif(t, payload), opts...)
+			require.NoError(t, err)
+			require.False(t, res.HadThumbnail)
+			require.Equal(t, []string{"IFD1 offset 65535 is outside the EXIF data"}, res.Warnings)
+		}
+	})
+
+	t.Run("読めないIFDを指すIFD1ポインタ", func(t *testing.T) {
+		payload := testTIFF{ifd0: []testEntry{asciiEntry(0x010F, "TestMaker")}}.exifPayload()
+		// 末尾に足したどこからも参照されないデータを指す。エントリ数が大きすぎて読めない
+		binary.BigEndian.PutUint32(payload[ifd0Next(1):], uint32(len(payload)-6))
+		payload = append(payload, 0xFF, 0xFF, 0xAB, 0xAB)
+		inData := jpegWithExif(t, payload)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, len(inData), len(outData), "ポインタの先のデータは残すべき")
+		require.Equal(t, int64(0), res.ThumbnailSize)
+		require.False(t, res.HadThumbnail)
+		require.Equal(t, []string{"IFD1 offset 36 does not point to a valid IFD"}, res.Warnings)
+
+		_, again, err := exifremovethumbnail.ExifRemoveThumbnailBytes(outData)
+		require.NoError(t, err)
+		require.Empty(t, again.Warnings, "ポインタは切られるべき")
+		require.Equal(t, "TestMaker", again.Exif.Make)
+	})
+
+	t.Run("IFD0を指すExifとGPSのポインタ", func(t *testing.T) {
+		payload := testTIFF{
+			ifd0:      []testEntry{asciiEntry(0x010F, "TestMaker")},
+			exif:      []testEntry{asciiEntry(0x9003, "2024:01:02 03:04:05")},
+			gps:       []testEntry{asciiEntry(0x0001, "N")},
+			ifd1:      []testEntry{shortEntry(binary.BigEndian, 0x0103, 6)},
+			thumbnail: bytes.Repeat([]byte{0xAB}, 100),
+		}.exifPayload()
+		// IFD0のエントリはMake、ExifIFD、GPSIFDの順
+		binary.BigEndian.PutUint32(payload[6+8+2+1*12+8:], 8)
+		binary.BigEndian.PutUint32(payload[6+8+2+2*12+8:], 8)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(jpegWithExif(t, payload),
+			exifremovethumbnail.WithRemoveGPS(), exifremovethumbnail.WithRetainedTagsReport())
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, []exifremovethumbnail.TagRef{{IFD: exifremovethumbnail.IFD0, ID: 0x010F}}, res.RetainedTags)
+
+		_, again, err := exifremovethumbnail.ExifRemoveThumbnailBytes(outData)
+		require.NoError(t, err)
+		require.Equal(t, "TestMaker", again.Exif.Make, "IFD0は壊れないべき")
+	})
+}
+
+func TestMaxInputSize(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	size := int64(len(inData))
+
+	t.Run("上限ちょうどは処理する", func(t *testing.T) {
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithMaxInputSize(size))
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+	})
+
+	t.Run("上限を超えるとErrInputTooLarge", func(t *testing.T) {
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithMaxInputSize(size-1))
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInputTooLarge)
+
+		out := filepath.Join(t.TempDir(), "out.jpg")
+		_, err = exifremovethumbnail.ExifRemoveThumbnail(filepath.Join("testdata", "thumbnail_embedded.jpg"), out,
+			exifremovethumbnail.WithMaxInputSize(size-1))
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInputTooLarge)
+		require.NoFileExists(t, out)
+	})
+
+	t.Run("ストリームではSOS以降の連結データも上限に含む", func(t *testing.T) {
+		concatenated := io.MultiReader(bytes.NewReader(inData), bytes.NewReader(make([]byte, 1<<20)))
+		r := exifremovethumbnail.NewReader(concatenated, exifremovethumbnail.WithMaxInputSize(size+100))
+		defer r.Close()
+		_, err := io.Copy(io.Discard, r)
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInputTooLarge)
+	})
+}
+
+// panicReader は指定バイト数を読んだ後にpanicするReader
+type panicReader struct {
+	r     io.Reader
+	after int
+}
+
+func (p *panicReader) Read(b []byte) (int, error) {
+	if p.after <= 0 {
+		panic("test panic")
+	}
+	n, err := p.r.Read(b[:min(len(b), p.after)])
+	p.after -= n
+	return n, err
+}
+
+func TestPanicRecovery(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+
+	t.Run("処理中のpanicはFormatErrorになる", func(t *testing.T) {
+		_, err := exifremovethumbnail.EstimateSavings(&panicReader{r: bytes.NewReader(inData), after: 100})
+		var formatErr *exifremovethumbnail.FormatError
+		require.ErrorAs(t, err, &formatErr)
+		require.Contains(t, err.Error(), "test panic")
+		require.Contains(t, err.Error(), "at input offset 100")
+	})
+
+	t.Run("ストリームでもワーカーが落ちない", func(t *testing.T) {
+		r := exifremovethumbnail.NewReader(&panicReader{r: bytes.NewReader(inData), after: 100})
+		defer r.Close()
+		_, err := io.Copy(io.Discard, r)
+		var formatErr *exifremovethumbnail.FormatError
+		require.ErrorAs(t, err, &formatErr)
+	})
+
+	t.Run("ReaderAtのpanic", func(t *testing.T) {
+		_, _, err := exifremovethumbnail.DetectThumbnail(panicReaderAt{})
+		var formatErr *exifremovethumbnail.FormatError
+		require.ErrorAs(t, err, &formatErr)
+	})
+}
+
+// panicReaderAt は常にpanicするReaderAt
+type panicReaderAt struct{}
+
+func (panicReaderAt) ReadAt([]byte, int64) (int, error) {
+	panic("test panic")
+}
+
+func TestSentinelErrors(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	pngData, err := os.ReadFile(filepath.Join("testdata", "actual_png.jpg"))
+	require.NoError(t, err)
+	// badIFD0 はIFD0のオフセットがデータ外を指すEXIFペイロード
+	badIFD0 := append([]byte("Exif\x00\x00MM\x00\x2A"), 0x00, 0x00, 0x10, 0x00)
+
+	cases := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"JPEGでない", pngData, exifremovethumbnail.ErrNotJPEG},
+		{"マーカーの途中で終わる", append([]byte{}, inData[:3]...), exifremovethumbnail.ErrTruncated},
+		{"セグメントの途中で終わる", append([]byte{}, inData[:30]...), exifremovethumbnail.ErrTruncated},
+		{"不正なIFD", insertSegment(inData, 0xE1, badIFD0), exifremovethumbnail.ErrInvalidIFD},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(c.data)
+			require.ErrorIs(t, err, c.want)
+			var formatErr *exifremovethumbnail.FormatError
+			require.ErrorAs(t, err, &formatErr)
+		})
+	}
+
+	t.Run("AnalyzeとDetectThumbnailも同じ分類", func(t *testing.T) {
+		_, err := exifremovethumbnail.Analyze(pngData)
+		require.ErrorIs(t, err, exifremovethumbnail.ErrNotJPEG)
+		_, err = exifremovethumbnail.Analyze(inData[:30])
+		require.ErrorIs(t, err, exifremovethumbnail.ErrTruncated)
+		_, _, err = exifremovethumbnail.DetectThumbnail(bytes.NewReader(insertSegment(inData, 0xE1, badIFD0)))
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInvalidIFD)
+	})
+
+	t.Run("2未満のセグメント長は途中切れではない", func(t *testing.T) {
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x00}, inData[2:]...))
+		require.Error(t, err)
+		require.NotErrorIs(t, err, exifremovethumbnail.ErrTruncated)
+	})
+}
+
+func TestErrorCodes(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	pngData, err := os.ReadFile(filepath.Join("testdata", "actual_png.jpg"))
+	require.NoError(t, err)
+	withExif := insertSegment(inData, 0xE1, append([]byte("Exif\x00\x00MM\x00\x2A"), 0x00, 0x00, 0x10, 0x00))
+
+	cases := []struct {
+		name   string
+		data   []byte
+		opts   []exifremovethumbnail.Option
+		code   exifremovethumbnail.ErrorCode
+		offset int64
+	}{
+		{"JPEGでない", pngData, nil, exifremovethumbnail.CodeNotJPEG, 0},
+		{"不正なマーカー", []byte{0xFF, 0xD8, 0x00, 0x00}, nil, exifremovethumbnail.CodeInvalidMarker, 2},
+		{"マーカーの途中で終わる", inData[:3], nil, exifremovethumbnail.CodeTruncated, 2},
+		{"セグメント長が2未満", []byte{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x01}, nil, exifremovethumbnail.CodeInvalidSegmentLength, 2},
+		{"不正なEXIF", withExif, nil, exifremovethumbnail.CodeInvalidExif, 2},
+		{"厳格モードの違反", append(append([]byte{}, inData...), 0), []exifremovethumbnail.Option{
+			exifremovethumbnail.WithParseMode(exifremovethumbnail.ParseStrict),
+		}, exifremovethumbnail.CodeStrictViolation, -1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(c.data, c.opts...)
+			var formatErr *exifremovethumbnail.FormatError
+			require.ErrorAs(t, err, &formatErr)
+			require.Equal(t, c.code, formatErr.Code)
+			require.Equal(t, c.offset, formatErr.Offset)
+		})
+	}
+
+	t.Run("JSONで出力できる", func(t *testing.T) {
+		_, _, formatErr := exifremovethumbnail.ExifRemoveThumbnailBytes(pngData)
+		data, err := json.Marshal(formatErr)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"code":"not_jpeg","offset":0,"message":"not a valid JPEG file"}`, string(data))
+	})
+
+	t.Run("エラーの分類", func(t *testing.T) {
+		_, _, formatErr := exifremovethumbnail.ExifRemoveThumbnailBytes(pngData)
+		require.Equal(t, exifremovethumbnail.CodeNotJPEG, exifremovethumbnail.ErrorClass(formatErr))
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithMaxInputSize(100))
+		require.Equal(t, exifremovethumbnail.CodeInputTooLarge, exifremovethumbnail.ErrorClass(err))
+		_, err = exifremovethumbnail.ExifRemoveThumbnail(filepath.Join("testdata", "missing.jpg"), filepath.Join(t.TempDir(), "out.jpg"))
+		require.Equal(t, exifremovethumbnail.CodeIO, exifremovethumbnail.ErrorClass(err))
+		require.Equal(t, exifremovethumbnail.CodeVerification, exifremovethumbnail.ErrorClass(fmt.Errorf("check: %w", exifremovethumbnail.ErrImageDataChanged)))
+		require.Equal(t, exifremovethumbnail.CodeOther, exifremovethumbnail.ErrorClass(errors.New("other")))
+		require.Equal(t, exifremovethumbnail.ErrorCode(""), exifremovethumbnail.ErrorClass(nil))
+	})
+}
+
+// insertSegment はSOI直後にセグメントを挿入したJPEGデータを返す
+func insertSegment(data []byte, marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	out := append([]byte{}, data[:2]...)
+	out = append(out, seg...)
+	out = append(out, payload...)
+	return append(out, data[2:]...)
+}
+
+func TestStripAllExif(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	icc := append([]byte("ICC_PROFILE\x00\x01\x01"), bytes.Repeat([]byte{0x11}, 32)...)
+	inData = insertSegment(inData, 0xE2, icc)
+
+	t.Run("全削除", func(t *testing.T) {
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithStripAllExif())
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail, "削除前のサムネイルは報告されるべき")
+		_, err = exif.Decode(bytes.NewReader(outData))
+		require.Error(t, err, "Exifは残らないべき")
+		require.False(t, bytes.Contains(outData, []byte("ICC_PROFILE")), "ICCも削除されるべき")
+		_, err = jpeg.Decode(bytes.NewReader(outData))
+		require.NoError(t, err, "JPEGデコード可能であるべき")
+	})
+
+	t.Run("OrientationとICCを保持", func(t *testing.T) {
+		outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithStripAllExif(),
+			exifremovethumbnail.WithKeepOrientation(),
+			exifremovethumbnail.WithKeepICC())
+		require.NoError(t, err)
+		require.True(t, bytes.Contains(outData, icc), "ICCは保持されるべき")
+		outExif, err := exif.Decode(bytes.NewReader(outData))
+		require.NoError(t, err)
+		tag, err := outExif.Get(exif.Orientation)
+		require.NoError(t, err, "Orientationは保持されるべき")
+		v, err := tag.Int(0)
+		require.NoError(t, err)
+		require.Equal(t, 1, v)
+		_, err = outExif.Get(exif.Make)
+		require.Error(t, err, "Orientation以外のタグは削除されるべき")
+	})
+}
+
+func TestRemoveTags(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	inExif, err := exif.Decode(bytes.NewReader(inData))
+	require.NoError(t, err)
+	copyright, err := inExif.Get(exif.Copyright)
+	require.NoError(t, err)
+	copyrightValue, err := copyright.StringVal()
+	require.NoError(t, err)
+
+	outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithRemoveTags(
+		exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFD0, ID: 0x8298},
+		exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFDExif, ID: 0x9003},
+		exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFDExif, ID: 0x9286},
+	))
+	require.NoError(t, err)
+	require.True(t, res.HadThumbnail)
+	require.ElementsMatch(t, []exifremovethumbnail.TagRef{
+		{IFD: exifremovethumbnail.IFD0, ID: 0x8298},
+		{IFD: exifremovethumbnail.IFDExif, ID: 0x9003},
+	}, res.RemovedTags, "存在したタグだけが報告されるべき")
+	require.False(t, bytes.Contains(outData, []byte(copyrightValue)), "削除したタグの値はゼロ埋めされるべき")
+
+	outExif, err := exif.Decode(bytes.NewReader(outData))
+	require.NoError(t, err)
+	_, err = outExif.Get(exif.Copyright)
+	require.Error(t, err, "Copyrightは削除されるべき")
+	_, err = outExif.Get(exif.DateTimeOriginal)
+	require.Error(t, err, "DateTimeOriginalは削除されるべき")
+	_, err = outExif.Get(exif.Software)
+	require.NoError(t, err, "他のタグは保持されるべき")
+	_, err = outExif.Get(exif.DateTimeDigitized)
+	require.NoError(t, err, "同じIFDの他のタグは保持されるべき")
+	_, err = outExif.Get(exif.GPSLatitude)
+	require.NoError(t, err, "GPSは保持されるべき")
+}
+
+func TestRemoveMakerNote(t *testing.T) {
+	makerNote := append([]byte("Nikon\x00\x02\x00"), bytes.Repeat([]byte("MAKERNOTE"), 20)...)
+	payload := testTIFF{
+		ifd0: []testEntry{asciiEntry(0x010F, "TestMaker"), asciiEntry(0x0110, "TestModel")},
+		exif: []testEntry{asciiEntry(0x9003, "2024:01:02 03:04:05"), undefinedEntry(0x927C, makerNote)},
+	}.exifPayload()
+	inData := jpegWithExif(t, payload)
+
+	outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithRemoveMakerNote())
+	require.NoError(t, err)
+	require.Equal(t, []exifremovethumbnail.TagRef{{IFD: exifremovethumbnail.IFDExif, ID: 0x927C}}, res.RemovedTags)
+	require.False(t, bytes.Contains(outData, []byte("MAKERNOTE")), "MakerNoteの値はゼロ埋めされるべき")
+
+	outExif, err := exif.Decode(bytes.NewReader(outData))
+	require.NoError(t, err)
+	_, err = outExif.Get(exif.MakerNote)
+	require.Error(t, err, "MakerNoteは削除されるべき")
+	tag, err := outExif.Get(exif.DateTimeOriginal)
+	require.NoError(t, err, "他のタグは保持されるべき")
+	v, err := tag.StringVal()
+	require.NoError(t, err)
+	require.Equal(t, "2024:01:02 03:04:05", v)
+	_, err = jpeg.Decode(bytes.NewReader(outData))
+	require.NoError(t, err)
+}
+
+func TestRemoveOwnerInfo(t *testing.T) {
+	payload := testTIFF{
+		ifd0: []testEntry{asciiEntry(0x010F, "TestMaker"), asciiEntry(0x013B, "Taro Yamada")},
+		exif: []testEntry{asciiEntry(0xA430, "Owner Name"), asciiEntry(0xA431, "BODY-123456"), asciiEntry(0x9003, "2024:01:02 03:04:05")},
+	}.exifPayload()
+	inData := jpegWithExif(t, payload)
+
+	outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithRemoveOwnerInfo())
+	require.NoError(t, err)
+	// LensSerialNumberは存在しないので報告されない
+	require.ElementsMatch(t, []exifremovethumbnail.TagRef{
+		{IFD: exifremovethumbnail.IFD0, ID: 0x013B},
+		{IFD: exifremovethumbnail.IFDExif, ID: 0xA430},
+		{IFD: exifremovethumbnail.IFDExif, ID: 0xA431},
+	}, res.RemovedTags)
+	for _, s := range []string{"Taro Yamada", "Owner Name", "BODY-123456"} {
+		require.False(t, bytes.Contains(outData, []byte(s)), "%s は残らないべき", s)
+	}
+	outExif, err := exif.Decode(bytes.NewReader(outData))
+	require.NoError(t, err)
+	_, err = outExif.Get(exif.Make)
+	require.NoError(t, err, "Makeは保持されるべき")
+	_, err = outExif.Get(exif.DateTimeOriginal)
+	require.NoError(t, err, "DateTimeOriginalは保持されるべき")
+}
+
+func TestStripComments(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	inData = insertSegment(inData, 0xFE, []byte("Created with GIMP"))
+
+	outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	require.True(t, bytes.Contains(outData, []byte("Created with GIMP")), "オプションなしではコメントは保持されるべき")
+
+	outData, _, err = exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithStripComments())
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(outData, []byte("Created with GIMP")), "コメントは削除されるべき")
+	_, err = jpeg.Decode(bytes.NewReader(outData))
+	require.NoError(t, err)
+}
+
+func TestSegmentFilter(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	icc := append([]byte("ICC_PROFILE\x00\x01\x01"), bytes.Repeat([]byte{0x11}, 32)...)
+	inData = insertSegment(inData, 0xE2, icc)
+	inData = insertSegment(inData, 0xEC, []byte("Ducky\x00\x01"))
+
+	t.Run("ホワイトリスト", func(t *testing.T) {
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithKeepSegments("APP0", exifremovethumbnail.SegmentExif, exifremovethumbnail.SegmentICC))
+		require.NoError(t, err)
+		require.Equal(t, map[exifremovethumbnail.Segment]int64{"APP12": 11}, res.RemovedSegments)
+		require.False(t, bytes.Contains(outData, []byte("Ducky")))
+		require.True(t, bytes.Contains(outData, icc))
+		_, err = exif.Decode(bytes.NewReader(outData))
+		require.NoError(t, err, "Exifは保持されるべき")
+	})
+
+	t.Run("ブラックリスト", func(t *testing.T) {
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithDropSegments(exifremovethumbnail.SegmentICC, "APP12"))
+		require.NoError(t, err)
+		require.Equal(t, map[exifremovethumbnail.Segment]int64{
+			exifremovethumbnail.SegmentICC: int64(len(icc) + 4),
+			"APP12":                        11,
+		}, res.RemovedSegments)
+		require.False(t, bytes.Contains(outData, icc))
+		require.True(t, res.HadThumbnail, "サムネイル削除も同じ処理で行われるべき")
+		_, err = jpeg.Decode(bytes.NewReader(outData))
+		require.NoError(t, err)
+	})
+}
+
+// iccChunks はJPEGデータ内のICCプロファイルのチャンクを順に連結して返す
+func iccChunks(data []byte) ([]byte, int) {
+	var profile []byte
+	count := 0
+	for pos := 2; pos+4 <= len(data) && data[pos+1] != 0xDA; {
+		length := int(data[pos+2])<<8 | int(data[pos+3])
+		payload := data[pos+4 : pos+2+length]
+		if data[pos+1] == 0xE2 && bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")) {
+			profile = append(profile, payload[14:]...)
+			count++
+		}
+		pos += 2 + length
+	}
+	return profile, count
+}
+
+func TestICCProfile(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	original := bytes.Repeat([]byte{0x11}, 32)
+	withICC := insertSegment(inData, 0xE2, append([]byte("ICC_PROFILE\x00\x01\x01"), original...))
+
+	t.Run("デフォルトでは保持", func(t *testing.T) {
+		outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withICC)
+		require.NoError(t, err)
+		profile, _ := iccChunks(outData)
+		require.Equal(t, original, profile)
+	})
+
+	t.Run("削除", func(t *testing.T) {
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withICC, exifremovethumbnail.WithStripICC())
+		require.NoError(t, err)
+		_, count := iccChunks(outData)
+		require.Zero(t, count)
+		require.Equal(t, int64(len(original)+18), res.RemovedSegments[exifremovethumbnail.SegmentICC])
+	})
+
+	t.Run("置換", func(t *testing.T) {
+		replacement := bytes.Repeat([]byte{0x22}, 100)
+		outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withICC, exifremovethumbnail.WithReplaceICC(replacement))
+		require.NoError(t, err)
+		profile, count := iccChunks(outData)
+		require.Equal(t, 1, count)
+		require.Equal(t, replacement, profile)
+	})
+
+	t.Run("ICCがない画像へ大きなプロファイルを挿入", func(t *testing.T) {
+		replacement := bytes.Repeat([]byte{0x33, 0x44}, 40000)
+		outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithReplaceICC(replacement))
+		require.NoError(t, err)
+		profile, count := iccChunks(outData)
+		require.Equal(t, 2, count, "64KBを超えるプロファイルは分割されるべき")
+		require.Equal(t, replacement, profile)
+		_, err = jpeg.Decode(bytes.NewReader(outData))
+		require.NoError(t, err)
+	})
+}
+
+// photoshopResource はAPP13の8BIMリソースブロックを作る
+func photoshopResource(id uint16, data []byte) []byte {
+	b := []byte{'8', 'B', 'I', 'M', byte(id >> 8), byte(id), 0, 0}
+	b = append(b, byte(len(data)>>24), byte(len(data)>>16), byte(len(data)>>8), byte(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestIPTC(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	iptc := []byte("\x1c\x02\x78\x00\x0bIPTCCAPTION")
+	digest := bytes.Repeat([]byte{0xAB}, 16)
+
+	t.Run("デフォルトでは保持して報告", func(t *testing.T) {
+		app13 := append([]byte("Photoshop 3.0\x00"), photoshopResource(0x0404, iptc)...)
+		withIPTC := insertSegment(inData, 0xED, app13)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withIPTC)
+		require.NoError(t, err)
+		require.True(t, res.HadIPTC)
+		require.Equal(t, int64(len(iptc)), res.IPTCSize)
+		require.True(t, bytes.Contains(outData, app13), "APP13はそのまま保持されるべき")
+	})
+
+	t.Run("IPTCのみ削除し他のリソースは保持", func(t *testing.T) {
+		app13 := append([]byte("Photoshop 3.0\x00"), photoshopResource(0x0404, iptc)...)
+		app13 = append(app13, photoshopResource(0x0425, digest)...)
+		withIPTC := insertSegment(inData, 0xED, app13)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withIPTC, exifremovethumbnail.WithStripIPTC())
+		require.NoError(t, err)
+		require.True(t, res.HadIPTC)
+		require.False(t, bytes.Contains(outData, []byte("IPTCCAPTION")))
+		require.True(t, bytes.Contains(outData, append([]byte("Photoshop 3.0\x00"), photoshopResource(0x0425, digest)...)))
+		_, err = jpeg.Decode(bytes.NewReader(outData))
+		require.NoError(t, err)
+	})
+
+	t.Run("IPTCだけのAPP13はセグメントごと削除", func(t *testing.T) {
+		app13 := append([]byte("Photoshop 3.0\x00"), photoshopResource(0x0404, iptc)...)
+		withIPTC := insertSegment(inData, 0xED, app13)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withIPTC, exifremovethumbnail.WithStripIPTC())
+		require.NoError(t, err)
+		require.False(t, bytes.Contains(outData, []byte("Photoshop 3.0")))
+		require.Equal(t, int64(len(app13)+4), res.RemovedSegments[exifremovethumbnail.SegmentPhotoshop])
+	})
+
+	t.Run("IPTCなし", func(t *testing.T) {
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.False(t, res.HadIPTC)
+		require.Zero(t, res.IPTCSize)
+	})
+}
+
+func TestThumbnailDetails(t *testing.T) {
+	t.Run("JPEGサムネイル", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.ThumbnailJPEG, res.ThumbnailCompression)
+		require.Equal(t, "jpeg", res.ThumbnailCompression.String())
+
+		// オフセットの位置から実際にサムネイルをデコードできること
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(inData[res.ThumbnailOffset:]))
+		require.NoError(t, err)
+		require.Equal(t, cfg.Width, res.ThumbnailWidth)
+		require.Equal(t, cfg.Height, res.ThumbnailHeight)
+		require.Greater(t, res.ThumbnailWidth, 0)
+	})
+
+	t.Run("非圧縮サムネイル", func(t *testing.T) {
+		order := binary.BigEndian
+		payload := testTIFF{
+			order: order,
+			ifd0:  []testEntry{asciiEntry(0x010F, "TestMaker")},
+			ifd1: []testEntry{
+				shortEntry(order, 0x0100, 160),
+				shortEntry(order, 0x0101, 120),
+				shortEntry(order, 0x0103, 1),
+				{tag: 0x0111, typ: 4, count: 1, value: []byte{0, 0, 0, 8}},
+			},
+		}.exifPayload()
+		inData := jpegWithExif(t, payload)
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, exifremovethumbnail.ThumbnailUncompressed, res.ThumbnailCompression)
+		require.Equal(t, 160, res.ThumbnailWidth)
+		require.Equal(t, 120, res.ThumbnailHeight)
+		tiffStart := int64(bytes.Index(inData, []byte("Exif\x00\x00")) + 6)
+		require.Equal(t, tiffStart+8, res.ThumbnailOffset)
+	})
+
+	t.Run("サムネイルなし", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "metadata_basic_exif.jpg"))
+		require.NoError(t, err)
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.ThumbnailUnknown, res.ThumbnailCompression)
+		require.Zero(t, res.ThumbnailWidth)
+		require.Zero(t, res.ThumbnailOffset)
+	})
+}
+
+func TestExifSummary(t *testing.T) {
+	t.Run("テスト画像", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		inExif, err := exif.Decode(bytes.NewReader(inData))
+		require.NoError(t, err)
+		dt, err := inExif.Get(exif.DateTimeOriginal)
+		require.NoError(t, err)
+		expected, err := dt.StringVal()
+		require.NoError(t, err)
+
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, 1, res.Exif.Orientation)
+		require.Equal(t, expected, res.Exif.DateTimeOriginal)
+	})
+
+	t.Run("削除するタグも削除前の値で報告", func(t *testing.T) {
+		order := binary.LittleEndian
+		payload := testTIFF{
+			order: order,
+			ifd0:  []testEntry{asciiEntry(0x010F, "Maker"), asciiEntry(0x0110, "Model X100"), shortEntry(order, 0x0112, 6)},
+			exif:  []testEntry{asciiEntry(0x9003, "2024:01:02 03:04:05")},
+		}.exifPayload()
+		inData := jpegWithExif(t, payload)
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithRemoveTags(
+			exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFDExif, ID: 0x9003}))
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.ExifSummary{
+			Make:             "Maker",
+			Model:            "Model X100",
+			Orientation:      6,
+			DateTimeOriginal: "2024:01:02 03:04:05",
+		}, res.Exif)
+	})
+
+	t.Run("EXIFなし", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "metadata_none.jpg"))
+		require.NoError(t, err)
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.ExifSummary{}, res.Exif)
+	})
+}
+
+func TestWarnings(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+
+	t.Run("正常なファイルは警告なし", func(t *testing.T) {
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Empty(t, res.Warnings)
+	})
+
+	t.Run("EOI後の余分なデータ", func(t *testing.T) {
+		withTrailer := append(append([]byte{}, inData...), []byte("TRAILER")...)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withTrailer)
+		require.NoError(t, err)
+		require.Equal(t, []string{"7 bytes of trailing data after EOI"}, res.Warnings)
+		require.True(t, bytes.HasSuffix(outData, []byte("TRAILER")), "余分なデータもそのままコピーされるべき")
+	})
+
+	t.Run("EOI後の余分なデータを削除", func(t *testing.T) {
+		want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		// 0xFFで終わる余分なデータでも最初のEOIで切る
+		withTrailer := append(append([]byte{}, inData...), []byte("TRAILER\xFF\xD9")...)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withTrailer, exifremovethumbnail.WithStripTrailer())
+		require.NoError(t, err)
+		require.Equal(t, want, outData)
+		require.Equal(t, int64(9), res.RemovedSegments[exifremovethumbnail.SegmentTrailer])
+		require.Equal(t, int64(len(want)), res.AfterSize)
+
+		// サイズを保つ場合は残す
+		outData, res, err = exifremovethumbnail.ExifRemoveThumbnailBytes(withTrailer,
+			exifremovethumbnail.WithStripTrailer(), exifremovethumbnail.WithConstantSize())
+		require.NoError(t, err)
+		require.Len(t, outData, len(withTrailer))
+		require.NotContains(t, res.RemovedSegments, exifremovethumbnail.SegmentTrailer)
+	})
+
+	t.Run("EOIなし", func(t *testing.T) {
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData[:len(inData)-2])
+		require.NoError(t, err)
+		require.Equal(t, []string{"missing EOI marker"}, res.Warnings)
+	})
+
+	t.Run("サムネイル後の不明なデータ", func(t *testing.T) {
+		payload := testTIFF{
+			ifd0:      []testEntry{asciiEntry(0x010F, "TestMaker")},
+			thumbnail: []byte{0xFF, 0xD8, 0xFF, 0xD9},
+		}.exifPayload()
+		payload = append(payload, 0, 0, 0)
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(jpegWithExif(t, payload))
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, []string{"3 bytes of unknown data after the thumbnail"}, res.Warnings)
+	})
+}
+
+func TestIFD1Rewrite(t *testing.T) {
+	thumbnail := bytes.Repeat([]byte{0xAB}, 300)
+
+	t.Run("サムネイルの後ろにある値を残してオフセットを直す", func(t *testing.T) {
+		for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			tt := testTIFF{
+				order:                order,
+				ifd0:                 []testEntry{asciiEntry(0x010F, "TestMaker"), asciiEntry(0x0131, "TestSoftware")},
+				exif:                 []testEntry{asciiEntry(0x9003, "2024:01:02 03:04:05")},
+				ifd1:                 []testEntry{shortEntry(order, 0x0103, 6)},
+				thumbnail:            thumbnail,
+				valuesAfterThumbnail: true,
+			}
+			inData := jpegWithExif(t, tt.exifPayload())
+
+			outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+			require.NoError(t, err)
+			require.True(t, res.HadThumbnail)
+			require.False(t, bytes.Contains(outData, thumbnail), "サムネイルは削除されるべき")
+			require.Equal(t, int64(len(inData)-len(outData)), res.ThumbnailSize)
+
+			outExif, err := exif.Decode(bytes.NewReader(outData))
+			require.NoError(t, err)
+			software, err := outExif.Get(exif.Software)
+			require.NoError(t, err)
+			require.Equal(t, `"TestSoftware"`, software.String())
+			dt, err := outExif.Get(exif.DateTimeOriginal)
+			require.NoError(t, err)
+			require.Equal(t, `"2024:01:02 03:04:05"`, dt.String())
+			_, err = outExif.JpegThumbnail()
+			require.Error(t, err, "サムネイルは残らないべき")
+		}
+	})
+
+	t.Run("IFD1の後ろにあるIFD0の値も残る", func(t *testing.T) {
+		// ヘルパーはIFD0、IFD1、値領域、サムネイルの順に並べる
+		tt := testTIFF{
+			ifd0:      []testEntry{asciiEntry(0x010F, "TestMaker")},
+			ifd1:      []testEntry{shortEntry(binary.BigEndian, 0x0103, 6)},
+			thumbnail: thumbnail,
+		}
+		inData := jpegWithExif(t, tt.exifPayload())
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		// IFD1(3エントリ)とサムネイルだけが削除される
+		require.Equal(t, int64(2+3*12+4+len(thumbnail)), res.ThumbnailSize)
+		require.Equal(t, len(inData)-int(res.ThumbnailSize), len(outData))
+
+		outExif, err := exif.Decode(bytes.NewReader(outData))
+		require.NoError(t, err)
+		maker, err := outExif.Get(exif.Make)
+		require.NoError(t, err)
+		require.Equal(t, `"TestMaker"`, maker.String())
+	})
+}
+
+func TestCompactExif(t *testing.T) {
+	t.Run("削除したタグの値領域を詰める", func(t *testing.T) {
+		payload := testTIFF{
+			ifd0: []testEntry{asciiEntry(0x010F, "TestMaker"), asciiEntry(0x013B, "Taro Yamada, Photographer")},
+			exif: []testEntry{asciiEntry(0x9003, "2024:01:02 03:04:05"), asciiEntry(0xA430, "Owner Name")},
+			gps:  []testEntry{asciiEntry(0x001B, "GPS-PROCESSING-METHOD")},
+		}.exifPayload()
+		inData := jpegWithExif(t, payload)
+
+		plain, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithRemoveOwnerInfo())
+		require.NoError(t, err)
+		compact, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithRemoveOwnerInfo(), exifremovethumbnail.WithCompactExif())
+		require.NoError(t, err)
+		require.Len(t, res.RemovedTags, 2)
+		// Artist(26バイト)とCameraOwnerName(パディング込み12バイト)の値領域、
+		// およびIFDから外した2エントリ分(24バイト)が消える
+		require.Equal(t, len(plain)-62, len(compact))
+
+		outExif, err := exif.Decode(bytes.NewReader(compact))
+		require.NoError(t, err)
+		for name, want := range map[exif.FieldName]string{
+			exif.Make:             "TestMaker",
+			exif.DateTimeOriginal: "2024:01:02 03:04:05",
+		} {
+			tag, err := outExif.Get(name)
+			require.NoError(t, err)
+			v, err := tag.StringVal()
+			require.NoError(t, err)
+			require.Equal(t, want, v)
+		}
+		_, err = outExif.Get(exif.Artist)
+		require.Error(t, err)
+		_, err = jpeg.Decode(bytes.NewReader(compact))
+		require.NoError(t, err)
+	})
+
+	t.Run("サムネイル削除と併用", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		plain, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithRemoveGPS())
+		require.NoError(t, err)
+		compact, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithRemoveGPS(), exifremovethumbnail.WithCompactExif())
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Less(t, len(compact), len(plain), "ゼロ埋めしたGPS領域が詰められるべき")
+
+		outExif, err := exif.Decode(bytes.NewReader(compact))
+		require.NoError(t, err)
+		_, err = outExif.Get(exif.Software)
+		require.NoError(t, err)
+		_, err = outExif.Get(exif.GPSLatitude)
+		require.Error(t, err)
+	})
+}
+
+func TestThumbnailLayout(t *testing.T) {
+	// 奇数長のサムネイルで、後続データのワード境界が保たれるかも確認する
+	thumbnail := bytes.Repeat([]byte{0xAB}, 301)
+
+	t.Run("値領域より前にあるサムネイルだけを削除する", func(t *testing.T) {
+		for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			// IFD0、Exif IFD、サムネイル、値領域、IFD1の順
+			tt := testTIFF{
+				order:                order,
+				ifd0:                 []testEntry{asciiEntry(0x010F, "TestMaker"), asciiEntry(0x0131, "TestSoftware")},
+				exif:                 []testEntry{asciiEntry(0x9003, "2024:01:02 03:04:05")},
+				ifd1:                 []testEntry{shortEntry(order, 0x0103, 6)},
+				thumbnail:            thumbnail,
+				valuesAfterThumbnail: true,
+				ifd1Last:             true,
+			}
+			inData := jpegWithExif(t, tt.exifPayload())
+
+			outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+			require.NoError(t, err)
+			require.True(t, res.HadThumbnail)
+			require.Empty(t, res.Warnings)
+			require.False(t, bytes.Contains(outData, thumbnail[:300]), "サムネイルは削除されるべき")
+			// IFD1(3エントリ)とサムネイル(偶数長に切り詰めて300バイト)が削除される
+			require.Equal(t, int64(2+3*12+4+300), res.ThumbnailSize)
+
+			outExif, err := exif.Decode(bytes.NewReader(outData))
+			require.NoError(t, err)
+			for name, want := range map[exif.FieldName]string{
+				exif.Make:             "TestMaker",
+				exif.Software:         "TestSoftware",
+				exif.DateTimeOriginal: "2024:01:02 03:04:05",
+			} {
+				tag, err := outExif.Get(name)
+				require.NoError(t, err)
+				v, err := tag.StringVal()
+				require.NoError(t, err)
+				require.Equal(t, want, v)
+			}
+			_, err = outExif.JpegThumbnail()
+			require.Error(t, err, "サムネイルは残らないべき")
+		}
+	})
+
+	t.Run("サムネイルの位置は入力での位置を報告する", func(t *testing.T) {
+		tt := testTIFF{
+			ifd0:                 []testEntry{asciiEntry(0x010F, "TestMaker")},
+			ifd1:                 []testEntry{shortEntry(binary.BigEndian, 0x0103, 6)},
+			thumbnail:            thumbnail,
+			valuesAfterThumbnail: true,
+			ifd1Last:             true,
+		}
+		payload := tt.exifPayload()
+		inData := jpegWithExif(t, payload)
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, thumbnail, inData[res.ThumbnailOffset:res.ThumbnailOffset+301])
+	})
+}
+
+func TestScanHash(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	// SOSからEOIまでのハッシュをAnalyzeの結果から求める
+	report, err := exifremovethumbnail.Analyze(inData)
+	require.NoError(t, err)
+	last := report.Segments[len(report.Segments)-1]
+	sos := last.Offset + last.Size
+	sum := sha256.Sum256(inData[sos : sos+report.ImageDataSize])
+	expected := hex.EncodeToString(sum[:])
+
+	t.Run("入力と出力のスキャンデータのハッシュ", func(t *testing.T) {
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithStripAllExif())
+		require.NoError(t, err)
+		require.Equal(t, expected, result.InputScanHash)
+		require.Equal(t, expected, result.OutputScanHash)
+	})
+
+	t.Run("EOI後のデータは含まない", func(t *testing.T) {
+		withTrailer := append(append([]byte{}, inData...), []byte("TRAILER")...)
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withTrailer)
+		require.NoError(t, err)
+		require.Equal(t, expected, result.InputScanHash)
+		require.Equal(t, expected, result.OutputScanHash)
+	})
+
+	t.Run("SOSに到達しなければ空", func(t *testing.T) {
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData[:int(sos)])
+		require.NoError(t, err)
+		require.Empty(t, result.InputScanHash)
+		require.Empty(t, result.OutputScanHash)
+	})
+}
+
+func TestMakerNoteLayout(t *testing.T) {
+	thumbnail := bytes.Repeat([]byte{0xAB}, 300)
+	// build はIFD1を末尾に置いたEXIFを作る
+	// thumbnailFirstならサムネイルを値領域(MakerNoteを含む)より前に置く
+	build := func(makerNote []byte, thumbnailFirst bool) []byte {
+		return jpegWithExif(t, testTIFF{
+			ifd0:                 []testEntry{asciiEntry(0x010F, "TestMaker")},
+			exif:                 []testEntry{asciiEntry(0x9003, "2024:01:02 03:04:05"), undefinedEntry(0x927C, makerNote)},
+			ifd1:                 []testEntry{shortEntry(binary.BigEndian, 0x0103, 6)},
+			thumbnail:            thumbnail,
+			valuesAfterThumbnail: thumbnailFirst,
+			ifd1Last:             true,
+		}.exifPayload())
+	}
+
+	t.Run("絶対オフセットのMakerNoteは位置を保つ", func(t *testing.T) {
+		makerNote := bytes.Repeat([]byte("CANONMN!"), 16)
+		inData := build(makerNote, true)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, bytes.Index(inData, makerNote), bytes.Index(outData, makerNote), "MakerNoteは移動しないべき")
+		require.False(t, bytes.Contains(outData, thumbnail), "サムネイルはゼロ埋めされるべき")
+		// 末尾のIFD1だけが削除される
+		require.Equal(t, len(inData)-(2+3*12+4), len(outData))
+		require.Equal(t, int64(2+3*12+4+300), res.ThumbnailSize)
+		require.Contains(t, res.Warnings, "MakerNote kept in place; 300 bytes zero-filled instead of removed")
+
+		outExif, err := exif.Decode(bytes.NewReader(outData))
+		require.NoError(t, err)
+		tag, err := outExif.Get(exif.DateTimeOriginal)
+		require.NoError(t, err)
+		v, err := tag.StringVal()
+		require.NoError(t, err)
+		require.Equal(t, "2024:01:02 03:04:05", v)
+	})
+
+	t.Run("自己完結したMakerNoteは移動できる", func(t *testing.T) {
+		makerNote := append([]byte("Nikon\x00\x02\x00"), bytes.Repeat([]byte("NIKONMN!"), 16)...)
+		inData := build(makerNote, true)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, len(inData)-(2+3*12+4+300), len(outData))
+		require.Empty(t, res.Warnings)
+		require.True(t, bytes.Contains(outData, makerNote))
+	})
+
+	t.Run("サムネイルが末尾なら通常どおり削除する", func(t *testing.T) {
+		makerNote := bytes.Repeat([]byte("CANONMN!"), 16)
+		inData := build(makerNote, false)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Empty(t, res.Warnings)
+		require.Equal(t, bytes.Index(inData, makerNote), bytes.Index(outData, makerNote))
+		require.Equal(t, len(inData)-int(res.ThumbnailSize), len(outData))
+	})
+}
+
+func TestRetainedTags(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+
+	t.Run("出力に残ったタグを列挙する", func(t *testing.T) {
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithRemoveGPS(), exifremovethumbnail.WithRetainedTagsReport())
+		require.NoError(t, err)
+		require.Contains(t, result.RetainedTags, exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFD0, ID: 0x0112})
+		require.Contains(t, result.RetainedTags, exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFDExif, ID: 0x9003})
+		for _, ref := range result.RetainedTags {
+			require.NotEqual(t, exifremovethumbnail.IFDGPS, ref.IFD, "GPSは残らないべき")
+			require.NotEqual(t, exifremovethumbnail.IFD1, ref.IFD, "IFD1は残らないべき")
+			require.NotEqual(t, uint16(0x8769), ref.ID, "IFDへのポインタは列挙しない")
+		}
+	})
+
+	t.Run("Orientationだけを残す", func(t *testing.T) {
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithStripAllExif(), exifremovethumbnail.WithKeepOrientation(),
+			exifremovethumbnail.WithRetainedTagsReport())
+		require.NoError(t, err)
+		require.Equal(t, []exifremovethumbnail.TagRef{{IFD: exifremovethumbnail.IFD0, ID: 0x0112}}, result.RetainedTags)
+	})
+
+	t.Run("オプションなしでは列挙しない", func(t *testing.T) {
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Nil(t, result.RetainedTags)
+	})
+
+	t.Run("タグの名前", func(t *testing.T) {
+		require.Equal(t, "Exif.DateTimeOriginal", exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFDExif, ID: 0x9003}.String())
+		require.Equal(t, "GPS.GPSLatitude", exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFDGPS, ID: 0x0002}.String())
+		require.Equal(t, "IFD0.0xC4A5", exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFD0, ID: 0xC4A5}.String())
+		require.Equal(t, "", exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFD0, ID: 0xC4A5}.Name())
+	})
+}
+
+func TestRiskyMakerNote(t *testing.T) {
+	thumbnail := bytes.Repeat([]byte{0xAB}, 300)
+	build := func(makerNote []byte) []byte {
+		return jpegWithExif(t, testTIFF{
+			ifd0:      []testEntry{asciiEntry(0x010F, "TestMaker")},
+			exif:      []testEntry{undefinedEntry(0x927C, makerNote)},
+			ifd1:      []testEntry{shortEntry(binary.BigEndian, 0x0103, 6)},
+			thumbnail: thumbnail,
+		}.exifPayload())
+	}
+	canon := bytes.Repeat([]byte("CANONMN!"), 16)
+
+	t.Run("絶対オフセットのMakerNoteを検出する", func(t *testing.T) {
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(build(canon))
+		require.NoError(t, err)
+		require.True(t, res.RiskyMakerNote)
+		require.False(t, res.Skipped)
+	})
+
+	t.Run("安全オプションでは入力をそのまま返す", func(t *testing.T) {
+		inData := build(canon)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithSkipRiskyMakerNote())
+		require.NoError(t, err)
+		require.Equal(t, inData, outData)
+		require.True(t, res.Skipped)
+		require.Equal(t, exifremovethumbnail.SkipRiskyMakerNote, res.SkipReason)
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, int64(0), res.ThumbnailSize)
+		require.Equal(t, res.BeforeSize, res.AfterSize)
+		require.Contains(t, res.Warnings, "skipped: risky MakerNote relying on absolute offsets")
+	})
+
+	t.Run("ファイルでもスキップしたら入力をそのまま書き出す", func(t *testing.T) {
+		inData := build(canon)
+		dir := t.TempDir()
+		path := filepath.Join(dir, "in.jpg")
+		require.NoError(t, os.WriteFile(path, inData, 0644))
+		out := filepath.Join(dir, "out.jpg")
+		res, err := exifremovethumbnail.ExifRemoveThumbnail(path, out, exifremovethumbnail.WithSkipRiskyMakerNote())
+		require.NoError(t, err)
+		require.True(t, res.Skipped)
+		outData, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.Equal(t, inData, outData)
+	})
+
+	t.Run("自己完結したMakerNoteや削除するMakerNoteは対象外", func(t *testing.T) {
+		nikon := append([]byte("Nikon\x00\x02\x00"), bytes.Repeat([]byte("NIKONMN!"), 16)...)
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(build(nikon), exifremovethumbnail.WithSkipRiskyMakerNote())
+		require.NoError(t, err)
+		require.False(t, res.RiskyMakerNote)
+		require.False(t, res.Skipped)
+
+		_, res, err = exifremovethumbnail.ExifRemoveThumbnailBytes(build(canon),
+			exifremovethumbnail.WithSkipRiskyMakerNote(), exifremovethumbnail.WithRemoveMakerNote())
+		require.NoError(t, err)
+		require.False(t, res.RiskyMakerNote)
+		require.False(t, res.Skipped)
+		require.True(t, res.HadThumbnail)
+	})
+}
+
+func TestDuplicateExif(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	// SOI, APP0-JFIF (2..20), APP1-Exif (20..8607), ...
+	exifPayload := inData[24:8607]
+	single, singleRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	rewritten := single[20 : 20+2+int(binary.BigEndian.Uint16(single[22:24]))]
+	// 先頭にもう一つEXIFセグメントを持つ入力
+	damaged := insertSegment(inData, 0xE1, exifPayload)
+
+	t.Run("すべてのEXIFセグメントからサムネイルを削除する", func(t *testing.T) {
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged)
+		require.NoError(t, err)
+		require.Equal(t, 2, res.ExifSegments)
+		require.Equal(t, 2*singleRes.ThumbnailSize, res.ThumbnailSize)
+		require.Equal(t, 2, bytes.Count(outData, rewritten))
+		require.Equal(t, []string{"duplicate EXIF segment at offset 8607"}, res.Warnings)
+		require.Equal(t, 1, singleRes.ExifSegments)
+	})
+
+	t.Run("厳格モードではエラー", func(t *testing.T) {
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, exifremovethumbnail.WithParseMode(exifremovethumbnail.ParseStrict))
+		var formatErr *exifremovethumbnail.FormatError
+		require.ErrorAs(t, err, &formatErr)
+		require.Equal(t, exifremovethumbnail.CodeStrictViolation, formatErr.Code)
+	})
+
+	t.Run("最初のセグメントだけを残す", func(t *testing.T) {
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged,
+			exifremovethumbnail.WithDedupeExif(), exifremovethumbnail.WithIntegrityCheck())
+		require.NoError(t, err)
+		require.Equal(t, 2, res.ExifSegments)
+		require.Equal(t, 1, bytes.Count(outData, rewritten))
+		require.Equal(t, int64(len(exifPayload)+4), res.RemovedSegments[exifremovethumbnail.SegmentExif])
+		require.Equal(t, singleRes.ThumbnailSize, res.ThumbnailSize)
+	})
+}
+
+func TestFillBytes(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, wantRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	// APP1の前に0xFFの詰め物を挟む
+	fill := []byte{0xFF, 0xFF, 0xFF}
+	padded := append(append(append([]byte{}, inData[:20]...), fill...), inData[20:]...)
+
+	for name, mode := range map[string]exifremovethumbnail.ParseMode{
+		"既定": exifremovethumbnail.ParseDefault,
+		"厳格": exifremovethumbnail.ParseStrict,
+		"寛容": exifremovethumbnail.ParseLenient,
+	} {
+		t.Run(name+"モードで詰め物を読み飛ばす", func(t *testing.T) {
+			outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(padded,
+				exifremovethumbnail.WithParseMode(mode), exifremovethumbnail.WithIntegrityCheck())
+			require.NoError(t, err)
+			require.Equal(t, want, outData)
+			require.Empty(t, res.Warnings)
+			require.Equal(t, wantRes.ThumbnailSize, res.ThumbnailSize)
+		})
+	}
+
+	t.Run("解析と検出も詰め物を読み飛ばす", func(t *testing.T) {
+		report, err := exifremovethumbnail.Analyze(padded)
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.SegmentExif, report.Segments[2].Name)
+		require.Equal(t, int64(23), report.Segments[2].Offset)
+		require.Greater(t, report.ThumbnailSize, int64(0))
+
+		_, found, err := exifremovethumbnail.DetectThumbnail(bytes.NewReader(padded))
+		require.NoError(t, err)
+		require.True(t, found)
+	})
+
+	t.Run("詰め物の途中で終わるデータ", func(t *testing.T) {
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(append(append([]byte{}, inData[:20]...), fill...))
+		require.ErrorIs(t, err, exifremovethumbnail.ErrTruncated)
+	})
+}
+
+func TestTruncatedInput(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	fullOut, fullRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	require.False(t, fullRes.Truncated)
+	strict := exifremovethumbnail.WithParseMode(exifremovethumbnail.ParseStrict)
+	allow := exifremovethumbnail.WithAllowTruncated()
+
+	t.Run("画像データの途中で切れたファイル", func(t *testing.T) {
+		damaged := inData[:len(inData)-100]
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, strict)
+		require.Error(t, err)
+		require.True(t, res.Truncated)
+
+		for _, opts := range [][]exifremovethumbnail.Option{nil, {allow}, {strict, allow}} {
+			outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, opts...)
+			require.NoError(t, err)
+			require.Equal(t, fullOut[:len(fullOut)-100], outData, "あるだけのデータをコピーするべき")
+			require.True(t, res.Truncated)
+			require.True(t, res.HadThumbnail)
+			require.Equal(t, []string{"missing EOI marker"}, res.Warnings)
+		}
+	})
+
+	t.Run("EXIFセグメントの途中で切れたファイル", func(t *testing.T) {
+		damaged := inData[:1000]
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged)
+		var segmentErr *exifremovethumbnail.SegmentError
+		require.ErrorAs(t, err, &segmentErr)
+		require.True(t, res.Truncated)
+
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, strict, allow)
+		require.NoError(t, err)
+		require.Equal(t, inData[:20], outData)
+		require.True(t, res.Truncated)
+		require.Equal(t, []string{"truncated segment 0xFFE1 dropped"}, res.Warnings)
+	})
+
+	t.Run("画像データの前で切れたファイル", func(t *testing.T) {
+		damaged := inData[:8607]
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, strict)
+		require.Error(t, err)
+		require.True(t, res.Truncated)
+
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, allow)
+		require.NoError(t, err)
+		require.Equal(t, fullOut[:len(fullOut)-(len(inData)-8607)], outData)
+		require.True(t, res.Truncated)
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, []string{"JPEG data ends before the image data"}, res.Warnings)
+	})
+
+	t.Run("入力より短い出力もファイルに書き込む", func(t *testing.T) {
+		noneData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_none.jpg"))
+		require.NoError(t, err)
+		dir := t.TempDir()
+		inputPath := filepath.Join(dir, "in.jpg")
+		outputPath := filepath.Join(dir, "out.jpg")
+		require.NoError(t, os.WriteFile(inputPath, noneData[:30], 0644))
+
+		res, err := exifremovethumbnail.ExifRemoveThumbnail(inputPath, outputPath, allow)
+		require.NoError(t, err)
+		require.True(t, res.Truncated)
+		require.Equal(t, int64(20), res.AfterSize)
+		outData, err := os.ReadFile(outputPath)
+		require.NoError(t, err)
+		require.Equal(t, noneData[:20], outData)
+	})
+}
+
+func TestLooseExifHeader(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	// SOI, APP0-JFIF (2..20), APP1-Exif (20..8607), ...
+	tiff := inData[30:8607]
+	want, wantRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	lenient := exifremovethumbnail.WithParseMode(exifremovethumbnail.ParseLenient)
+
+	for name, header := range map[string]string{
+		"二つ目のNULがない": "Exif\x00",
+		"二つ目が0xFF":   "Exif\x00\xFF",
+	} {
+		t.Run(name, func(t *testing.T) {
+			payload := append([]byte(header), tiff...)
+			damaged := append(append(append([]byte{}, inData[:20]...), 0xFF, 0xE1, byte((len(payload)+2)>>8), byte(len(payload)+2)), payload...)
+			damaged = append(damaged, inData[8607:]...)
+
+			_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged)
+			require.NoError(t, err)
+			require.False(t, res.HadThumbnail, "既定ではEXIFとして扱わない")
+
+			outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, lenient, exifremovethumbnail.WithIntegrityCheck())
+			require.NoError(t, err)
+			require.Equal(t, want, outData, "標準のヘッダーに直して処理するべき")
+			require.True(t, res.HadThumbnail)
+			require.Equal(t, wantRes.ThumbnailOffset-int64(6-len(header)), res.ThumbnailOffset)
+			require.Len(t, res.Warnings, 1)
+			require.True(t, strings.HasPrefix(res.Warnings[0], "non-standard EXIF header "))
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	cleanOut, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	// afterSOI はSOI直後にバイト列を挟んだJPEGデータを返す
+	afterSOI := func(b []byte) []byte {
+		return append(append(append([]byte{}, inData[:2]...), b...), inData[2:]...)
+	}
+	strict := exifremovethumbnail.WithParseMode(exifremovethumbnail.ParseStrict)
+	lenient := exifremovethumbnail.WithParseMode(exifremovethumbnail.ParseLenient)
+	var formatErr *exifremovethumbnail.FormatError
+
+	t.Run("厳格モードは正常なファイルを処理する", func(t *testing.T) {
+		outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, strict)
+		require.NoError(t, err)
+		require.Equal(t, cleanOut, outData)
+	})
+
+	t.Run("厳格モードは警告となる違反も拒否する", func(t *testing.T) {
+		withTrailer := append(append([]byte{}, inData...), []byte("TRAILER")...)
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withTrailer, strict)
+		require.ErrorAs(t, err, &formatErr)
+		require.Equal(t, "strict: 7 bytes of trailing data after EOI", err.Error())
+
+		_, _, err = exifremovethumbnail.ExifRemoveThumbnailBytes(inData[:len(inData)-2], strict)
+		require.ErrorAs(t, err, &formatErr)
+	})
+
+	t.Run("マーカー間のゴミ", func(t *testing.T) {
+		damaged := afterSOI([]byte("GARBAGE"))
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged)
+		require.ErrorAs(t, err, &formatErr)
+
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, lenient)
+		require.NoError(t, err)
+		require.Equal(t, cleanOut, outData)
+		require.Len(t, res.Warnings, 1)
+		require.Contains(t, res.Warnings[0], "7 bytes of garbage before marker")
+	})
+
+	t.Run("フィルバイトは警告しない", func(t *testing.T) {
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(afterSOI([]byte{0xFF, 0xFF}), lenient)
+		require.NoError(t, err)
+		require.Equal(t, cleanOut, outData)
+		require.Empty(t, res.Warnings)
+	})
+
+	t.Run("不正なセグメント長", func(t *testing.T) {
+		damaged := afterSOI([]byte{0xFF, 0xFE, 0x00, 0x00})
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged)
+		require.ErrorAs(t, err, &formatErr)
+
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, lenient)
+		require.NoError(t, err)
+		require.Equal(t, cleanOut, outData)
+		require.Equal(t, []string{"invalid segment length 0 of marker 0xFFFE skipped"}, res.Warnings)
+	})
+
+	t.Run("解析できないEXIFはそのまま残す", func(t *testing.T) {
+		broken := append([]byte("Exif\x00\x00"), []byte("XXXXXXXX")...)
+		damaged := insertSegment(inData, 0xE1, broken)
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged)
+		require.Error(t, err)
+
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, lenient)
+		require.NoError(t, err)
+		require.True(t, bytes.Contains(outData, broken), "壊れたEXIFはそのまま残るべき")
+		require.True(t, res.HadThumbnail, "後続のEXIFは処理されるべき")
+		require.Len(t, res.Warnings, 2)
+		require.True(t, strings.HasPrefix(res.Warnings[0], "EXIF segment kept unchanged: "))
+		require.True(t, strings.HasPrefix(res.Warnings[1], "duplicate EXIF segment at offset "))
+	})
+
+	t.Run("途中で切れたセグメント", func(t *testing.T) {
+		damaged := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00, 'E', 'x', 'i', 'f'}
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, lenient)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0xFF, 0xD8}, outData)
+		require.Equal(t, []string{"truncated segment 0xFFE1 dropped"}, res.Warnings)
+	})
+}
+
+// extendedExif はEXIFペイロードを拡張EXIFとして複数のAPP1セグメントに分割する
+func extendedExif(payload []byte) []byte {
+	const max = 0xFFFF - 2
+	var out []byte
+	segment := func(p []byte) {
+		out = append(out, 0xFF, 0xE1, byte((len(p)+2)>>8), byte(len(p)+2))
+		out = append(out, p...)
+	}
+	segment(payload[:max])
+	for rest := payload[max:]; len(rest) > 0; {
+		n := min(len(rest), max-6)
+		segment(append([]byte("Exif\x00\x00"), rest[:n]...))
+		rest = rest[n:]
+	}
+	return out
+}
+
+func TestExtendedExif(t *testing.T) {
+	thumbnail := append([]byte{0xFF, 0xD8}, bytes.Repeat([]byte{0xAB}, 5000)...)
+	tt := testTIFF{
+		ifd0:      []testEntry{asciiEntry(0x010F, "Drone")},
+		exif:      []testEntry{undefinedEntry(0x9286, bytes.Repeat([]byte{0x55}, 150000))},
+		ifd1:      []testEntry{shortEntry(binary.BigEndian, 0x0103, 6)},
+		thumbnail: thumbnail,
+	}
+	payload := tt.exifPayload()
+	data, err := os.ReadFile(filepath.Join("testdata", "metadata_none.jpg"))
+	require.NoError(t, err)
+	app0End := 4 + int(binary.BigEndian.Uint16(data[4:6]))
+	inData := append(append(append([]byte{}, data[:app0End]...), extendedExif(payload)...), data[app0End:]...)
+
+	outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+		exifremovethumbnail.WithRetainedTagsReport(), exifremovethumbnail.WithIntegrityCheck())
+	require.NoError(t, err)
+	require.True(t, res.HadThumbnail, "継続セグメントにあるサムネイルも見つかるべき")
+	require.GreaterOrEqual(t, res.ThumbnailSize, int64(len(thumbnail)))
+	require.Equal(t, 1, res.ExifSegments, "継続セグメントは重複として数えない")
+	require.Empty(t, res.Warnings)
+	require.Equal(t, thumbnail, inData[res.ThumbnailOffset:res.ThumbnailOffset+int64(len(thumbnail))], "入力中の位置を指すべき")
+	require.Contains(t, res.RetainedTags, exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFDExif, ID: 0x9286})
+
+	t.Run("書き込み時に再分割される", func(t *testing.T) {
+		report, err := exifremovethumbnail.Analyze(outData)
+		require.NoError(t, err)
+		app1 := 0
+		for _, s := range report.Segments {
+			if s.Name == exifremovethumbnail.SegmentExif || s.Name == "APP1" {
+				app1++
+				require.LessOrEqual(t, s.Size, int64(0xFFFF+2))
+			}
+		}
+		require.Equal(t, 3, app1)
+
+		again, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(outData)
+		require.NoError(t, err)
+		require.False(t, res.HadThumbnail)
+		require.Equal(t, outData, again)
+	})
+}
+
+func TestUnchanged(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	processed, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	require.False(t, res.Unchanged)
+
+	t.Run("処理済みのファイルはそのまま返す", func(t *testing.T) {
+		out, again, err := exifremovethumbnail.ExifRemoveThumbnailBytes(processed)
+		require.NoError(t, err)
+		require.True(t, again.Unchanged)
+		require.False(t, again.HadThumbnail)
+		require.Equal(t, processed, out)
+		require.Same(t, &processed[0], &out[0], "コピーせず入力をそのまま返す")
+		require.Equal(t, res.Exif, again.Exif)
+		require.Equal(t, res.InputScanHash, again.InputScanHash)
+		require.Equal(t, again.InputScanHash, again.OutputScanHash)
+		require.Equal(t, int64(len(processed)), again.AfterSize)
+		require.Equal(t, 1, again.ExifSegments)
+	})
+
+	t.Run("変更が必要なら通常どおり処理する", func(t *testing.T) {
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(processed, exifremovethumbnail.WithStripAllExif())
+		require.NoError(t, err)
+		require.False(t, res.Unchanged)
+		require.Less(t, len(out), len(processed))
+
+		// 詰め物のバイトは取り除かれる
+		padded := append(append(append([]byte{}, processed[:20]...), 0xFF, 0xFF), processed[20:]...)
+		out, res, err = exifremovethumbnail.ExifRemoveThumbnailBytes(padded)
+		require.NoError(t, err)
+		require.False(t, res.Unchanged)
+		require.Equal(t, processed, out)
+	})
+
+	t.Run("同じファイルへは書き戻さない", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.jpg")
+		require.NoError(t, os.WriteFile(path, processed, 0644))
+		past := time.Now().Add(-time.Hour).Truncate(time.Second)
+		require.NoError(t, os.Chtimes(path, past, past))
+
+		res, err := exifremovethumbnail.ExifRemoveThumbnail(path, path)
+		require.NoError(t, err)
+		require.True(t, res.Unchanged)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, past, info.ModTime())
+
+		other := filepath.Join(dir, "b.jpg")
+		_, err = exifremovethumbnail.ExifRemoveThumbnail(path, other)
+		require.NoError(t, err)
+		data, err := os.ReadFile(other)
+		require.NoError(t, err)
+		require.Equal(t, processed, data)
+	})
+}
+
+func TestStreamFile(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	// SOSの直後に大きな画像データを挿入する
+	sos := bytes.LastIndex(inData, []byte{0xFF, 0xDA})
+	sos += 2 + int(binary.BigEndian.Uint16(inData[sos+2:]))
+	const scanSize = 32 << 20
+	big := append(append(append([]byte{}, inData[:sos]...), make([]byte, scanSize)...), inData[sos:]...)
+	want, wantRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(big)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.jpg")
+	require.NoError(t, os.WriteFile(path, big, 0644))
+
+	t.Run("画像データをメモリに読み込まない", func(t *testing.T) {
+		out := filepath.Join(dir, "out.jpg")
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		res, err := exifremovethumbnail.ExifRemoveThumbnail(path, out, exifremovethumbnail.WithVerifyImageDataUnchanged())
+		runtime.ReadMemStats(&after)
+		require.NoError(t, err)
+		require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(scanSize/8))
+
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, wantRes.AfterSize, res.AfterSize)
+		require.Equal(t, int64(len(big)), res.BeforeSize)
+		require.Equal(t, wantRes.OutputScanHash, res.OutputScanHash)
+		got, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(want, got))
+	})
+
+	t.Run("出力全体を調べるオプションではメモリ上で処理する", func(t *testing.T) {
+		out := filepath.Join(dir, "checked.jpg")
+		_, err := exifremovethumbnail.ExifRemoveThumbnail(path, out, exifremovethumbnail.WithIntegrityCheck())
+		require.NoError(t, err)
+		got, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(want, got))
+	})
+}
+
+func TestConstantSize(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	sos := bytes.LastIndex(inData, []byte{0xFF, 0xDA})
+
+	t.Run("サイズと画像データの位置を保つ", func(t *testing.T) {
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithConstantSize())
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Len(t, out, len(inData))
+		require.Equal(t, res.BeforeSize, res.AfterSize)
+		require.Equal(t, inData[sos:], out[sos:])
+		require.False(t, exifremovethumbnail.FindExifBlocks(out)[0].HasThumbnail)
+		_, err = jpeg.Decode(bytes.NewReader(out))
+		require.NoError(t, err)
+
+		report, err := exifremovethumbnail.Analyze(out)
+		require.NoError(t, err)
+		var names []exifremovethumbnail.Segment
+		for _, s := range report.Segments {
+			names = append(names, exifremovethumbnail.Segment(s.Name))
+		}
+		require.Contains(t, names, exifremovethumbnail.SegmentPadding)
+
+		again, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(out, exifremovethumbnail.WithConstantSize())
+		require.NoError(t, err)
+		require.True(t, res.Unchanged)
+		require.Equal(t, out, again)
+	})
+
+	t.Run("タグの削除でもサイズを保つ", func(t *testing.T) {
+		inData := jpegWithExif(t, testTIFF{ifd0: []testEntry{asciiEntry(0x010F, "TestMaker"), asciiEntry(0x013B, "Owner")}}.exifPayload())
+		out, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithRemoveOwnerInfo(), exifremovethumbnail.WithCompactExif(), exifremovethumbnail.WithConstantSize())
+		require.NoError(t, err)
+		require.Len(t, out, len(inData))
+		sos := bytes.LastIndex(inData, []byte{0xFF, 0xDA})
+		require.Equal(t, inData[sos:], out[sos:])
+		require.NotContains(t, string(out[:sos]), "Owner")
+	})
+}
+
+func TestEOIScan(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_none.jpg"))
+	require.NoError(t, err)
+	sos := bytes.LastIndex(inData, []byte{0xFF, 0xDA})
+	header := inData[:sos+2]
+
+	// EOIがコピーウィンドウの境目をまたいでも見つける
+	for _, eoi := range [][]byte{{0xFF, 0xD9}, {0xFF, 0xFF, 0xD9}, {0xFF, 0x00, 0xFF, 0xD9}} {
+		for shift := 505; shift <= 515; shift++ {
+			scan := append(bytes.Repeat([]byte{0x12}, shift), eoi...)
+			data := append(append(append([]byte{}, header...), scan...), "ABCDE"...)
+			_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(data, exifremovethumbnail.WithWindowSize(512))
+			require.NoError(t, err)
+			require.Contains(t, res.Warnings, "5 bytes of trailing data after EOI", "shift %d", shift)
+			sum := sha256.Sum256(append([]byte{0xFF, 0xDA}, scan...))
+			require.Equal(t, hex.EncodeToString(sum[:]), res.InputScanHash)
+			require.Equal(t, res.InputScanHash, exifremovethumbnail.ScanHash(data))
+		}
+	}
+}
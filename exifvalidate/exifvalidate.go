@@ -0,0 +1,276 @@
+// Package exifvalidate lints the structure of TIFF/EXIF data.
+//
+// It checks what decoders rely on: offsets within the data, known field types,
+// sub-IFD pointers and a consistent IFD1 thumbnail. It shares no parsing code
+// with exifremovethumbnail, so it can verify the package's own outputs as well
+// as its inputs, for example in a CI pipeline:
+//
+//	issues, err := exifvalidate.ValidateJPEG(output)
+//	if err != nil || len(issues) > 0 {
+//		// fail the build
+//	}
+package exifvalidate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+// typeSizes maps TIFF field types to the byte size of a single value.
+// Type 13 is the IFD type of TIFF-EP, used by some writers for sub-IFD pointers.
+var typeSizes = map[uint16]int64{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4,
+}
+
+// Issue is a structural problem found in TIFF data.
+type Issue struct {
+	// Offset is the position of the problem within the TIFF data.
+	Offset int64
+	// IFD is the directory holding the problem. Header problems are reported for IFD0.
+	IFD exifremovethumbnail.IFD
+	// Tag is the tag of the offending entry, or 0 for the directory itself.
+	Tag     uint16
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Tag != 0 {
+		return fmt.Sprintf("%s tag 0x%04X at offset %d: %s", i.IFD, i.Tag, i.Offset, i.Message)
+	}
+	return fmt.Sprintf("%s at offset %d: %s", i.IFD, i.Offset, i.Message)
+}
+
+// ErrNotJPEG is returned by ValidateJPEG for data without a SOI marker.
+var ErrNotJPEG = errors.New("not a JPEG file")
+
+// ValidateJPEG lints every EXIF APP1 segment of JPEG data, stitching extended
+// EXIF continued over several segments. Offsets in the issues are relative to
+// the TIFF data of each segment. The error reports JPEG data that cannot be
+// split into segments.
+func ValidateJPEG(data []byte) ([]Issue, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrNotJPEG
+	}
+	var blocks [][]byte
+	pos := 2
+	for pos+4 <= len(data) {
+		marker := binary.BigEndian.Uint16(data[pos:])
+		if marker == 0xFFFF {
+			pos++
+			continue
+		}
+		if marker == spec.SOS || marker == spec.EOI {
+			break
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) || end < pos+4 {
+			return nil, fmt.Errorf("malformed segment at offset %d", pos)
+		}
+		payload := data[pos+4 : end]
+		if marker == spec.APP1 && bytes.HasPrefix(payload, []byte(spec.ExifHeader)) {
+			body := payload[len(spec.ExifHeader):]
+			if isTIFFHeader(body) || len(blocks) == 0 {
+				blocks = append(blocks, append([]byte{}, body...))
+			} else {
+				blocks[len(blocks)-1] = append(blocks[len(blocks)-1], body...)
+			}
+		}
+		pos = end
+	}
+	var issues []Issue
+	for _, b := range blocks {
+		issues = append(issues, Validate(b)...)
+	}
+	return issues, nil
+}
+
+// Validate lints TIFF data as stored in an EXIF segment after the EXIF header.
+// A leading EXIF header is skipped. It returns nil for valid data.
+func Validate(data []byte) []Issue {
+	data = bytes.TrimPrefix(data, []byte(spec.ExifHeader))
+	v := &validator{data: data, visited: map[int64]bool{}}
+	v.run()
+	return v.issues
+}
+
+func isTIFFHeader(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*"))
+}
+
+// entry is a single 12-byte IFD entry.
+type entry struct {
+	pos   int64
+	tag   uint16
+	typ   uint16
+	count uint32
+	value uint32
+}
+
+type validator struct {
+	data    []byte
+	order   binary.ByteOrder
+	issues  []Issue
+	visited map[int64]bool
+}
+
+func (v *validator) report(offset int64, ifd exifremovethumbnail.IFD, tag uint16, format string, args ...any) {
+	v.issues = append(v.issues, Issue{Offset: offset, IFD: ifd, Tag: tag, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) run() {
+	if len(v.data) < 8 {
+		v.report(0, exifremovethumbnail.IFD0, 0, "TIFF header is truncated")
+		return
+	}
+	switch string(v.data[:2]) {
+	case "II":
+		v.order = binary.LittleEndian
+	case "MM":
+		v.order = binary.BigEndian
+	default:
+		v.report(0, exifremovethumbnail.IFD0, 0, "invalid byte order %q", v.data[:2])
+		return
+	}
+	if v.order.Uint16(v.data[2:]) != 42 {
+		v.report(2, exifremovethumbnail.IFD0, 0, "invalid TIFF magic number %d", v.order.Uint16(v.data[2:]))
+		return
+	}
+	ifd0 := int64(v.order.Uint32(v.data[4:]))
+	entries, next, ok := v.readIFD(ifd0, exifremovethumbnail.IFD0)
+	if !ok {
+		return
+	}
+	for _, e := range entries {
+		switch e.tag {
+		case spec.TagExifIFD:
+			if exif, ok := v.subIFD(e, exifremovethumbnail.IFD0, exifremovethumbnail.IFDExif); ok {
+				for _, e := range exif {
+					if e.tag == spec.TagInteropIFD {
+						v.subIFD(e, exifremovethumbnail.IFDExif, exifremovethumbnail.IFDInterop)
+					}
+				}
+			}
+		case spec.TagGPSIFD:
+			v.subIFD(e, exifremovethumbnail.IFD0, exifremovethumbnail.IFDGPS)
+		}
+	}
+	if next != 0 {
+		if ifd1, _, ok := v.readIFD(next, exifremovethumbnail.IFD1); ok {
+			v.checkThumbnail(ifd1)
+		}
+	}
+}
+
+// readIFD reads and checks the directory at offset. It returns the entries and
+// the offset of the next directory, or false if the directory is unusable.
+// Only the link from IFD0 to IFD1 is followed; decoders ignore the links of
+// sub-IFDs and IFD1, which many writers leave uninitialized.
+func (v *validator) readIFD(offset int64, ifd exifremovethumbnail.IFD) ([]entry, int64, bool) {
+	if offset < 8 || offset+2 > int64(len(v.data)) {
+		v.report(offset, ifd, 0, "directory offset out of range")
+		return nil, 0, false
+	}
+	if v.visited[offset] {
+		v.report(offset, ifd, 0, "directory is referenced more than once")
+		return nil, 0, false
+	}
+	v.visited[offset] = true
+	n := int64(v.order.Uint16(v.data[offset:]))
+	end := offset + 2 + n*12
+	if end+4 > int64(len(v.data)) {
+		v.report(offset, ifd, 0, "directory of %d entries extends past the end of the data", n)
+		return nil, 0, false
+	}
+	entries := make([]entry, 0, n)
+	var prev uint16
+	for i := int64(0); i < n; i++ {
+		pos := offset + 2 + i*12
+		e := entry{
+			pos:   pos,
+			tag:   v.order.Uint16(v.data[pos:]),
+			typ:   v.order.Uint16(v.data[pos+2:]),
+			count: v.order.Uint32(v.data[pos+4:]),
+			value: v.order.Uint32(v.data[pos+8:]),
+		}
+		if i > 0 && e.tag <= prev {
+			v.report(pos, ifd, e.tag, "tags are not in ascending order")
+		}
+		prev = e.tag
+		size, ok := typeSizes[e.typ]
+		if !ok {
+			v.report(pos, ifd, e.tag, "invalid field type %d", e.typ)
+			continue
+		}
+		if total := size * int64(e.count); total > 4 && int64(e.value)+total > int64(len(v.data)) {
+			v.report(pos, ifd, e.tag, "value of %d bytes at offset %d extends past the end of the data", total, e.value)
+		}
+		entries = append(entries, e)
+	}
+	next := int64(v.order.Uint32(v.data[end:]))
+	if ifd != exifremovethumbnail.IFD0 {
+		return entries, 0, true
+	}
+	if next != 0 && (next < 8 || next+2 > int64(len(v.data))) {
+		v.report(end, ifd, 0, "next directory offset %d out of range", next)
+		next = 0
+	}
+	return entries, next, true
+}
+
+// subIFD checks the pointer e in parent and reads the directory it points to.
+func (v *validator) subIFD(e entry, parent, ifd exifremovethumbnail.IFD) ([]entry, bool) {
+	if (e.typ != 4 && e.typ != 13) || e.count != 1 {
+		v.report(e.pos, parent, e.tag, "%s pointer must be a single LONG", ifd)
+		return nil, false
+	}
+	entries, _, ok := v.readIFD(int64(e.value), ifd)
+	return entries, ok
+}
+
+// checkThumbnail checks that the JPEG thumbnail described by IFD1 is complete.
+func (v *validator) checkThumbnail(entries []entry) {
+	var offset, length *entry
+	var compression uint16
+	for i, e := range entries {
+		switch e.tag {
+		case spec.TagJPEGInterchangeFormat:
+			offset = &entries[i]
+		case spec.TagJPEGInterchangeFormatLength:
+			length = &entries[i]
+		case spec.TagCompression:
+			if e.typ == 3 {
+				compression = v.order.Uint16(v.data[e.pos+8:])
+			}
+		}
+	}
+	switch {
+	case offset == nil && length == nil:
+		if compression == 6 {
+			v.report(0, exifremovethumbnail.IFD1, spec.TagJPEGInterchangeFormat, "JPEG compression without a thumbnail")
+		}
+		return
+	case offset == nil:
+		v.report(length.pos, exifremovethumbnail.IFD1, length.tag, "thumbnail length without an offset")
+		return
+	case length == nil:
+		v.report(offset.pos, exifremovethumbnail.IFD1, offset.tag, "thumbnail offset without a length")
+		return
+	}
+	start, size := int64(offset.value), int64(length.value)
+	if length.typ == 3 {
+		size = int64(v.order.Uint16(v.data[length.pos+8:]))
+	}
+	if start+size > int64(len(v.data)) {
+		v.report(offset.pos, exifremovethumbnail.IFD1, offset.tag, "thumbnail of %d bytes at offset %d extends past the end of the data", size, start)
+		return
+	}
+	if size < 2 || v.data[start] != 0xFF || v.data[start+1] != 0xD8 {
+		v.report(offset.pos, exifremovethumbnail.IFD1, offset.tag, "thumbnail at offset %d does not start with SOI", start)
+	}
+}
@@ -0,0 +1,142 @@
+package exifvalidate_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+	"github.com/ideamans/go-exif-remove-thumbnail/exifvalidate"
+)
+
+// tiffWithThumbnail はIFD0にOrientation、IFD1に4バイトのサムネイルを持つビッグエンディアンのTIFFを作る
+//
+//	0: ヘッダ, 8: IFD0 (1エントリ), 26: IFD1 (2エントリ), 56: サムネイル
+func tiffWithThumbnail() []byte {
+	be := binary.BigEndian
+	b := make([]byte, 60)
+	copy(b, "MM\x00*")
+	be.PutUint32(b[4:], 8)
+	be.PutUint16(b[8:], 1)
+	be.PutUint16(b[10:], 0x0112)
+	be.PutUint16(b[12:], 3)
+	be.PutUint32(b[14:], 1)
+	be.PutUint16(b[18:], 1)
+	be.PutUint32(b[22:], 26)
+	be.PutUint16(b[26:], 2)
+	be.PutUint16(b[28:], 0x0201)
+	be.PutUint16(b[30:], 4)
+	be.PutUint32(b[32:], 1)
+	be.PutUint32(b[36:], 56)
+	be.PutUint16(b[40:], 0x0202)
+	be.PutUint16(b[42:], 4)
+	be.PutUint32(b[44:], 1)
+	be.PutUint32(b[48:], 4)
+	copy(b[56:], []byte{0xFF, 0xD8, 0xFF, 0xD9})
+	return b
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("正しいTIFFには問題がない", func(t *testing.T) {
+		require.Empty(t, exifvalidate.Validate(tiffWithThumbnail()))
+		require.Empty(t, exifvalidate.Validate(append([]byte("Exif\x00\x00"), tiffWithThumbnail()...)), "EXIFヘッダは読み飛ばす")
+	})
+
+	tests := []struct {
+		name   string
+		damage func(b []byte) []byte
+		want   exifvalidate.Issue
+	}{
+		{
+			name:   "不正なバイトオーダー",
+			damage: func(b []byte) []byte { b[0] = 'X'; return b },
+			want:   exifvalidate.Issue{Offset: 0, IFD: exifremovethumbnail.IFD0, Message: `invalid byte order "XM"`},
+		},
+		{
+			name:   "範囲外のIFD0",
+			damage: func(b []byte) []byte { binary.BigEndian.PutUint32(b[4:], 1000); return b },
+			want:   exifvalidate.Issue{Offset: 1000, IFD: exifremovethumbnail.IFD0, Message: "directory offset out of range"},
+		},
+		{
+			name:   "不正な型",
+			damage: func(b []byte) []byte { binary.BigEndian.PutUint16(b[12:], 99); return b },
+			want:   exifvalidate.Issue{Offset: 10, IFD: exifremovethumbnail.IFD0, Tag: 0x0112, Message: "invalid field type 99"},
+		},
+		{
+			name: "範囲外の値",
+			damage: func(b []byte) []byte {
+				binary.BigEndian.PutUint32(b[14:], 10)
+				binary.BigEndian.PutUint32(b[18:], 50)
+				return b
+			},
+			want: exifvalidate.Issue{Offset: 10, IFD: exifremovethumbnail.IFD0, Tag: 0x0112, Message: "value of 20 bytes at offset 50 extends past the end of the data"},
+		},
+		{
+			name:   "IFD1がIFD0を指す循環",
+			damage: func(b []byte) []byte { binary.BigEndian.PutUint32(b[22:], 8); return b },
+			want:   exifvalidate.Issue{Offset: 8, IFD: exifremovethumbnail.IFD1, Message: "directory is referenced more than once"},
+		},
+		{
+			name:   "途中で切れたサムネイル",
+			damage: func(b []byte) []byte { return b[:58] },
+			want:   exifvalidate.Issue{Offset: 28, IFD: exifremovethumbnail.IFD1, Tag: 0x0201, Message: "thumbnail of 4 bytes at offset 56 extends past the end of the data"},
+		},
+		{
+			name:   "長さのないサムネイル",
+			damage: func(b []byte) []byte { binary.BigEndian.PutUint16(b[40:], 0x0203); return b },
+			want:   exifvalidate.Issue{Offset: 28, IFD: exifremovethumbnail.IFD1, Tag: 0x0201, Message: "thumbnail offset without a length"},
+		},
+		{
+			name:   "SOIで始まらないサムネイル",
+			damage: func(b []byte) []byte { b[57] = 0; return b },
+			want:   exifvalidate.Issue{Offset: 28, IFD: exifremovethumbnail.IFD1, Tag: 0x0201, Message: "thumbnail at offset 56 does not start with SOI"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, []exifvalidate.Issue{tt.want}, exifvalidate.Validate(tt.damage(tiffWithThumbnail())))
+		})
+	}
+
+	t.Run("問題の表示", func(t *testing.T) {
+		issue := exifvalidate.Issue{Offset: 10, IFD: exifremovethumbnail.IFD0, Tag: 0x0112, Message: "invalid field type 99"}
+		require.Equal(t, "IFD0 tag 0x0112 at offset 10: invalid field type 99", issue.String())
+	})
+}
+
+func TestValidateJPEG(t *testing.T) {
+	t.Run("テスト画像の入力と出力に問題がない", func(t *testing.T) {
+		for _, name := range []string{"metadata_basic_exif.jpg", "metadata_full_exif.jpg", "metadata_gps.jpg", "metadata_none.jpg", "thumbnail_embedded.jpg", "thumbnail_none.jpg"} {
+			inData, err := os.ReadFile(filepath.Join("..", "testdata", name))
+			require.NoError(t, err)
+			issues, err := exifvalidate.ValidateJPEG(inData)
+			require.NoError(t, err)
+			require.Empty(t, issues, name)
+
+			outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+			require.NoError(t, err)
+			issues, err = exifvalidate.ValidateJPEG(outData)
+			require.NoError(t, err)
+			require.Empty(t, issues, name)
+		}
+	})
+
+	t.Run("EXIFセグメントの問題を報告する", func(t *testing.T) {
+		tiff := tiffWithThumbnail()[:58]
+		payload := append([]byte("Exif\x00\x00"), tiff...)
+		data := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}, payload...)
+		data = append(data, 0xFF, 0xD9)
+		issues, err := exifvalidate.ValidateJPEG(data)
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		require.Equal(t, exifremovethumbnail.IFD1, issues[0].IFD)
+	})
+
+	t.Run("JPEGでなければエラー", func(t *testing.T) {
+		_, err := exifvalidate.ValidateJPEG([]byte("GIF89a"))
+		require.ErrorIs(t, err, exifvalidate.ErrNotJPEG)
+	})
+}
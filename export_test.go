@@ -0,0 +1,13 @@
+package exifremovethumbnail
+
+// CheckIntegrity exposes checkIntegrity with the default options to the tests.
+func CheckIntegrity(input, output []byte) error {
+	return checkIntegrity(input, output, ExifRemoveThumbnailResult{}, newOptions(nil))
+}
+
+// SetFreeSpace replaces the free space lookup for the tests and returns a function restoring it.
+func SetFreeSpace(f func(dir string) (int64, error)) func() {
+	saved := freeSpace
+	freeSpace = f
+	return func() { freeSpace = saved }
+}
@@ -0,0 +1,105 @@
+package exifremovethumbnail
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Fault is a failure simulated by WithFaultInjection.
+type Fault int
+
+const (
+	// FaultRead makes reading the input fail, as a network file system may
+	// fail in the middle of a file.
+	FaultRead Fault = iota + 1
+	// FaultWrite makes writing the output fail, as on a full disk.
+	FaultWrite
+	// FaultRename makes ExifRemoveThumbnail fail to move the finished output
+	// into place. The temporary file is removed and an existing output file
+	// is left unchanged.
+	FaultRename
+)
+
+// ErrInjectedFault is the error returned for failures simulated by WithFaultInjection.
+var ErrInjectedFault = errors.New("injected fault")
+
+// WithFaultInjection simulates a failure so that callers can test their retry
+// and rollback handling deterministically. For FaultRead and FaultWrite the
+// failure happens once after bytes have been read from the input or written to
+// the output; after is ignored for FaultRename. The error wraps ErrInjectedFault.
+// It is meant for tests only.
+func WithFaultInjection(f Fault, after int64) Option {
+	return func(o *options) {
+		o.fault = f
+		o.faultAfter = after
+	}
+}
+
+// faultReader fails with ErrInjectedFault once n bytes have been read.
+type faultReader struct {
+	r io.Reader
+	n int64
+}
+
+func (fr *faultReader) Read(p []byte) (int, error) {
+	if fr.n <= 0 {
+		return 0, ErrInjectedFault
+	}
+	n, err := fr.r.Read(p[:min(int64(len(p)), fr.n)])
+	fr.n -= int64(n)
+	return n, err
+}
+
+// faultWriter fails with ErrInjectedFault once n bytes have been written.
+type faultWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (fw *faultWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) <= fw.n {
+		fw.n -= int64(len(p))
+		return fw.w.Write(p)
+	}
+	n, _ := fw.w.Write(p[:fw.n])
+	fw.n = 0
+	return n, ErrInjectedFault
+}
+
+// writeFile writes data to a temporary file next to path and renames it over
+// path, so that a failed write never leaves a partial output behind.
+func writeFile(path string, data []byte, o *options) error {
+	return writeFileFrom(path, o, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// writeFileFrom is writeFile for output produced by write as it goes. When
+// write fails, the temporary file is removed and path is left unchanged.
+func writeFileFrom(path string, o *options, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil && o.fault == FaultRename {
+		err = &os.LinkError{Op: "rename", Old: tmp, New: path, Err: ErrInjectedFault}
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package exifremovethumbnail_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestFaultInjection(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+
+	t.Run("読み込みの失敗", func(t *testing.T) {
+		for _, after := range []int64{0, 10, 100, int64(len(inData)) - 1} {
+			_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+				exifremovethumbnail.WithFaultInjection(exifremovethumbnail.FaultRead, after))
+			require.ErrorIs(t, err, exifremovethumbnail.ErrInjectedFault, "%d バイト後", after)
+		}
+	})
+
+	t.Run("書き込みの失敗", func(t *testing.T) {
+		for _, after := range []int64{0, 10, 100, 5000} {
+			_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+				exifremovethumbnail.WithFaultInjection(exifremovethumbnail.FaultWrite, after))
+			require.ErrorIs(t, err, exifremovethumbnail.ErrInjectedFault, "%d バイト後", after)
+			require.Equal(t, after, res.AfterSize, "書けたバイト数が報告されるべき")
+		}
+	})
+
+	t.Run("上限に届かなければ成功する", func(t *testing.T) {
+		want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		got, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithFaultInjection(exifremovethumbnail.FaultWrite, int64(len(want))))
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	})
+
+	t.Run("リネームの失敗では既存の出力が残る", func(t *testing.T) {
+		dir := t.TempDir()
+		in := filepath.Join(dir, "in.jpg")
+		out := filepath.Join(dir, "out.jpg")
+		require.NoError(t, os.WriteFile(in, inData, 0644))
+		require.NoError(t, os.WriteFile(out, []byte("previous"), 0644))
+
+		_, err := exifremovethumbnail.ExifRemoveThumbnail(in, out,
+			exifremovethumbnail.WithFaultInjection(exifremovethumbnail.FaultRename, 0))
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInjectedFault)
+		outData, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.Equal(t, []byte("previous"), outData)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 2, "一時ファイルは削除されるべき")
+
+		_, err = exifremovethumbnail.ExifRemoveThumbnail(in, out)
+		require.NoError(t, err)
+		info, err := os.Stat(out)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	})
+}
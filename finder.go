@@ -0,0 +1,94 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+	"github.com/ideamans/go-exif-remove-thumbnail/tiff"
+)
+
+const (
+	tagJPEGInterchangeFormat       = spec.TagJPEGInterchangeFormat
+	tagJPEGInterchangeFormatLength = spec.TagJPEGInterchangeFormatLength
+
+	// maxFinderEntries bounds the IFD0 entry count accepted as a plausible TIFF header.
+	maxFinderEntries = 1000
+)
+
+// ExifBlock describes a TIFF/EXIF structure found by FindExifBlocks.
+// Offset is the position of the TIFF header within the scanned data.
+// ThumbnailOffset is relative to the scanned data as well and is only
+// meaningful when HasThumbnail is true.
+type ExifBlock struct {
+	Offset          int64
+	LittleEndian    bool
+	HasExifHeader   bool
+	HasThumbnail    bool
+	ThumbnailOffset int64
+	ThumbnailSize   int64
+}
+
+// FindExifBlocks scans an arbitrary binary blob for TIFF headers and reports
+// each plausible EXIF block along with its IFD1 thumbnail, if any.
+// It never modifies data, so it can be used to audit formats that this package
+// does not rewrite yet.
+func FindExifBlocks(data []byte) []ExifBlock {
+	var blocks []ExifBlock
+	for pos := 0; pos+8 <= len(data); pos++ {
+		if data[pos] != 'I' && data[pos] != 'M' {
+			continue
+		}
+		var order binary.ByteOrder
+		switch {
+		case bytes.HasPrefix(data[pos:], []byte("II*\x00")):
+			order = binary.LittleEndian
+		case bytes.HasPrefix(data[pos:], []byte("MM\x00*")):
+			order = binary.BigEndian
+		default:
+			continue
+		}
+		block, ok := inspectTIFF(&tiffBlock{&tiff.Block{Data: data[pos:], Order: order}})
+		if !ok {
+			continue
+		}
+		block.Offset = int64(pos)
+		block.HasExifHeader = pos >= len(exifHeader) && string(data[pos-len(exifHeader):pos]) == exifHeader
+		if block.HasThumbnail {
+			block.ThumbnailOffset += int64(pos)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// inspectTIFF validates a candidate TIFF block and collects its IFD1 thumbnail information.
+func inspectTIFF(t *tiffBlock) (ExifBlock, bool) {
+	var block ExifBlock
+	block.LittleEndian = t.Order == binary.LittleEndian
+	ifd0, err := t.ReadIFD(t.IFD0Offset())
+	if err != nil || len(ifd0.Entries) == 0 || len(ifd0.Entries) > maxFinderEntries {
+		return block, false
+	}
+	for _, e := range ifd0.Entries {
+		if _, ok := tiff.TypeSize(e.Type); !ok {
+			return block, false
+		}
+	}
+	if ifd0.Next == 0 {
+		return block, true
+	}
+	ifd1, err := t.ReadIFD(ifd0.Next)
+	if err != nil || ifd1.Overlaps(ifd0) {
+		return block, true
+	}
+	offset, hasOffset := ifd1.Find(tagJPEGInterchangeFormat)
+	length, hasLength := ifd1.Find(tagJPEGInterchangeFormatLength)
+	if !hasOffset || !hasLength || int64(offset.Value)+int64(length.Value) > int64(len(t.Data)) {
+		return block, true
+	}
+	block.HasThumbnail = true
+	block.ThumbnailOffset = int64(offset.Value)
+	block.ThumbnailSize = int64(length.Value)
+	return block, true
+}
@@ -0,0 +1,55 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestFindExifBlocks(t *testing.T) {
+	t.Run("サムネイルあり", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		blocks := exifremovethumbnail.FindExifBlocks(data)
+		require.Len(t, blocks, 1)
+		b := blocks[0]
+		require.True(t, b.HasExifHeader)
+		require.False(t, b.LittleEndian)
+		require.True(t, b.HasThumbnail)
+		require.Equal(t, int64(7920), b.ThumbnailSize)
+		// サムネイル位置にはJPEGのSOIがあること
+		require.Equal(t, []byte{0xFF, 0xD8}, data[b.ThumbnailOffset:b.ThumbnailOffset+2])
+	})
+
+	t.Run("未知のフォーマットに埋め込まれたTIFF", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		tiffStart := bytes.Index(data, []byte("MM\x00*"))
+		require.Greater(t, tiffStart, 0)
+		blob := append([]byte("UNKNOWN-CONTAINER\x00\x01\x02"), data[tiffStart:]...)
+		blocks := exifremovethumbnail.FindExifBlocks(blob)
+		require.NotEmpty(t, blocks)
+		require.Equal(t, int64(20), blocks[0].Offset)
+		require.False(t, blocks[0].HasExifHeader)
+		require.True(t, blocks[0].HasThumbnail)
+	})
+
+	t.Run("サムネイルなし", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "metadata_basic_exif.jpg"))
+		require.NoError(t, err)
+		blocks := exifremovethumbnail.FindExifBlocks(data)
+		require.Len(t, blocks, 1)
+		require.False(t, blocks[0].HasThumbnail)
+	})
+
+	t.Run("EXIFなし", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "metadata_none.jpg"))
+		require.NoError(t, err)
+		require.Empty(t, exifremovethumbnail.FindExifBlocks(data))
+	})
+}
@@ -0,0 +1,111 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// FormatJPEG is the format name reported for JPEG input.
+const FormatJPEG = "jpeg"
+
+// detectHeaderSize is the number of leading bytes passed to FormatHandler.Detect.
+const detectHeaderSize = 512
+
+// FormatHandler removes thumbnails from a container format other than JPEG.
+// Handlers are registered with RegisterFormat and take part in format detection
+// for ExifRemoveThumbnailBytes and ExifRemoveThumbnail.
+type FormatHandler interface {
+	// Name returns a short unique identifier such as "cr2".
+	Name() string
+	// Detect reports whether header, at most the first 512 bytes of the input, belongs to the format.
+	Detect(header []byte) bool
+	// RemoveThumbnail removes the thumbnail from data and describes the operation.
+	RemoveThumbnail(data []byte) ([]byte, ExifRemoveThumbnailResult, error)
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   []FormatHandler
+)
+
+// RegisterFormat makes a format handler available to the auto-detection dispatcher.
+// Options are specific to JPEG processing and are not passed to handlers.
+// RegisterFormat panics if a handler with the same name is already registered.
+func RegisterFormat(h FormatHandler) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	name := h.Name()
+	if name == FormatJPEG {
+		panic("exifremovethumbnail: format name " + FormatJPEG + " is reserved")
+	}
+	for _, f := range formats {
+		if f.Name() == name {
+			panic(fmt.Sprintf("exifremovethumbnail: RegisterFormat called twice for %q", name))
+		}
+	}
+	formats = append(formats, h)
+}
+
+// DetectFormat returns the format name of data: FormatJPEG, the name of a
+// registered handler, or "" if the format is not supported.
+func DetectFormat(data []byte) string {
+	if isJPEG(data) {
+		return FormatJPEG
+	}
+	if h := lookupFormat(data); h != nil {
+		return h.Name()
+	}
+	return ""
+}
+
+// patchHandler is implemented by format handlers that only overwrite parts of
+// a file, so that ExifRemoveThumbnail can write the file from the input and
+// the patches instead of building the whole output in memory.
+type patchHandler interface {
+	FormatHandler
+	// patches describes the changes without modifying data.
+	patches(data []byte) ([]bytePatch, ExifRemoveThumbnailResult, error)
+}
+
+// bytePatch replaces the bytes of a file at offset with data.
+type bytePatch struct {
+	offset int
+	data   []byte
+}
+
+// writePatched writes data to w with patches applied. The patches must be
+// sorted by offset and must not overlap.
+func writePatched(w io.Writer, data []byte, patches []bytePatch) error {
+	pos := 0
+	for _, p := range patches {
+		if _, err := w.Write(data[pos:p.offset]); err != nil {
+			return err
+		}
+		if _, err := w.Write(p.data); err != nil {
+			return err
+		}
+		pos = p.offset + len(p.data)
+	}
+	_, err := w.Write(data[pos:])
+	return err
+}
+
+// isJPEG reports whether data starts with the SOI marker.
+func isJPEG(data []byte) bool {
+	return bytes.HasPrefix(data, []byte{0xFF, 0xD8})
+}
+
+// lookupFormat returns the first registered handler detecting data.
+func lookupFormat(data []byte) FormatHandler {
+	header := data[:min(len(data), detectHeaderSize)]
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for _, f := range formats {
+		if f.Detect(header) {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+// fakeRAWHandler は "FAKERAW" で始まるデータの末尾 "THUMB" を取り除くテスト用ハンドラ
+type fakeRAWHandler struct{}
+
+func (fakeRAWHandler) Name() string { return "fakeraw" }
+
+func (fakeRAWHandler) Detect(header []byte) bool {
+	return bytes.HasPrefix(header, []byte("FAKERAW"))
+}
+
+func (fakeRAWHandler) RemoveThumbnail(data []byte) ([]byte, exifremovethumbnail.ExifRemoveThumbnailResult, error) {
+	out, had := bytes.CutSuffix(data, []byte("THUMB"))
+	result := exifremovethumbnail.ExifRemoveThumbnailResult{
+		HadThumbnail: had,
+		BeforeSize:   int64(len(data)),
+		AfterSize:    int64(len(out)),
+	}
+	if had {
+		result.ThumbnailSize = 5
+	}
+	return out, result, nil
+}
+
+var registerFakeRAWOnce sync.Once
+
+// registerFakeRAW はテスト用ハンドラを一度だけ登録する
+func registerFakeRAW() {
+	registerFakeRAWOnce.Do(func() { exifremovethumbnail.RegisterFormat(fakeRAWHandler{}) })
+}
+
+func TestRegisterFormat(t *testing.T) {
+	registerFakeRAW()
+
+	t.Run("登録したハンドラに処理が委ねられる", func(t *testing.T) {
+		out, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes([]byte("FAKERAW-dataTHUMB"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("FAKERAW-data"), out)
+		require.Equal(t, "fakeraw", result.Format)
+		require.True(t, result.HadThumbnail)
+		require.Equal(t, int64(5), result.ThumbnailSize)
+	})
+
+	t.Run("ファイルAPIでも委ねられる", func(t *testing.T) {
+		dir := t.TempDir()
+		in := filepath.Join(dir, "in.raw")
+		out := filepath.Join(dir, "out.raw")
+		require.NoError(t, os.WriteFile(in, []byte("FAKERAW-dataTHUMB"), 0644))
+		result, err := exifremovethumbnail.ExifRemoveThumbnail(in, out)
+		require.NoError(t, err)
+		require.Equal(t, "fakeraw", result.Format)
+		outData, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.Equal(t, []byte("FAKERAW-data"), outData)
+	})
+
+	t.Run("JPEGは組み込み処理のまま", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.FormatJPEG, result.Format)
+		require.True(t, result.HadThumbnail)
+	})
+
+	t.Run("フォーマット判定", func(t *testing.T) {
+		pngData, err := os.ReadFile(filepath.Join("testdata", "actual_png.jpg"))
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.FormatJPEG, exifremovethumbnail.DetectFormat([]byte{0xFF, 0xD8, 0xFF}))
+		require.Equal(t, "fakeraw", exifremovethumbnail.DetectFormat([]byte("FAKERAW")))
+		require.Equal(t, "", exifremovethumbnail.DetectFormat(pngData))
+	})
+
+	t.Run("未対応のフォーマットはFormatError", func(t *testing.T) {
+		pngData, err := os.ReadFile(filepath.Join("testdata", "actual_png.jpg"))
+		require.NoError(t, err)
+		_, _, err = exifremovethumbnail.ExifRemoveThumbnailBytes(pngData)
+		_, ok := err.(*exifremovethumbnail.FormatError)
+		require.True(t, ok, "FormatErrorであるべき")
+	})
+
+	t.Run("同じ名前の二重登録はpanic", func(t *testing.T) {
+		require.Panics(t, func() { exifremovethumbnail.RegisterFormat(fakeRAWHandler{}) })
+	})
+}
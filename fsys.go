@@ -0,0 +1,266 @@
+package exifremovethumbnail
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+)
+
+// WriteFS is a file system files can also be written to, the output side of
+// ExifRemoveThumbnailFS. Names are slash-separated as in fs.FS.
+type WriteFS interface {
+	fs.FS
+	// WriteFile writes data to the named file, creating it if necessary and
+	// replacing its contents otherwise.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// ExifRemoveThumbnailFS is ExifRemoveThumbnail for files of an fs.FS, such
+// as an embed.FS, a zip.Reader or an fstest.MapFS: it reads inputName from
+// src and writes the result to outputName in dst. The output is written even
+// when nothing changes, so dst always ends up with the file. src and dst may
+// be the same file system.
+func ExifRemoveThumbnailFS(src fs.FS, inputName string, dst WriteFS, outputName string, opts ...Option) (ExifRemoveThumbnailResult, error) {
+	o := newOptions(opts)
+	if limit := o.maxInputSize; limit > 0 {
+		if info, err := fs.Stat(src, inputName); err == nil && info.Size() > limit {
+			return ExifRemoveThumbnailResult{}, fmt.Errorf("%s: %w", inputName, ErrInputTooLarge)
+		}
+	}
+	inputData, err := fs.ReadFile(src, inputName)
+	if err != nil {
+		return ExifRemoveThumbnailResult{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+	outputData, result, err := removeThumbnailBytes(inputData, o)
+	if err != nil {
+		return result, err
+	}
+	if err := dst.WriteFile(outputName, outputData, 0644); err != nil {
+		return result, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return result, nil
+}
+
+// DirFS returns a WriteFS for the directory tree rooted at dir, reading like
+// os.DirFS. Files are written through a temporary file and renamed into
+// place, as ExifRemoveThumbnail does, and missing directories are created.
+func DirFS(dir string) WriteFS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (d dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	path := filepath.Join(d.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := writeFile(path, data, &options{}); err != nil {
+		return err
+	}
+	if perm != 0644 {
+		return os.Chmod(path, perm)
+	}
+	return nil
+}
+
+// FileResult is the outcome of one file processed by RemoveAll.
+type FileResult struct {
+	Result ExifRemoveThumbnailResult
+	Err    error
+}
+
+// BatchReport is the outcome of RemoveAll: the result of every file and the
+// totals an operator checks at the end of a run.
+type BatchReport struct {
+	// Files holds the result of every processed file, keyed by name.
+	Files map[string]FileResult
+	// Scanned counts the processed files, including skipped and failed ones.
+	Scanned int
+	// WithThumbnail counts the files that had an EXIF thumbnail.
+	WithThumbnail int
+	// Changed counts the files rewritten, Skipped those left alone.
+	Changed int
+	Skipped int
+	// Failed counts the files that could not be processed, and Failures
+	// breaks them down by ErrorClass.
+	Failed   int
+	Failures map[ErrorCode]int
+	// SavedBytes is the total size reduction of the rewritten files.
+	SavedBytes int64
+}
+
+// add counts the result of one file into the report.
+func (r *BatchReport) add(name string, f FileResult) {
+	r.Files[name] = f
+	r.Scanned++
+	if f.Result.HadThumbnail {
+		r.WithThumbnail++
+	}
+	switch {
+	case f.Err != nil:
+		r.Failed++
+		r.Failures[ErrorClass(f.Err)]++
+	case f.Result.Skipped:
+		r.Skipped++
+	case !f.Result.Unchanged:
+		r.Changed++
+		r.SavedBytes += f.Result.BeforeSize - f.Result.AfterSize
+	}
+}
+
+// RemoveAll removes the thumbnails from every file of fsys matching pattern
+// and writes each result back under the same name. The pattern is
+// slash-separated like fs.FS names, with the syntax of path.Match in each
+// element plus "**" for any number of directories: "**/*.jpg" matches JPEG
+// files at any depth, "2024/**/*.jpg" those under 2024.
+//
+// The report holds the result of every file matching pattern, keyed by name,
+// and the totals. An error on one file is recorded in its FileResult and does
+// not stop the others; files in no supported format are reported as skipped
+// with SkipUnsupportedFormat, and files left unchanged are not written.
+// WithExtensions, WithModifiedAfter, WithMinFileSize, WithLargestFirst and
+// the related options restrict the walk as for TopOffenders; the files they
+// leave out are reported as skipped with their SkipReason. WithResultStream
+// reports each file, processed or skipped, as it is done, WithCheckpoint lets
+// an interrupted run resume and WithStateStore skips files known to be clean.
+// The error is for a malformed pattern, a failed walk or a failed write to
+// the result stream or the checkpoint.
+func RemoveAll(fsys WriteFS, pattern string, opts ...Option) (BatchReport, error) {
+	elems := strings.Split(pattern, "/")
+	for _, e := range elems {
+		if _, err := pathpkg.Match(e, ""); err != nil {
+			return BatchReport{}, fmt.Errorf("%q: %w", pattern, err)
+		}
+	}
+	o := newOptions(opts)
+	var cp *checkpoint
+	if o.checkpoint != "" {
+		var err error
+		if cp, err = openCheckpoint(o.checkpoint); err != nil {
+			return BatchReport{}, err
+		}
+	}
+	report := BatchReport{Files: map[string]FileResult{}, Failures: map[ErrorCode]int{}}
+	match := func(path string) bool {
+		return matchGlob(elems, strings.Split(path, "/"))
+	}
+	// Skipped files are reported, counted and streamed like processed ones.
+	// The walk cannot fail from skip, so a failed stream write is kept for
+	// the next file and the end of the walk.
+	var streamErr error
+	skip := func(path string, reason SkipReason) {
+		o.skip(path, reason)
+		f := FileResult{Result: ExifRemoveThumbnailResult{Skipped: true, SkipReason: reason}}
+		report.add(path, f)
+		if streamErr == nil {
+			streamErr = o.streamResult(path, f)
+		}
+	}
+	err := walkFiles(fsys, o, match, skip, func(path string) error {
+		if streamErr != nil {
+			return streamErr
+		}
+		if cp != nil && cp.done[path] {
+			skip(path, SkipCompleted)
+			return streamErr
+		}
+		f := removeFSFile(fsys, path, o)
+		report.add(path, f)
+		if err := o.streamResult(path, f); err != nil {
+			return err
+		}
+		if cp != nil && f.Err == nil {
+			return cp.record(path)
+		}
+		return nil
+	})
+	if err == nil {
+		err = streamErr
+	}
+	if cp != nil {
+		if closeErr := cp.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to write checkpoint: %w", closeErr)
+		}
+	}
+	if err != nil {
+		return BatchReport{}, err
+	}
+	return report, nil
+}
+
+// removeFSFile processes one file for RemoveAll.
+func removeFSFile(fsys WriteFS, path string, o *options) FileResult {
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return FileResult{Err: fmt.Errorf("failed to read input file: %w", err)}
+	}
+	size := int64(len(data))
+	if DetectFormat(data) == "" {
+		o.skip(path, SkipUnsupportedFormat)
+		return FileResult{Result: ExifRemoveThumbnailResult{BeforeSize: size, AfterSize: size, Skipped: true, SkipReason: SkipUnsupportedFormat}}
+	}
+	var hash string
+	if o.stateStore != nil {
+		hash = sha256Hex(data)
+		clean, err := o.stateStore.IsClean(hash)
+		if err != nil {
+			return FileResult{Err: fmt.Errorf("failed to look up state: %w", err)}
+		}
+		if clean {
+			o.skip(path, SkipKnownClean)
+			return FileResult{Result: ExifRemoveThumbnailResult{BeforeSize: size, AfterSize: size, Skipped: true, SkipReason: SkipKnownClean}}
+		}
+	}
+	out, result, err := removeThumbnailBytes(data, o)
+	if err != nil || result.Skipped {
+		return FileResult{Result: result, Err: err}
+	}
+	if !result.Unchanged {
+		if err := fsys.WriteFile(path, out, 0644); err != nil {
+			return FileResult{Result: result, Err: fmt.Errorf("failed to write output file: %w", err)}
+		}
+	}
+	if o.stateStore != nil {
+		// The rewritten file is what the next run will find.
+		if !result.Unchanged {
+			hash = sha256Hex(out)
+		}
+		if err := o.stateStore.MarkClean(hash); err != nil {
+			return FileResult{Result: result, Err: fmt.Errorf("failed to record state: %w", err)}
+		}
+	}
+	return FileResult{Result: result}
+}
+
+// matchGlob reports whether the elements of a file name match the elements
+// of a pattern, where "**" matches any number of elements.
+func matchGlob(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlob(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := pathpkg.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,261 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+// mapWriteFS はfstest.MapFSに書き込めるようにしたもの
+type mapWriteFS struct {
+	fstest.MapFS
+}
+
+func (m mapWriteFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.MapFS[name] = &fstest.MapFile{Data: append([]byte{}, data...), Mode: perm}
+	return nil
+}
+
+var errStreamFull = errors.New("stream full")
+
+// failingWriter は常に書き込みに失敗する
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errStreamFull
+}
+
+func TestExifRemoveThumbnailFS(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+
+	t.Run("メモリ上のファイルシステム", func(t *testing.T) {
+		fsys := mapWriteFS{fstest.MapFS{"in/a.jpg": {Data: inData}}}
+		res, err := exifremovethumbnail.ExifRemoveThumbnailFS(fsys, "in/a.jpg", fsys, "out/a.jpg")
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, want, fsys.MapFS["out/a.jpg"].Data)
+		require.Equal(t, inData, fsys.MapFS["in/a.jpg"].Data)
+
+		// 同じファイルへの上書き
+		_, err = exifremovethumbnail.ExifRemoveThumbnailFS(fsys, "in/a.jpg", fsys, "in/a.jpg")
+		require.NoError(t, err)
+		require.Equal(t, want, fsys.MapFS["in/a.jpg"].Data)
+		res, err = exifremovethumbnail.ExifRemoveThumbnailFS(fsys, "in/a.jpg", fsys, "in/a.jpg")
+		require.NoError(t, err)
+		require.True(t, res.Unchanged)
+		require.Equal(t, want, fsys.MapFS["in/a.jpg"].Data)
+	})
+
+	t.Run("ディレクトリへ書き込む", func(t *testing.T) {
+		dir := t.TempDir()
+		src := os.DirFS("testdata")
+		res, err := exifremovethumbnail.ExifRemoveThumbnailFS(src, "thumbnail_embedded.jpg", exifremovethumbnail.DirFS(dir), "sub/dir/out.jpg")
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		got, err := os.ReadFile(filepath.Join(dir, "sub", "dir", "out.jpg"))
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+
+		// 書き込んだファイルはfs.FSとしても読める
+		got, err = fs.ReadFile(exifremovethumbnail.DirFS(dir), "sub/dir/out.jpg")
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+
+		require.NoError(t, exifremovethumbnail.DirFS(dir).WriteFile("private.jpg", got, 0600))
+		info, err := os.Stat(filepath.Join(dir, "private.jpg"))
+		require.NoError(t, err)
+		require.Equal(t, fs.FileMode(0600), info.Mode().Perm())
+
+		for _, name := range []string{"../escape.jpg", "/abs.jpg", "."} {
+			err = exifremovethumbnail.DirFS(dir).WriteFile(name, got, 0644)
+			require.ErrorIs(t, err, fs.ErrInvalid, name)
+		}
+	})
+
+	t.Run("エラーのときは書き込まない", func(t *testing.T) {
+		fsys := mapWriteFS{fstest.MapFS{
+			"a.jpg":   {Data: inData},
+			"png.jpg": {Data: []byte("\x89PNG\r\n\x1a\n")},
+		}}
+		_, err := exifremovethumbnail.ExifRemoveThumbnailFS(fsys, "png.jpg", fsys, "out.jpg")
+		require.ErrorIs(t, err, exifremovethumbnail.ErrNotJPEG)
+		_, err = exifremovethumbnail.ExifRemoveThumbnailFS(fsys, "a.jpg", fsys, "out.jpg", exifremovethumbnail.WithMaxInputSize(100))
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInputTooLarge)
+		_, err = exifremovethumbnail.ExifRemoveThumbnailFS(fsys, "missing.jpg", fsys, "out.jpg")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		require.NotContains(t, fsys.MapFS, "out.jpg")
+	})
+}
+
+func TestRemoveAll(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	newFS := func() mapWriteFS {
+		return mapWriteFS{fstest.MapFS{
+			"a.jpg":             {Data: inData},
+			"2024/01/b.jpg":     {Data: inData},
+			"2024/01/c.png":     {Data: inData},
+			"2024/02/clean.jpg": {Data: want},
+			"2023/d.jpg":        {Data: inData},
+			"notes/e.jpg":       {Data: []byte("not an image")},
+			"notes/f.jpg":       {Data: []byte{0xFF, 0xD8, 0xFF}},
+		}}
+	}
+
+	t.Run("パターンに一致するファイルだけを処理する", func(t *testing.T) {
+		for _, c := range []struct {
+			pattern string
+			want    []string
+		}{
+			{"**/*.jpg", []string{"a.jpg", "2024/01/b.jpg", "2024/02/clean.jpg", "2023/d.jpg", "notes/e.jpg", "notes/f.jpg"}},
+			{"*.jpg", []string{"a.jpg"}},
+			{"2024/**/*.jpg", []string{"2024/01/b.jpg", "2024/02/clean.jpg"}},
+			{"202?/*.jpg", []string{"2023/d.jpg"}},
+			{"**/01/*", []string{"2024/01/b.jpg", "2024/01/c.png"}},
+			{"**/*.gif", nil},
+		} {
+			report, err := exifremovethumbnail.RemoveAll(newFS(), c.pattern)
+			require.NoError(t, err, c.pattern)
+			var got []string
+			for name := range report.Files {
+				got = append(got, name)
+			}
+			require.ElementsMatch(t, c.want, got, c.pattern)
+		}
+	})
+
+	t.Run("結果をファイルごとに返す", func(t *testing.T) {
+		fsys := newFS()
+		var skipped []string
+		report, err := exifremovethumbnail.RemoveAll(fsys, "**/*.jpg", exifremovethumbnail.WithSkipHandler(func(name string, reason exifremovethumbnail.SkipReason) {
+			skipped = append(skipped, name)
+		}))
+		require.NoError(t, err)
+		results := report.Files
+
+		for _, name := range []string{"a.jpg", "2024/01/b.jpg", "2023/d.jpg"} {
+			require.NoError(t, results[name].Err)
+			require.True(t, results[name].Result.HadThumbnail)
+			require.Equal(t, want, fsys.MapFS[name].Data, name)
+		}
+		require.NoError(t, results["2024/02/clean.jpg"].Err)
+		require.True(t, results["2024/02/clean.jpg"].Result.Unchanged)
+
+		require.NoError(t, results["notes/e.jpg"].Err)
+		require.True(t, results["notes/e.jpg"].Result.Skipped)
+		require.Equal(t, exifremovethumbnail.SkipUnsupportedFormat, results["notes/e.jpg"].Result.SkipReason)
+		require.Equal(t, []string{"notes/e.jpg"}, skipped)
+
+		// 壊れたファイルのエラーは他のファイルを止めない
+		require.Error(t, results["notes/f.jpg"].Err)
+		require.Equal(t, []byte{0xFF, 0xD8, 0xFF}, fsys.MapFS["notes/f.jpg"].Data)
+		require.Equal(t, inData, fsys.MapFS["2024/01/c.png"].Data)
+
+		// 集計
+		require.Equal(t, 6, report.Scanned)
+		require.Equal(t, 3, report.WithThumbnail)
+		require.Equal(t, 3, report.Changed)
+		require.Equal(t, 1, report.Skipped)
+		require.Equal(t, 1, report.Failed)
+		require.Equal(t, map[exifremovethumbnail.ErrorCode]int{exifremovethumbnail.CodeTruncated: 1}, report.Failures)
+		require.Equal(t, int64(3*(len(inData)-len(want))), report.SavedBytes)
+	})
+
+	t.Run("ディレクトリの中身を書き換える", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "x", "y"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "x", "y", "a.jpg"), inData, 0644))
+		report, err := exifremovethumbnail.RemoveAll(exifremovethumbnail.DirFS(dir), "**/*.jpg")
+		require.NoError(t, err)
+		require.Len(t, report.Files, 1)
+		got, err := os.ReadFile(filepath.Join(dir, "x", "y", "a.jpg"))
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+		entries, err := os.ReadDir(filepath.Join(dir, "x", "y"))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	})
+
+	t.Run("結果をJSONLで逐次書き出す", func(t *testing.T) {
+		var buf bytes.Buffer
+		report, err := exifremovethumbnail.RemoveAll(newFS(), "**/*.jpg", exifremovethumbnail.WithResultStream(&buf))
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		require.Len(t, lines, len(report.Files))
+
+		docs := map[string]map[string]any{}
+		for _, line := range lines {
+			var doc map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &doc))
+			docs[doc["path"].(string)] = doc
+		}
+		a := docs["a.jpg"]
+		require.Nil(t, a["error"])
+		require.Equal(t, true, a["result"].(map[string]any)["had_thumbnail"])
+		require.Equal(t, float64(len(want)), a["result"].(map[string]any)["after_size"])
+		require.Equal(t, "unsupported_format", docs["notes/e.jpg"]["result"].(map[string]any)["skip_reason"])
+		failed := docs["notes/f.jpg"]["error"].(map[string]any)
+		require.Equal(t, "truncated", failed["code"])
+		require.NotEmpty(t, failed["message"])
+	})
+
+	t.Run("絞り込みで外したファイルも書き出す", func(t *testing.T) {
+		var buf bytes.Buffer
+		report, err := exifremovethumbnail.RemoveAll(newFS(), "**/*.jpg",
+			exifremovethumbnail.WithMinFileSize(100), exifremovethumbnail.WithResultStream(&buf))
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		require.Len(t, lines, len(report.Files))
+
+		reasons := map[string]any{}
+		for _, line := range lines {
+			var doc map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &doc))
+			reasons[doc["path"].(string)] = doc["result"].(map[string]any)["skip_reason"]
+		}
+		require.Equal(t, "too_small", reasons["notes/e.jpg"])
+		require.Equal(t, "too_small", reasons["notes/f.jpg"])
+		require.Equal(t, "", reasons["a.jpg"])
+	})
+
+	t.Run("読み飛ばしたファイルの書き出しに失敗しても止まる", func(t *testing.T) {
+		_, err := exifremovethumbnail.RemoveAll(newFS(), "notes/*.jpg",
+			exifremovethumbnail.WithMinFileSize(100), exifremovethumbnail.WithResultStream(failingWriter{}))
+		require.ErrorIs(t, err, errStreamFull)
+	})
+
+	t.Run("書き出しに失敗すると止まる", func(t *testing.T) {
+		fsys := newFS()
+		_, err := exifremovethumbnail.RemoveAll(fsys, "**/*.jpg", exifremovethumbnail.WithResultStream(failingWriter{}))
+		require.ErrorIs(t, err, errStreamFull)
+		// 最初のファイルだけが処理される
+		changed := 0
+		for _, name := range []string{"a.jpg", "2024/01/b.jpg", "2023/d.jpg"} {
+			if !bytes.Equal(inData, fsys.MapFS[name].Data) {
+				changed++
+			}
+		}
+		require.LessOrEqual(t, changed, 1)
+	})
+
+	t.Run("不正なパターン", func(t *testing.T) {
+		_, err := exifremovethumbnail.RemoveAll(newFS(), "**/[.jpg")
+		require.ErrorIs(t, err, path.ErrBadPattern)
+	})
+}
@@ -0,0 +1,159 @@
+package exifremovethumbnail_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testEntry はテスト用TIFFのIFDエントリ
+type testEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// asciiEntry はASCII型のエントリを作る
+func asciiEntry(tag uint16, s string) testEntry {
+	return testEntry{tag: tag, typ: 2, count: uint32(len(s) + 1), value: append([]byte(s), 0)}
+}
+
+// undefinedEntry はUNDEFINED型のエントリを作る
+func undefinedEntry(tag uint16, b []byte) testEntry {
+	return testEntry{tag: tag, typ: 7, count: uint32(len(b)), value: b}
+}
+
+// shortEntry はSHORT型のエントリを作る
+func shortEntry(order binary.ByteOrder, tag uint16, v uint16) testEntry {
+	b := make([]byte, 2)
+	order.PutUint16(b, v)
+	return testEntry{tag: tag, typ: 3, count: 1, value: b}
+}
+
+// testTIFF はテスト用TIFFの構成
+type testTIFF struct {
+	order     binary.ByteOrder
+	ifd0      []testEntry
+	exif      []testEntry
+	gps       []testEntry
+	ifd1      []testEntry
+	thumbnail []byte
+	// valuesAfterThumbnail は値領域をサムネイルの後ろに置く
+	valuesAfterThumbnail bool
+	// ifd1Last はIFD1のディレクトリを末尾に置く
+	ifd1Last bool
+}
+
+// build はIFD群、値領域、サムネイルの順に並べたTIFFを組み立てる
+// valuesAfterThumbnailなら値領域とサムネイルの順序を入れ替え、ifd1LastならIFD1を末尾に置く
+func (tt testTIFF) build() []byte {
+	order := tt.order
+	if order == nil {
+		order = binary.BigEndian
+	}
+	ifdSize := func(n int) int { return 2 + n*12 + 4 }
+	ifd0 := append([]testEntry{}, tt.ifd0...)
+	ifd1 := append([]testEntry{}, tt.ifd1...)
+	long := func(tag uint16) testEntry { return testEntry{tag: tag, typ: 4, count: 1, value: make([]byte, 4)} }
+	if tt.exif != nil {
+		ifd0 = append(ifd0, long(0x8769))
+	}
+	if tt.gps != nil {
+		ifd0 = append(ifd0, long(0x8825))
+	}
+	if tt.thumbnail != nil {
+		ifd1 = append(ifd1, long(0x0201), long(0x0202))
+	}
+	dirs := [][]testEntry{ifd0, tt.exif, tt.gps, ifd1}
+	offsets := make([]int, len(dirs))
+	pos := 8
+	for i, d := range dirs {
+		sort.Slice(d, func(a, b int) bool { return d[a].tag < d[b].tag })
+		if d == nil || (i == 3 && (len(d) == 0 || tt.ifd1Last)) {
+			continue
+		}
+		offsets[i] = pos
+		pos += ifdSize(len(d))
+	}
+	ifd1Size := 0
+	if tt.ifd1Last && len(ifd1) > 0 {
+		ifd1Size = ifdSize(len(ifd1))
+	}
+	valuesSize := 0
+	for _, d := range dirs {
+		for _, e := range d {
+			if len(e.value) > 4 {
+				valuesSize += len(e.value) + len(e.value)%2
+			}
+		}
+	}
+	valuePos, thumbPos := pos, pos+valuesSize
+	if tt.valuesAfterThumbnail {
+		thumbPos, valuePos = pos, pos+len(tt.thumbnail)+len(tt.thumbnail)%2
+	}
+	if ifd1Size > 0 {
+		offsets[3] = pos + valuesSize + len(tt.thumbnail) + len(tt.thumbnail)%2
+	}
+	buf := make([]byte, pos+valuesSize+len(tt.thumbnail)+len(tt.thumbnail)%2+ifd1Size)
+	if order == binary.LittleEndian {
+		copy(buf, "II")
+	} else {
+		copy(buf, "MM")
+	}
+	order.PutUint16(buf[2:], 42)
+	order.PutUint32(buf[4:], 8)
+	for i, d := range dirs {
+		if offsets[i] == 0 {
+			continue
+		}
+		p := offsets[i]
+		order.PutUint16(buf[p:], uint16(len(d)))
+		for j, e := range d {
+			ep := p + 2 + j*12
+			order.PutUint16(buf[ep:], e.tag)
+			order.PutUint16(buf[ep+2:], e.typ)
+			order.PutUint32(buf[ep+4:], e.count)
+			switch {
+			case e.tag == 0x8769:
+				order.PutUint32(buf[ep+8:], uint32(offsets[1]))
+			case e.tag == 0x8825:
+				order.PutUint32(buf[ep+8:], uint32(offsets[2]))
+			case i == 3 && e.tag == 0x0201:
+				order.PutUint32(buf[ep+8:], uint32(thumbPos))
+			case i == 3 && e.tag == 0x0202:
+				order.PutUint32(buf[ep+8:], uint32(len(tt.thumbnail)))
+			case len(e.value) > 4:
+				order.PutUint32(buf[ep+8:], uint32(valuePos))
+				copy(buf[valuePos:], e.value)
+				valuePos += len(e.value) + len(e.value)%2
+			default:
+				copy(buf[ep+8:], e.value)
+			}
+		}
+		if i == 0 {
+			order.PutUint32(buf[p+2+len(d)*12:], uint32(offsets[3]))
+		}
+	}
+	copy(buf[thumbPos:], tt.thumbnail)
+	return buf
+}
+
+// exifPayload はAPP1のEXIFペイロードを返す
+func (tt testTIFF) exifPayload() []byte {
+	return append([]byte("Exif\x00\x00"), tt.build()...)
+}
+
+// jpegWithExif はEXIFなしのテスト画像のAPP0直後にEXIFセグメントを挿入する
+func jpegWithExif(t *testing.T, payload []byte) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "metadata_none.jpg"))
+	require.NoError(t, err)
+	app0End := 4 + int(binary.BigEndian.Uint16(data[4:6]))
+	out := insertSegment(append([]byte{0xFF, 0xD8}, data[app0End:]...), 0xE1, payload)
+	return append(append([]byte{}, data[:app0End]...), out[2:]...)
+}
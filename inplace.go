@@ -0,0 +1,126 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+// ExifRemoveThumbnailInPlace removes the EXIF thumbnail from the JPEG file at
+// path by patching only the bytes in front of the image data. The image data
+// of huge files is neither read into memory nor written again: the rewritten
+// headers are written over the old ones with WriteAt, and the bytes they no
+// longer take become padding in front of SOS, as with WithConstantSize. The
+// thumbnail is gone but the file keeps its size; rewrite the file with
+// ExifRemoveThumbnail and WithDropSegments(SegmentPadding) to reclaim the space.
+//
+// Files whose headers would grow, whose image data would change (as with
+// WithConformance), files of other formats, and options examining the whole
+// output (WithIntegrityCheck, WithValidateOutput, WithVerifyPixels) make the
+// file be rewritten through a temporary file as ExifRemoveThumbnail(path, path)
+// does. Patching is not atomic: a crash while the headers are written can
+// leave a broken file, which the temporary file of ExifRemoveThumbnail avoids.
+func ExifRemoveThumbnailInPlace(path string, opts ...Option) (ExifRemoveThumbnailResult, error) {
+	o := newOptions(opts)
+	if !o.streamable() {
+		return ExifRemoveThumbnail(path, path, opts...)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return ExifRemoveThumbnailResult{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return ExifRemoveThumbnailResult{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+	size := info.Size()
+	if o.maxInputSize > 0 && size > o.maxInputSize {
+		f.Close()
+		return ExifRemoveThumbnailResult{}, fmt.Errorf("%s: %w", path, ErrInputTooLarge)
+	}
+	sos, err := sosOffset(f)
+	if err != nil {
+		// Let the regular path report what is wrong with the file.
+		f.Close()
+		return ExifRemoveThumbnail(path, path, opts...)
+	}
+
+	o.constantSize = true
+	head := &prefixWriter{limit: sos}
+	result, err := removeThumbnailAt(head, f, size, o)
+	result.BeforeSize = size
+	if err != nil {
+		f.Close()
+		return result, err
+	}
+	headLen := result.AfterSize - (size - sos)
+	switch {
+	case result.Skipped:
+		result.AfterSize = size
+		return result, f.Close()
+	case headLen != sos || result.OutputScanHash != result.InputScanHash:
+		f.Close()
+		return ExifRemoveThumbnail(path, path, opts...)
+	}
+	patch := head.buf
+	current := make([]byte, sos)
+	if _, err := f.ReadAt(current, 0); err != nil {
+		f.Close()
+		return result, fmt.Errorf("failed to read input file: %w", err)
+	}
+	if bytes.Equal(patch, current) {
+		result.Unchanged = true
+		return result, f.Close()
+	}
+	if _, err := f.WriteAt(patch, 0); err != nil {
+		f.Close()
+		return result, fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return result, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return result, nil
+}
+
+// sosOffset returns the offset of the first SOS marker of the JPEG data read
+// from r, reading only the marker headers.
+func sosOffset(r io.ReaderAt) (int64, error) {
+	header := make([]byte, 4)
+	if _, err := r.ReadAt(header[:2], 0); err != nil || binary.BigEndian.Uint16(header) != spec.SOI {
+		return 0, ErrNotJPEG
+	}
+	for pos := int64(2); ; {
+		if _, err := r.ReadAt(header, pos); err != nil {
+			return 0, err
+		}
+		marker := binary.BigEndian.Uint16(header)
+		switch {
+		case marker == 0xFFFF:
+			pos++
+		case marker == spec.SOS:
+			return pos, nil
+		case marker&0xFF00 != 0xFF00 || binary.BigEndian.Uint16(header[2:]) < 2:
+			return 0, fmt.Errorf("invalid JPEG marker at offset %d", pos)
+		default:
+			pos += 2 + int64(binary.BigEndian.Uint16(header[2:]))
+		}
+	}
+}
+
+// prefixWriter keeps the first limit bytes written and counts the rest.
+type prefixWriter struct {
+	limit int64
+	buf   []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	if keep := p.limit - int64(len(p.buf)); keep > 0 {
+		p.buf = append(p.buf, b[:min(keep, int64(len(b)))]...)
+	}
+	return len(b), nil
+}
@@ -0,0 +1,81 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestInPlace(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	write := func(t *testing.T, data []byte) string {
+		path := filepath.Join(t.TempDir(), "a.jpg")
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		return path
+	}
+
+	t.Run("ヘッダだけを書き換えて画像データは動かさない", func(t *testing.T) {
+		path := write(t, inData)
+		res, err := exifremovethumbnail.ExifRemoveThumbnailInPlace(path)
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, res.BeforeSize, res.AfterSize)
+
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Len(t, got, len(inData))
+		sos := bytes.LastIndex(inData, []byte{0xFF, 0xDA})
+		require.Equal(t, inData[sos:], got[sos:])
+		require.Equal(t, exifremovethumbnail.ScanHash(inData), exifremovethumbnail.ScanHash(got))
+		blocks := exifremovethumbnail.FindExifBlocks(got)
+		require.Len(t, blocks, 1)
+		require.False(t, blocks[0].HasThumbnail)
+		_, err = jpeg.Decode(bytes.NewReader(got))
+		require.NoError(t, err)
+
+		// 通常の処理結果とは詰め物の分だけ異なる
+		want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithConstantSize())
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+		want, _, err = exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		cleaned, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(got, exifremovethumbnail.WithDropSegments(exifremovethumbnail.SegmentPadding))
+		require.NoError(t, err)
+		require.Equal(t, want, cleaned)
+
+		again, err := exifremovethumbnail.ExifRemoveThumbnailInPlace(path)
+		require.NoError(t, err)
+		require.True(t, again.Unchanged)
+	})
+
+	t.Run("ヘッダが大きくなるときは書き直す", func(t *testing.T) {
+		path := write(t, inData)
+		profile := bytes.Repeat([]byte{0x42}, 20000)
+		res, err := exifremovethumbnail.ExifRemoveThumbnailInPlace(path, exifremovethumbnail.WithReplaceICC(profile))
+		require.NoError(t, err)
+		want, wantRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithReplaceICC(profile))
+		require.NoError(t, err)
+		require.Equal(t, wantRes.AfterSize, res.AfterSize)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	})
+
+	t.Run("JPEGでなければエラー", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "actual_png.jpg"))
+		require.NoError(t, err)
+		path := write(t, data)
+		_, err = exifremovethumbnail.ExifRemoveThumbnailInPlace(path)
+		require.ErrorIs(t, err, exifremovethumbnail.ErrNotJPEG)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+	})
+}
@@ -0,0 +1,79 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/jpegseg"
+)
+
+// IntegrityError reports output that is not a faithful copy of the input
+// where only the EXIF data and the segments selected by the options may change.
+type IntegrityError struct {
+	// Offset is the position in the output where the mismatch was found.
+	Offset int64
+	msg    string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("integrity check failed at output offset %d: %s", e.Offset, e.msg)
+}
+
+// checkIntegrity verifies that output is input with only the expected changes:
+// EXIF segments may be rewritten, including those whose header ParseLenient
+// normalizes, segments reported in RemovedSegments may be missing, IPTC and ICC
+// segments may change when the options say so, and everything from SOS on is
+// copied verbatim.
+func checkIntegrity(input, output []byte, result ExifRemoveThumbnailResult, o *options) error {
+	in, inSOS, err := jpegseg.Split(input)
+	if err != nil {
+		return &IntegrityError{msg: "input: " + err.Error()}
+	}
+	out, outSOS, err := jpegseg.Split(output)
+	if err != nil {
+		return &IntegrityError{msg: "output: " + err.Error()}
+	}
+	if !bytes.Equal(input[:2], output[:2]) {
+		return &IntegrityError{Offset: 0, msg: "SOI differs"}
+	}
+	// rewritten reports whether a segment may differ from the input.
+	rewritten := func(s jpegseg.Segment) bool {
+		payload := s.Payload()
+		switch {
+		case s.Marker == markerAPP0+1 && isExifSegment(payload):
+			return true
+		case s.Marker == markerAPP0+1 && o.parseMode == ParseLenient:
+			_, loose := looseExifHeader(payload)
+			return loose
+		case s.Marker == markerAPP13 && isPhotoshopSegment(payload):
+			return o.stripIPTC
+		case s.Marker == markerAPP2 && isICCSegment(payload):
+			return o.replaceICC != nil
+		}
+		return false
+	}
+	i := 0
+	for _, s := range out {
+		if rewritten(s) {
+			continue
+		}
+		for ; i < len(in) && !bytes.Equal(in[i].Data, s.Data); i++ {
+			if !rewritten(in[i]) && result.RemovedSegments[markerName(in[i].Marker, in[i].Payload())] == 0 {
+				return &IntegrityError{Offset: int64(s.Offset), msg: fmt.Sprintf("input segment 0x%04X at offset %d is missing or changed", in[i].Marker, in[i].Offset)}
+			}
+		}
+		if i == len(in) {
+			return &IntegrityError{Offset: int64(s.Offset), msg: fmt.Sprintf("segment 0x%04X is not in the input", s.Marker)}
+		}
+		i++
+	}
+	for ; i < len(in); i++ {
+		if !rewritten(in[i]) && result.RemovedSegments[markerName(in[i].Marker, in[i].Payload())] == 0 {
+			return &IntegrityError{Offset: int64(outSOS), msg: fmt.Sprintf("input segment 0x%04X at offset %d is missing", in[i].Marker, in[i].Offset)}
+		}
+	}
+	if !bytes.Equal(input[inSOS:], output[outSOS:]) {
+		return &IntegrityError{Offset: int64(outSOS), msg: "image data differs"}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestIntegrityCheck(t *testing.T) {
+	files := []string{"metadata_basic_exif.jpg", "metadata_full_exif.jpg", "metadata_gps.jpg", "metadata_none.jpg", "thumbnail_embedded.jpg", "thumbnail_none.jpg"}
+	optionSets := map[string][]exifremovethumbnail.Option{
+		"既定":        nil,
+		"EXIF全削除":   {exifremovethumbnail.WithStripAllExif(), exifremovethumbnail.WithKeepOrientation()},
+		"コメントとIPTC": {exifremovethumbnail.WithStripComments(), exifremovethumbnail.WithStripIPTC()},
+		"ICC置換":     {exifremovethumbnail.WithReplaceICC([]byte("profile"))},
+		"APP0削除":    {exifremovethumbnail.WithDropSegments("APP0")},
+	}
+
+	t.Run("正しい出力は検査を通る", func(t *testing.T) {
+		for _, file := range files {
+			inData, err := os.ReadFile(filepath.Join("testdata", file))
+			require.NoError(t, err)
+			for name, opts := range optionSets {
+				opts = append(opts, exifremovethumbnail.WithIntegrityCheck())
+				_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, opts...)
+				require.NoError(t, err, "%s: %s", file, name)
+			}
+		}
+	})
+
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	require.NoError(t, exifremovethumbnail.CheckIntegrity(inData, outData))
+
+	t.Run("画像データの変化を検出する", func(t *testing.T) {
+		broken := append([]byte{}, outData...)
+		broken[len(broken)-10] ^= 0xFF
+		var integrityErr *exifremovethumbnail.IntegrityError
+		require.ErrorAs(t, exifremovethumbnail.CheckIntegrity(inData, broken), &integrityErr)
+	})
+
+	t.Run("EXIF以外のセグメントの変化を検出する", func(t *testing.T) {
+		// APP0の中身を書き換える
+		broken := append([]byte{}, outData...)
+		broken[10] ^= 0xFF
+		var integrityErr *exifremovethumbnail.IntegrityError
+		require.ErrorAs(t, exifremovethumbnail.CheckIntegrity(inData, broken), &integrityErr)
+	})
+
+	t.Run("セグメントの欠落を検出する", func(t *testing.T) {
+		app0End := 4 + int(binary.BigEndian.Uint16(outData[4:6]))
+		broken := append(append([]byte{}, outData[:2]...), outData[app0End:]...)
+		require.True(t, bytes.HasPrefix(outData[2:], []byte{0xFF, 0xE0}))
+		var integrityErr *exifremovethumbnail.IntegrityError
+		require.ErrorAs(t, exifremovethumbnail.CheckIntegrity(inData, broken), &integrityErr)
+	})
+}
@@ -0,0 +1,82 @@
+package exifremovethumbnail
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+const (
+	photoshopHeader = "Photoshop 3.0\x00"
+	markerAPP13     = spec.APP13
+
+	// resourceIPTC is the Photoshop image resource ID holding IPTC-NAA data.
+	resourceIPTC = 0x0404
+)
+
+// photoshopResource is an image resource block inside an APP13 segment.
+// start and end delimit the whole block including padding.
+type photoshopResource struct {
+	id       uint16
+	dataSize int64
+	start    int
+	end      int
+}
+
+// isPhotoshopSegment reports whether an APP13 payload holds Photoshop image resources.
+func isPhotoshopSegment(segmentData []byte) bool {
+	return len(segmentData) >= len(photoshopHeader) && string(segmentData[:len(photoshopHeader)]) == photoshopHeader
+}
+
+// parsePhotoshopResources splits an APP13 payload into its 8BIM resource blocks.
+func parsePhotoshopResources(segmentData []byte) ([]photoshopResource, error) {
+	var resources []photoshopResource
+	pos := len(photoshopHeader)
+	for pos < len(segmentData) {
+		start := pos
+		if len(segmentData) < pos+7 || string(segmentData[pos:pos+4]) != "8BIM" {
+			return nil, fmt.Errorf("invalid image resource at %d", pos)
+		}
+		id := binary.BigEndian.Uint16(segmentData[pos+4 : pos+6])
+		// Pascal string name padded to an even length including its length byte
+		nameLen := int(segmentData[pos+6]) + 1
+		pos += 6 + nameLen + nameLen%2
+		if len(segmentData) < pos+4 {
+			return nil, fmt.Errorf("invalid image resource at %d", start)
+		}
+		size := int(binary.BigEndian.Uint32(segmentData[pos : pos+4]))
+		pos += 4 + size + size%2
+		if pos > len(segmentData) {
+			return nil, fmt.Errorf("image resource at %d overruns the segment", start)
+		}
+		resources = append(resources, photoshopResource{id: id, dataSize: int64(size), start: start, end: pos})
+	}
+	return resources, nil
+}
+
+// iptcSize returns the total size of IPTC-NAA data in an APP13 payload.
+func iptcSize(resources []photoshopResource) int64 {
+	var size int64
+	for _, r := range resources {
+		if r.id == resourceIPTC {
+			size += r.dataSize
+		}
+	}
+	return size
+}
+
+// stripIPTC removes IPTC-NAA resource blocks from an APP13 payload.
+// It reports whether any other resource remains.
+func stripIPTC(segmentData []byte, resources []photoshopResource) ([]byte, bool) {
+	out := append([]byte{}, segmentData[:len(photoshopHeader)]...)
+	remaining := false
+	for _, r := range resources {
+		if r.id == resourceIPTC {
+			continue
+		}
+		out = append(out, segmentData[r.start:r.end]...)
+		remaining = true
+	}
+	return out, remaining
+}
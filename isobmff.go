@@ -0,0 +1,180 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// canonUUID identifies the Canon metadata box of CR3 files holding the CMT1..CMT4 TIFF blocks.
+var canonUUID = []byte{0x85, 0xC0, 0xB6, 0x87, 0x82, 0x0F, 0x11, 0xE0, 0x81, 0x11, 0xF4, 0xCE, 0x46, 0x2B, 0x6A, 0x48}
+
+// isobmffContainers lists the boxes searched for EXIF boxes. The media data
+// (mdat) is never looked into.
+var isobmffContainers = map[string]bool{
+	"moov": true, "trak": true, "mdia": true, "minf": true, "udta": true, "meta": true, "uuid": true,
+}
+
+// isobmffImageBrands are the major brands of HEIF and AVIF images, which keep
+// EXIF in items rather than boxes and are not handled.
+var isobmffImageBrands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "mif1": true, "msf1": true, "avif": true, "avis": true,
+}
+
+// maxBoxDepth bounds the nesting of boxes followed by the ISOBMFF handler.
+const maxBoxDepth = 16
+
+// ISOBMFF returns a FormatHandler for ISO base media files: MP4, MOV and
+// Canon CR3. It is not registered by default; call RegisterFormat to opt in.
+//
+// The handler removes the IFD1 thumbnail from the TIFF/EXIF blocks stored in
+// boxes of the movie header, such as udta/Exif and the CMT1..CMT4 boxes of
+// CR3, and leaves the media data alone. Every box keeps its position so that
+// the sample offsets of the tracks stay valid: the freed bytes become a free
+// box after the rewritten one, or are zero-filled when too few for a box
+// header. The file size is therefore unchanged.
+func ISOBMFF() FormatHandler {
+	return isobmffHandler{}
+}
+
+type isobmffHandler struct{}
+
+func (isobmffHandler) Name() string {
+	return "isobmff"
+}
+
+func (isobmffHandler) Detect(header []byte) bool {
+	return len(header) >= 12 && string(header[4:8]) == "ftyp" && !isobmffImageBrands[string(header[8:12])]
+}
+
+// RemoveThumbnail returns data itself when nothing changes; otherwise the
+// output is a copy of data with the rewritten boxes patched in.
+func (h isobmffHandler) RemoveThumbnail(data []byte) ([]byte, ExifRemoveThumbnailResult, error) {
+	patches, result, err := h.patches(data)
+	if err != nil || len(patches) == 0 {
+		return data, result, err
+	}
+	var out bytes.Buffer
+	out.Grow(len(data))
+	writePatched(&out, data, patches)
+	return out.Bytes(), result, nil
+}
+
+// patches finds the boxes to rewrite without modifying data. Only the
+// rewritten boxes are held in memory, so files can be written by writePatched
+// without copying the media data.
+func (isobmffHandler) patches(data []byte) ([]bytePatch, ExifRemoveThumbnailResult, error) {
+	result := ExifRemoveThumbnailResult{BeforeSize: int64(len(data)), AfterSize: int64(len(data))}
+	var patches []bytePatch
+	err := walkBoxes(data, 0, len(data), 0, func(b box) {
+		if p, ok := removeBoxThumbnail(data, b, &result); ok {
+			patches = append(patches, p)
+		}
+	})
+	if err != nil {
+		return nil, result, err
+	}
+	result.Unchanged = len(patches) == 0
+	return patches, result, nil
+}
+
+// box is a box found by walkBoxes. The payload spans data[payload:end].
+type box struct {
+	typ     string
+	offset  int
+	header  int
+	payload int
+	end     int
+}
+
+// walkBoxes calls fn for every leaf box in data[start:end], descending into
+// the containers listed in isobmffContainers.
+func walkBoxes(data []byte, start, end, depth int, fn func(box)) error {
+	if depth > maxBoxDepth {
+		return fmt.Errorf("boxes nested deeper than %d at offset %d", maxBoxDepth, start)
+	}
+	for pos := start; pos+8 <= end; {
+		b := box{typ: string(data[pos+4 : pos+8]), offset: pos, header: 8}
+		size := uint64(binary.BigEndian.Uint32(data[pos:]))
+		switch size {
+		case 0:
+			size = uint64(end - pos)
+		case 1:
+			if pos+16 > end {
+				return fmt.Errorf("truncated %q box at offset %d: %w", b.typ, pos, ErrTruncated)
+			}
+			size = binary.BigEndian.Uint64(data[pos+8:])
+			b.header = 16
+		}
+		if size < uint64(b.header) || size > uint64(end-pos) {
+			return fmt.Errorf("invalid size %d of %q box at offset %d: %w", size, b.typ, pos, ErrTruncated)
+		}
+		b.payload, b.end = pos+b.header, pos+int(size)
+		if isobmffContainers[b.typ] {
+			children := b.payload
+			switch b.typ {
+			case "uuid":
+				// Only the Canon uuid box is known to hold boxes.
+				if b.end-children < 16 || !bytes.Equal(data[children:children+16], canonUUID) {
+					children = b.end
+				} else {
+					children += 16
+				}
+			case "meta":
+				// meta is a full box in MP4 but a plain container in QuickTime.
+				if b.end-children >= 4 && binary.BigEndian.Uint32(data[children:]) == 0 {
+					children += 4
+				}
+			}
+			if err := walkBoxes(data, children, b.end, depth+1, fn); err != nil {
+				return err
+			}
+		} else if b.typ != "mdat" {
+			fn(b)
+		}
+		pos = b.end
+	}
+	return nil
+}
+
+// removeBoxThumbnail returns b with the thumbnail removed from its TIFF
+// block, if any, as a patch of the same size so that no other byte of data
+// moves.
+func removeBoxThumbnail(data []byte, b box, result *ExifRemoveThumbnailResult) (bytePatch, bool) {
+	body := data[b.payload:b.end]
+	skip := 0
+	if isExifSegment(body) {
+		skip = len(exifHeader)
+	}
+	if !isTIFFHeader(body[skip:]) {
+		return bytePatch{}, false
+	}
+	result.ExifSegments++
+	exifData := append([]byte(exifHeader), body[skip:]...)
+	rewritten, res, err := removeThumbnailFromExif(exifData, &options{})
+	result.Warnings = append(result.Warnings, res.warnings...)
+	if err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%q box at offset %d left unchanged: %v", b.typ, b.offset, err))
+		return bytePatch{}, false
+	}
+	if !res.hadThumbnail {
+		return bytePatch{}, false
+	}
+	tiff := rewritten[len(exifHeader):]
+	if skip+len(tiff) > len(body) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%q box at offset %d left unchanged: rewritten EXIF is larger", b.typ, b.offset))
+		return bytePatch{}, false
+	}
+	result.HadThumbnail = true
+	result.ThumbnailSize += res.thumbnailSize
+	patched := make([]byte, b.end-b.offset)
+	copy(patched, data[b.offset:b.payload+skip])
+	newBody := patched[b.header:]
+	n := copy(newBody[skip:], tiff) + skip
+	if free := len(newBody) - n; free >= 8 && b.header == 8 {
+		binary.BigEndian.PutUint32(patched, uint32(b.header+n))
+		binary.BigEndian.PutUint32(newBody[n:], uint32(free))
+		copy(newBody[n+4:], "free")
+	}
+	return bytePatch{offset: b.offset, data: patched}, true
+}
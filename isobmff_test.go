@@ -0,0 +1,130 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+var registerISOBMFFOnce sync.Once
+
+// registerISOBMFF はISOBMFFハンドラを一度だけ登録する
+func registerISOBMFF() {
+	registerISOBMFFOnce.Do(func() { exifremovethumbnail.RegisterFormat(exifremovethumbnail.ISOBMFF()) })
+}
+
+// isoBox はサイズと種類を前置したボックスを作る
+func isoBox(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(b, typ...), body...)
+}
+
+// canonUUID はCR3のメタデータを収めるuuidボックスの識別子
+var canonUUID = []byte{0x85, 0xC0, 0xB6, 0x87, 0x82, 0x0F, 0x11, 0xE0, 0x81, 0x11, 0xF4, 0xCE, 0x46, 0x2B, 0x6A, 0x48}
+
+func TestISOBMFF(t *testing.T) {
+	registerISOBMFF()
+	tiff := testTIFF{
+		ifd0:      []testEntry{asciiEntry(0x010F, "TestMaker")},
+		ifd1:      []testEntry{shortEntry(binary.BigEndian, 0x0103, 6)},
+		thumbnail: append([]byte{0xFF, 0xD8}, bytes.Repeat([]byte{0xAB}, 200)...),
+	}.build()
+	mdat := isoBox("mdat", bytes.Repeat([]byte{0x11, 0x22}, 500))
+
+	for name, input := range map[string][]byte{
+		"MP4のudta/Exifボックス": bytes.Join([][]byte{
+			isoBox("ftyp", []byte("isom\x00\x00\x02\x00isommp41")),
+			isoBox("moov", isoBox("mvhd", make([]byte, 100)), isoBox("udta", isoBox("Exif", []byte("Exif\x00\x00"), tiff))),
+			mdat,
+		}, nil),
+		"CR3のCMT1ボックス": bytes.Join([][]byte{
+			isoBox("ftyp", []byte("crx \x00\x00\x00\x01crx isom")),
+			isoBox("moov", isoBox("uuid", canonUUID, isoBox("CNCV", []byte("CanonCR3_001/00.09.00/00.00.00")), isoBox("CMT1", tiff))),
+			mdat,
+		}, nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			original := append([]byte{}, input...)
+			require.Equal(t, "isobmff", exifremovethumbnail.DetectFormat(input))
+			out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(input)
+			require.NoError(t, err)
+			require.Equal(t, "isobmff", res.Format)
+			require.True(t, res.HadThumbnail)
+			require.Greater(t, res.ThumbnailSize, int64(200))
+			require.Equal(t, 1, res.ExifSegments)
+
+			require.Equal(t, original, input, "入力は変更しない")
+			require.Len(t, out, len(input), "ボックスの位置は変わらない")
+			require.Equal(t, mdat, out[len(out)-len(mdat):], "メディアデータには触れない")
+			blocks := exifremovethumbnail.FindExifBlocks(out)
+			require.Len(t, blocks, 1)
+			require.False(t, blocks[0].HasThumbnail)
+			require.Contains(t, string(out), "free", "空いた領域はfreeボックスになる")
+
+			_, again, err := exifremovethumbnail.ExifRemoveThumbnailBytes(out)
+			require.NoError(t, err)
+			require.False(t, again.HadThumbnail)
+		})
+	}
+
+	t.Run("サムネイルがなければ変更しない", func(t *testing.T) {
+		noThumb := testTIFF{ifd0: []testEntry{asciiEntry(0x010F, "TestMaker")}}.build()
+		input := bytes.Join([][]byte{
+			isoBox("ftyp", []byte("qt  \x00\x00\x02\x00qt  ")),
+			isoBox("moov", isoBox("udta", isoBox("Exif", noThumb))),
+			mdat,
+		}, nil)
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(input)
+		require.NoError(t, err)
+		require.False(t, res.HadThumbnail)
+		require.True(t, res.Unchanged)
+		require.Equal(t, input, out)
+	})
+
+	t.Run("ファイルはメディアデータを複製せずに書き出す", func(t *testing.T) {
+		const mediaSize = 32 << 20
+		input := bytes.Join([][]byte{
+			isoBox("ftyp", []byte("isom\x00\x00\x02\x00isommp41")),
+			isoBox("moov", isoBox("udta", isoBox("Exif", []byte("Exif\x00\x00"), tiff))),
+			isoBox("mdat", make([]byte, mediaSize)),
+		}, nil)
+		want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(input)
+		require.NoError(t, err)
+		dir := t.TempDir()
+		in := filepath.Join(dir, "in.mp4")
+		require.NoError(t, os.WriteFile(in, input, 0644))
+
+		out := filepath.Join(dir, "out.mp4")
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		res, err := exifremovethumbnail.ExifRemoveThumbnail(in, out)
+		runtime.ReadMemStats(&after)
+		require.NoError(t, err)
+		require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(mediaSize*3/2), "入力を読む分だけ")
+		require.Equal(t, "isobmff", res.Format)
+		require.True(t, res.HadThumbnail)
+		got, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(want, got))
+	})
+
+	t.Run("HEIFは対象外", func(t *testing.T) {
+		input := isoBox("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+		require.Equal(t, "", exifremovethumbnail.DetectFormat(input))
+	})
+
+	t.Run("壊れたボックスはエラー", func(t *testing.T) {
+		input := append(isoBox("ftyp", []byte("isom\x00\x00\x02\x00")), 0x00, 0x00, 0x10, 0x00, 'm', 'o', 'o', 'v')
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(input)
+		require.ErrorIs(t, err, exifremovethumbnail.ErrTruncated)
+	})
+}
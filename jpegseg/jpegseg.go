@@ -0,0 +1,148 @@
+// Package jpegseg splits JPEG data into the marker segments in front of the
+// image data.
+//
+// It is the segment walker exifremovethumbnail uses itself, exported so that
+// analysis tools can reuse it. Segments are returned in place without copying
+// the data, and malformed lengths are reported instead of read past.
+package jpegseg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+// ErrNotJPEG is returned for data that does not start with a SOI marker.
+var ErrNotJPEG = errors.New("not a JPEG file")
+
+// SyntaxError reports JPEG data that cannot be split into segments.
+type SyntaxError struct {
+	// Offset is the position of the offending marker.
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Msg, e.Offset)
+}
+
+// Segment is a marker segment with a length field.
+type Segment struct {
+	Marker uint16
+	// Offset is the position of the marker in the data.
+	Offset int
+	// Data is the whole segment: the marker, the length field and the
+	// payload. It shares the memory of the scanned data.
+	Data []byte
+}
+
+// Payload returns the segment data after the length field.
+func (s Segment) Payload() []byte {
+	return s.Data[4:]
+}
+
+// Scanner walks the segments of JPEG data from SOI to the first SOS marker.
+//
+//	sc := jpegseg.NewScanner(data)
+//	for sc.Next() {
+//		s := sc.Segment()
+//		...
+//	}
+//	if err := sc.Err(); err != nil {
+//		...
+//	}
+//	imageData := data[sc.End():]
+type Scanner struct {
+	data []byte
+	pos  int
+	seg  Segment
+	err  error
+	done bool
+}
+
+// NewScanner returns a Scanner over data.
+func NewScanner(data []byte) *Scanner {
+	return &Scanner{data: data}
+}
+
+// Next advances to the next segment. It returns false at the SOS or EOI
+// marker, at the end of the data or on an error.
+func (s *Scanner) Next() bool {
+	if s.done {
+		return false
+	}
+	if s.pos == 0 {
+		if len(s.data) < 2 || binary.BigEndian.Uint16(s.data) != spec.SOI {
+			return s.fail(ErrNotJPEG)
+		}
+		s.pos = 2
+	}
+	for s.pos+2 <= len(s.data) {
+		marker := binary.BigEndian.Uint16(s.data[s.pos:])
+		switch {
+		case marker == 0xFFFF:
+			// Fill bytes may precede a marker.
+			s.pos++
+			continue
+		case marker == spec.SOS || marker == spec.EOI:
+			s.done = true
+			return false
+		case marker&0xFF00 != 0xFF00:
+			return s.fail(&SyntaxError{Offset: s.pos, Msg: "invalid marker"})
+		}
+		if s.pos+4 > len(s.data) {
+			break
+		}
+		end := s.pos + 2 + int(binary.BigEndian.Uint16(s.data[s.pos+2:]))
+		if end > len(s.data) || end < s.pos+4 {
+			break
+		}
+		s.seg = Segment{Marker: marker, Offset: s.pos, Data: s.data[s.pos:end]}
+		s.pos = end
+		return true
+	}
+	if s.pos != len(s.data) {
+		return s.fail(&SyntaxError{Offset: s.pos, Msg: "malformed segment"})
+	}
+	s.done = true
+	return false
+}
+
+func (s *Scanner) fail(err error) bool {
+	s.err = err
+	s.done = true
+	return false
+}
+
+// Segment returns the segment found by the last call to Next.
+func (s *Scanner) Segment() Segment {
+	return s.seg
+}
+
+// Err returns the error that stopped the scan, if any.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// End returns the offset where the segments end once Next has returned
+// false: the position of the SOS or EOI marker, or len(data) if there is
+// none. Before that it is the offset the scan has reached.
+func (s *Scanner) End() int {
+	return s.pos
+}
+
+// Split returns the segments of data in front of the image data and the
+// offset where they end, as reported by Scanner.End.
+func Split(data []byte) ([]Segment, int, error) {
+	var segments []Segment
+	sc := NewScanner(data)
+	for sc.Next() {
+		segments = append(segments, sc.Segment())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, 0, err
+	}
+	return segments, sc.End(), nil
+}
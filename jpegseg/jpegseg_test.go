@@ -0,0 +1,84 @@
+package jpegseg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+	"github.com/ideamans/go-exif-remove-thumbnail/jpegseg"
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+func TestScanner(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+
+	t.Run("Analyzeと同じセグメントを返す", func(t *testing.T) {
+		report, err := exifremovethumbnail.Analyze(data)
+		require.NoError(t, err)
+		segments, end, err := jpegseg.Split(data)
+		require.NoError(t, err)
+		require.Len(t, segments, len(report.Segments)-1)
+		for i, s := range segments {
+			// report.Segments[0]はSOI
+			require.Equal(t, report.Segments[i+1].Offset, int64(s.Offset))
+			require.Equal(t, report.Segments[i+1].Size, int64(len(s.Data)))
+		}
+		require.Equal(t, uint16(spec.APP0), segments[0].Marker)
+		require.Equal(t, uint16(spec.APP1), segments[1].Marker)
+		require.Equal(t, spec.ExifHeader, string(segments[1].Payload()[:6]))
+		require.Equal(t, []byte{0xFF, 0xDA}, data[end:end+2])
+		require.Equal(t, report.ImageDataSize+report.TrailerSize, int64(len(data)-end))
+
+		// データを共有している
+		require.Same(t, &data[segments[0].Offset], &segments[0].Data[0])
+	})
+
+	t.Run("途中で止められる", func(t *testing.T) {
+		sc := jpegseg.NewScanner(data)
+		require.True(t, sc.Next())
+		require.Equal(t, 2, sc.Segment().Offset)
+		require.Equal(t, 20, sc.End())
+		require.NoError(t, sc.Err())
+	})
+
+	t.Run("詰め物とEOI", func(t *testing.T) {
+		data := []byte{0xFF, 0xD8, 0xFF, 0xFF, 0xFF, 0xFE, 0x00, 0x03, 'x', 0xFF, 0xD9}
+		segments, end, err := jpegseg.Split(data)
+		require.NoError(t, err)
+		require.Len(t, segments, 1)
+		require.Equal(t, uint16(spec.COM), segments[0].Marker)
+		require.Equal(t, 4, segments[0].Offset)
+		require.Equal(t, []byte("x"), segments[0].Payload())
+		require.Equal(t, 9, end)
+	})
+
+	t.Run("壊れたデータ", func(t *testing.T) {
+		_, _, err := jpegseg.Split([]byte("not a jpeg"))
+		require.ErrorIs(t, err, jpegseg.ErrNotJPEG)
+
+		var syntaxErr *jpegseg.SyntaxError
+		// 長さがデータを超える
+		_, _, err = jpegseg.Split([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x10, 0x00})
+		require.ErrorAs(t, err, &syntaxErr)
+		require.Equal(t, 2, syntaxErr.Offset)
+		// 長さが2未満
+		_, _, err = jpegseg.Split([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01})
+		require.ErrorAs(t, err, &syntaxErr)
+		// マーカーでない
+		_, _, err = jpegseg.Split([]byte{0xFF, 0xD8, 0x12, 0x34, 0x00, 0x04})
+		require.ErrorAs(t, err, &syntaxErr)
+		require.Equal(t, "invalid marker at offset 2", err.Error())
+	})
+
+	t.Run("SOSがなければデータの終わり", func(t *testing.T) {
+		data := []byte{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x02}
+		segments, end, err := jpegseg.Split(data)
+		require.NoError(t, err)
+		require.Len(t, segments, 1)
+		require.Equal(t, len(data), end)
+	})
+}
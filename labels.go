@@ -0,0 +1,101 @@
+package exifremovethumbnail
+
+// Language selects the language of human-readable labels in reports.
+type Language string
+
+// Supported label languages.
+const (
+	English  Language = "en"
+	Japanese Language = "ja"
+)
+
+// tagLabels holds human-readable tag labels by language, keyed by the tag name.
+// Labels of tags appearing in several IFDs are shared.
+var tagLabels = map[Language]map[string]string{
+	English: {
+		"ImageWidth": "Image width", "ImageLength": "Image height", "Compression": "Compression",
+		"ImageDescription": "Image description", "Make": "Camera maker", "Model": "Camera model",
+		"Orientation": "Orientation", "XResolution": "Horizontal resolution", "YResolution": "Vertical resolution",
+		"ResolutionUnit": "Resolution unit", "Software": "Software", "DateTime": "Date modified",
+		"Artist": "Author", "Rating": "Rating", "Copyright": "Copyright",
+		"JPEGInterchangeFormat": "Thumbnail offset", "JPEGInterchangeFormatLength": "Thumbnail size",
+		"YCbCrPositioning": "YCbCr positioning", "ExifIFDPointer": "Exif data", "GPSInfoIFDPointer": "GPS data",
+		"ExposureTime": "Exposure time", "FNumber": "F-number", "ExposureProgram": "Exposure program",
+		"ISOSpeedRatings": "ISO speed", "ExifVersion": "Exif version", "DateTimeOriginal": "Date taken",
+		"DateTimeDigitized": "Date digitized", "OffsetTime": "Time zone", "OffsetTimeOriginal": "Time zone of date taken",
+		"OffsetTimeDigitized": "Time zone of date digitized", "ShutterSpeedValue": "Shutter speed",
+		"ApertureValue": "Aperture", "BrightnessValue": "Brightness", "ExposureBiasValue": "Exposure bias",
+		"MaxApertureValue": "Max aperture", "SubjectDistance": "Subject distance", "MeteringMode": "Metering mode",
+		"LightSource": "Light source", "Flash": "Flash", "FocalLength": "Focal length", "MakerNote": "Maker note",
+		"UserComment": "User comment", "ColorSpace": "Color space", "PixelXDimension": "Image width",
+		"PixelYDimension": "Image height", "ExposureMode": "Exposure mode", "WhiteBalance": "White balance",
+		"DigitalZoomRatio": "Digital zoom", "FocalLengthIn35mmFilm": "35mm focal length",
+		"SceneCaptureType": "Scene type", "ImageUniqueID": "Image ID", "CameraOwnerName": "Camera owner",
+		"BodySerialNumber": "Camera serial number", "LensSpecification": "Lens specification",
+		"LensMake": "Lens maker", "LensModel": "Lens model", "LensSerialNumber": "Lens serial number",
+		"GPSVersionID": "GPS version", "GPSLatitudeRef": "Latitude reference", "GPSLatitude": "Latitude",
+		"GPSLongitudeRef": "Longitude reference", "GPSLongitude": "Longitude", "GPSAltitudeRef": "Altitude reference",
+		"GPSAltitude": "Altitude", "GPSTimeStamp": "GPS time", "GPSSatellites": "GPS satellites",
+		"GPSSpeed": "Speed", "GPSTrack": "Direction of movement", "GPSImgDirection": "Image direction",
+		"GPSMapDatum": "Map datum", "GPSProcessingMethod": "Positioning method", "GPSDateStamp": "GPS date",
+	},
+	Japanese: {
+		"ImageWidth": "画像の幅", "ImageLength": "画像の高さ", "Compression": "圧縮方式",
+		"ImageDescription": "画像の説明", "Make": "カメラのメーカー", "Model": "カメラの機種",
+		"Orientation": "画像の向き", "XResolution": "水平解像度", "YResolution": "垂直解像度",
+		"ResolutionUnit": "解像度の単位", "Software": "ソフトウェア", "DateTime": "更新日時",
+		"Artist": "作成者", "Rating": "評価", "Copyright": "著作権",
+		"JPEGInterchangeFormat": "サムネイルの位置", "JPEGInterchangeFormatLength": "サムネイルのサイズ",
+		"YCbCrPositioning": "YCbCr の配置", "ExifIFDPointer": "Exif 情報", "GPSInfoIFDPointer": "GPS 情報",
+		"ExposureTime": "露出時間", "FNumber": "F 値", "ExposureProgram": "露出プログラム",
+		"ISOSpeedRatings": "ISO 感度", "ExifVersion": "Exif バージョン", "DateTimeOriginal": "撮影日時",
+		"DateTimeDigitized": "デジタル化日時", "OffsetTime": "タイムゾーン", "OffsetTimeOriginal": "撮影日時のタイムゾーン",
+		"OffsetTimeDigitized": "デジタル化日時のタイムゾーン", "ShutterSpeedValue": "シャッタースピード",
+		"ApertureValue": "絞り", "BrightnessValue": "輝度", "ExposureBiasValue": "露出補正",
+		"MaxApertureValue": "開放絞り", "SubjectDistance": "被写体距離", "MeteringMode": "測光方式",
+		"LightSource": "光源", "Flash": "フラッシュ", "FocalLength": "焦点距離", "MakerNote": "メーカーノート",
+		"UserComment": "ユーザーコメント", "ColorSpace": "色空間", "PixelXDimension": "画像の幅",
+		"PixelYDimension": "画像の高さ", "ExposureMode": "露出モード", "WhiteBalance": "ホワイトバランス",
+		"DigitalZoomRatio": "デジタルズーム", "FocalLengthIn35mmFilm": "35mm 換算焦点距離",
+		"SceneCaptureType": "撮影シーン", "ImageUniqueID": "画像 ID", "CameraOwnerName": "カメラの所有者",
+		"BodySerialNumber": "カメラのシリアル番号", "LensSpecification": "レンズの仕様",
+		"LensMake": "レンズのメーカー", "LensModel": "レンズの機種", "LensSerialNumber": "レンズのシリアル番号",
+		"GPSVersionID": "GPS バージョン", "GPSLatitudeRef": "緯度の基準", "GPSLatitude": "緯度",
+		"GPSLongitudeRef": "経度の基準", "GPSLongitude": "経度", "GPSAltitudeRef": "高度の基準",
+		"GPSAltitude": "高度", "GPSTimeStamp": "GPS 時刻", "GPSSatellites": "GPS 衛星",
+		"GPSSpeed": "移動速度", "GPSTrack": "移動方向", "GPSImgDirection": "撮影方向",
+		"GPSMapDatum": "測地系", "GPSProcessingMethod": "測位方式", "GPSDateStamp": "GPS 日付",
+	},
+}
+
+// ifdLabels holds human-readable IFD labels by language.
+var ifdLabels = map[Language]map[IFD]string{
+	English:  {IFD0: "Main image", IFDExif: "Exif", IFDGPS: "GPS", IFDInterop: "Interoperability", IFD1: "Thumbnail"},
+	Japanese: {IFD0: "主画像", IFDExif: "Exif", IFDGPS: "GPS", IFDInterop: "互換性", IFD1: "サムネイル"},
+}
+
+// Label returns a name of the IFD that non-experts can read, e.g. "Thumbnail".
+// Unsupported languages fall back to English.
+func (i IFD) Label(lang Language) string {
+	if label, ok := ifdLabels[lang][i]; ok {
+		return label
+	}
+	if label, ok := ifdLabels[English][i]; ok {
+		return label
+	}
+	return i.String()
+}
+
+// Label returns a name of the tag that non-experts can read, e.g. "Date taken"
+// or "撮影日時" for Exif.DateTimeOriginal. Unsupported languages fall back to
+// English, and tags without a label to String.
+func (r TagRef) Label(lang Language) string {
+	name := r.Name()
+	if label, ok := tagLabels[lang][name]; ok {
+		return label
+	}
+	if label, ok := tagLabels[English][name]; ok {
+		return label
+	}
+	return r.String()
+}
@@ -0,0 +1,35 @@
+package exifremovethumbnail_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestLabels(t *testing.T) {
+	dateTaken := exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFDExif, ID: 0x9003}
+
+	t.Run("英語と日本語のタグ名", func(t *testing.T) {
+		require.Equal(t, "Date taken", dateTaken.Label(exifremovethumbnail.English))
+		require.Equal(t, "撮影日時", dateTaken.Label(exifremovethumbnail.Japanese))
+		latitude := exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFDGPS, ID: 0x0002}
+		require.Equal(t, "緯度", latitude.Label(exifremovethumbnail.Japanese))
+	})
+
+	t.Run("未対応の言語は英語", func(t *testing.T) {
+		require.Equal(t, "Date taken", dateTaken.Label("fr"))
+		require.Equal(t, "Thumbnail", exifremovethumbnail.IFD1.Label("fr"))
+	})
+
+	t.Run("ラベルのないタグは識別子", func(t *testing.T) {
+		unknown := exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFD0, ID: 0xC4A5}
+		require.Equal(t, "IFD0.0xC4A5", unknown.Label(exifremovethumbnail.Japanese))
+	})
+
+	t.Run("IFDの名前", func(t *testing.T) {
+		require.Equal(t, "サムネイル", exifremovethumbnail.IFD1.Label(exifremovethumbnail.Japanese))
+		require.Equal(t, "Main image", exifremovethumbnail.IFD0.Label(exifremovethumbnail.English))
+	})
+}
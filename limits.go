@@ -0,0 +1,71 @@
+package exifremovethumbnail
+
+import "fmt"
+
+// Limits bounds the resources spent on one input. Zero fields mean no limit.
+type Limits struct {
+	// MaxAPP1Size is the largest APP1 segment payload accepted, in bytes.
+	MaxAPP1Size int
+	// MaxMetadataSize is the largest total payload of the APPn and COM segments, in bytes.
+	MaxMetadataSize int64
+	// MaxIFDEntries is the largest number of IFD entries accepted in one EXIF segment,
+	// counted over all of its IFDs.
+	MaxIFDEntries int
+}
+
+// LimitError reports input exceeding one of the Limits.
+type LimitError struct {
+	// Limit names the exceeded limit: "app1_size", "metadata_size" or "ifd_entries".
+	Limit string
+	Max   int64
+	Value int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %d exceeds the limit of %d", e.Limit, e.Value, e.Max)
+}
+
+// WithLimits rejects input exceeding l with a *LimitError. Segment sizes are
+// checked before the segment is read, so services exposed to untrusted uploads
+// can bound the memory and CPU spent on metadata.
+func WithLimits(l Limits) Option {
+	return func(o *options) {
+		o.limits = l
+	}
+}
+
+// checkSegment checks a segment with a payload of size bytes before it is read.
+// metadata is the total payload of the metadata segments so far, including this one.
+func (l Limits) checkSegment(marker uint16, size int, metadata int64) error {
+	if marker == markerAPP0+1 && l.MaxAPP1Size > 0 && size > l.MaxAPP1Size {
+		return &LimitError{Limit: "app1_size", Max: int64(l.MaxAPP1Size), Value: int64(size)}
+	}
+	if l.MaxMetadataSize > 0 && metadata > l.MaxMetadataSize {
+		return &LimitError{Limit: "metadata_size", Max: l.MaxMetadataSize, Value: metadata}
+	}
+	return nil
+}
+
+// checkExif checks the IFDs of an EXIF segment payload before they are processed.
+// Unparsable data is left to the processing to report.
+func (l Limits) checkExif(segmentData []byte) error {
+	if l.MaxIFDEntries <= 0 {
+		return nil
+	}
+	t, err := parseTIFF(segmentData[len(exifHeader):])
+	if err != nil {
+		return nil
+	}
+	dirs, err := t.ifds()
+	if err != nil {
+		return nil
+	}
+	entries := 0
+	for _, d := range dirs {
+		entries += len(d.Entries)
+	}
+	if entries > l.MaxIFDEntries {
+		return &LimitError{Limit: "ifd_entries", Max: int64(l.MaxIFDEntries), Value: int64(entries)}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package exifremovethumbnail_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestLimits(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	report, err := exifremovethumbnail.Analyze(inData)
+	require.NoError(t, err)
+	var app1Size, metadataSize int64
+	for _, s := range report.Segments {
+		if s.Name == exifremovethumbnail.SegmentExif {
+			app1Size = s.Size - 4
+		}
+		if strings.HasPrefix(string(s.Name), "APP") || s.Name == "COM" {
+			metadataSize += s.Size - 4
+		}
+	}
+	require.Greater(t, app1Size, int64(0))
+
+	t.Run("上限内なら処理する", func(t *testing.T) {
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithLimits(exifremovethumbnail.Limits{
+			MaxAPP1Size:     int(app1Size),
+			MaxMetadataSize: metadataSize,
+			MaxIFDEntries:   1000,
+		}))
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+	})
+
+	t.Run("APP1のサイズ", func(t *testing.T) {
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithLimits(exifremovethumbnail.Limits{MaxAPP1Size: int(app1Size) - 1}))
+		var limitErr *exifremovethumbnail.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		require.Equal(t, "app1_size", limitErr.Limit)
+		require.Equal(t, app1Size, limitErr.Value)
+	})
+
+	t.Run("メタデータの合計サイズ", func(t *testing.T) {
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithLimits(exifremovethumbnail.Limits{MaxMetadataSize: metadataSize - 1}))
+		var limitErr *exifremovethumbnail.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		require.Equal(t, "metadata_size", limitErr.Limit)
+	})
+
+	t.Run("IFDエントリ数", func(t *testing.T) {
+		payload := testTIFF{
+			ifd0: []testEntry{asciiEntry(0x010F, "TestMaker"), asciiEntry(0x0110, "TestModel")},
+			exif: []testEntry{asciiEntry(0x9003, "2024:01:02 03:04:05")},
+		}.exifPayload()
+		data := jpegWithExif(t, payload)
+		// IFD0の2エントリとExifIFDポインタ、ExifIFDの1エントリ
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(data,
+			exifremovethumbnail.WithLimits(exifremovethumbnail.Limits{MaxIFDEntries: 4}))
+		require.NoError(t, err)
+
+		_, _, err = exifremovethumbnail.ExifRemoveThumbnailBytes(data,
+			exifremovethumbnail.WithLimits(exifremovethumbnail.Limits{MaxIFDEntries: 3}))
+		var limitErr *exifremovethumbnail.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		require.Equal(t, "ifd_entries", limitErr.Limit)
+		require.Equal(t, int64(4), limitErr.Value)
+	})
+}
@@ -0,0 +1,38 @@
+package exifremovethumbnail
+
+import (
+	"errors"
+	"os"
+)
+
+// errMmapUnsupported is returned by mapFile on platforms without mmap.
+var errMmapUnsupported = errors.New("memory mapping is not supported on this platform")
+
+// WithMmap makes ExifRemoveThumbnail map the input file into memory instead of
+// reading it, so that huge files such as multi-hundred-MB panoramas are served
+// from the OS page cache rather than copied onto the heap. Where mapping is not
+// supported (platforms other than Linux and macOS, empty files) the file is
+// read as usual. The output is not affected. The input file must not be
+// truncated while it is processed, which would crash the process.
+func WithMmap() Option {
+	return func(o *options) {
+		o.mmap = true
+	}
+}
+
+// readInput returns the contents of the file at path: mapped with WithMmap,
+// else read into memory. release must be called once the data is no longer used.
+func readInput(path string, o *options) (data []byte, release func(), err error) {
+	if o.mmap {
+		if f, err := os.Open(path); err == nil {
+			defer f.Close()
+			if info, err := f.Stat(); err == nil {
+				if data, unmap, err := mapFile(f, info.Size()); err == nil {
+					return data, unmap, nil
+				}
+			}
+		}
+	}
+	data, err = os.ReadFile(path)
+	return data, func() {}, err
+}
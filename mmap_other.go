@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package exifremovethumbnail
+
+import "os"
+
+// mapFile reports that memory mapping is not supported on this platform.
+func mapFile(f *os.File, size int64) ([]byte, func(), error) {
+	return nil, nil, errMmapUnsupported
+}
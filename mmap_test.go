@@ -0,0 +1,51 @@
+package exifremovethumbnail_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestMmap(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "in.jpg")
+	require.NoError(t, os.WriteFile(path, inData, 0644))
+
+	for name, opts := range map[string][]exifremovethumbnail.Option{
+		"ストリーミング":  {exifremovethumbnail.WithMmap()},
+		"メモリ上での処理": {exifremovethumbnail.WithMmap(), exifremovethumbnail.WithIntegrityCheck()},
+	} {
+		t.Run(name+"でも同じ出力", func(t *testing.T) {
+			out := filepath.Join(dir, "out.jpg")
+			res, err := exifremovethumbnail.ExifRemoveThumbnail(path, out, opts...)
+			require.NoError(t, err)
+			require.True(t, res.HadThumbnail)
+			got, err := os.ReadFile(out)
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+
+			// 処理済みのファイルは書き戻さずにそのまま残す
+			res, err = exifremovethumbnail.ExifRemoveThumbnail(out, out, opts...)
+			require.NoError(t, err)
+			require.True(t, res.Unchanged)
+			got, err = os.ReadFile(out)
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		})
+	}
+
+	t.Run("空のファイルは通常どおり読み込む", func(t *testing.T) {
+		empty := filepath.Join(dir, "empty.jpg")
+		require.NoError(t, os.WriteFile(empty, nil, 0644))
+		_, err := exifremovethumbnail.ExifRemoveThumbnail(empty, filepath.Join(dir, "x.jpg"), exifremovethumbnail.WithMmap())
+		require.ErrorIs(t, err, exifremovethumbnail.ErrNotJPEG)
+	})
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package exifremovethumbnail_test
+
+const raceEnabled = false
@@ -0,0 +1,472 @@
+package exifremovethumbnail
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Option configures optional behavior of the thumbnail removal functions.
+type Option func(*options)
+
+type options struct {
+	stripAllExif    bool
+	keepOrientation bool
+	keepICC         bool
+	removeTags      []TagRef
+	removeGPS       bool
+	keepThumbnail   bool
+	stripComments   bool
+	keepSegments    []Segment
+	dropSegments    []Segment
+	stripICC        bool
+	replaceICC      []byte
+	stripIPTC       bool
+	stripTrailer    bool
+	windowSize      int
+	verifyPixels    bool
+	softMemory      int64
+	softTime        time.Duration
+	compactExif     bool
+	reportRetained  bool
+	skipRisky       bool
+	parseMode       ParseMode
+	maxInputSize    int64
+	limits          Limits
+	checkIntegrity  bool
+	conformance     bool
+	modifiedAfter   time.Time
+	modifiedBefore  time.Time
+	newerThan       time.Duration
+	olderThan       time.Duration
+	dedupeExif      bool
+	minFileSize     int64
+	maxFileSize     int64
+	extensions      []string
+	includePaths    []*regexp.Regexp
+	excludePaths    []*regexp.Regexp
+	largestFirst    bool
+	fault           Fault
+	faultAfter      int64
+	allowTruncated  bool
+	verifyImageData bool
+	validateOutput  bool
+	mmap            bool
+	constantSize    bool
+	visitors        []SegmentVisitor
+	exifBackend     ExifBackend
+	onSkip          func(path string, reason SkipReason)
+	resultStream    io.Writer
+	checkpoint      string
+	stateStore      StateStore
+}
+
+// defaultWindowSize is the copy window used for image data after SOS.
+const defaultWindowSize = 32 * 1024
+
+func newOptions(opts []Option) *options {
+	o := &options{windowSize: defaultWindowSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithStripAllExif removes whole metadata segments instead of only the thumbnail.
+// APP1 EXIF and XMP segments and APP2 ICC profile segments are dropped.
+// Combine with WithKeepOrientation or WithKeepICC to retain what is needed for correct display.
+func WithStripAllExif() Option {
+	return func(o *options) {
+		o.stripAllExif = true
+	}
+}
+
+// WithKeepOrientation keeps the Orientation tag when EXIF is stripped.
+// The EXIF segment is replaced by a minimal one holding only Orientation.
+func WithKeepOrientation() Option {
+	return func(o *options) {
+		o.keepOrientation = true
+	}
+}
+
+// WithKeepICC keeps APP2 ICC profile segments when EXIF is stripped.
+func WithKeepICC() Option {
+	return func(o *options) {
+		o.keepICC = true
+	}
+}
+
+// WithRemoveTags removes the given tags during the same rewrite pass.
+// For example TagRef{IFDExif, 0x9286} removes UserComment.
+// Tags actually found are reported in ExifRemoveThumbnailResult.RemovedTags.
+func WithRemoveTags(tags ...TagRef) Option {
+	return func(o *options) {
+		o.removeTags = append(o.removeTags, tags...)
+	}
+}
+
+// WithRemoveMakerNote drops the MakerNote tag from the EXIF IFD.
+// The entry is removed from the IFD and its value is zero-filled, so the rest of the EXIF stays valid.
+func WithRemoveMakerNote() Option {
+	return WithRemoveTags(TagRef{IFD: IFDExif, ID: tagMakerNote})
+}
+
+// WithRemoveOwnerInfo removes tags identifying the camera or its owner:
+// BodySerialNumber, LensSerialNumber, CameraOwnerName and Artist.
+// Tags actually found are reported in ExifRemoveThumbnailResult.RemovedTags.
+func WithRemoveOwnerInfo() Option {
+	return WithRemoveTags(
+		TagRef{IFD: IFD0, ID: tagArtist},
+		TagRef{IFD: IFDExif, ID: tagCameraOwnerName},
+		TagRef{IFD: IFDExif, ID: tagBodySerialNumber},
+		TagRef{IFD: IFDExif, ID: tagLensSerialNumber},
+	)
+}
+
+// WithRemoveGPS removes the GPS IFD pointer from IFD0 and zero-fills the GPS data.
+// When GPS data was present, TagRef{IFD0, 0x8825} is reported in RemovedTags.
+func WithRemoveGPS() Option {
+	return func(o *options) {
+		o.removeGPS = true
+	}
+}
+
+// WithStripComments removes JPEG comment (COM) segments.
+func WithStripComments() Option {
+	return func(o *options) {
+		o.stripComments = true
+	}
+}
+
+// WithKeepSegments sets a whitelist of application segments.
+// APPn segments not matched by any of names are removed,
+// e.g. WithKeepSegments("APP0", SegmentExif, SegmentICC).
+func WithKeepSegments(names ...Segment) Option {
+	return func(o *options) {
+		o.keepSegments = append(o.keepSegments, names...)
+	}
+}
+
+// WithDropSegments sets a blacklist of application segments to remove.
+// It takes precedence over WithKeepSegments.
+func WithDropSegments(names ...Segment) Option {
+	return func(o *options) {
+		o.dropSegments = append(o.dropSegments, names...)
+	}
+}
+
+// WithStripICC removes APP2 ICC profile segments.
+// Without this option ICC profiles are always preserved.
+func WithStripICC() Option {
+	return func(o *options) {
+		o.stripICC = true
+	}
+}
+
+// WithReplaceICC replaces the ICC profile with profile, e.g. an sRGB profile.
+// The profile is inserted even if the input had none, split into as many
+// APP2 segments as needed.
+func WithReplaceICC(profile []byte) Option {
+	return func(o *options) {
+		o.replaceICC = profile
+	}
+}
+
+// WithStripIPTC removes IPTC-NAA resources from APP13 segments.
+// Other Photoshop image resources are kept; a segment left empty is removed.
+// Without this option APP13 segments are passed through unchanged.
+func WithStripIPTC() Option {
+	return func(o *options) {
+		o.stripIPTC = true
+	}
+}
+
+// WithStripTrailer removes the data after the EOI marker, such as appended
+// archives or vendor blobs, which is otherwise copied as is. The removed bytes
+// are reported in RemovedSegments as SegmentTrailer. With WithConstantSize the
+// trailer is kept so that the size does not change.
+func WithStripTrailer() Option {
+	return func(o *options) {
+		o.stripTrailer = true
+	}
+}
+
+// WithWindowSize sets the size of the window used to copy image data after SOS.
+// Processing never buffers more than the larger of one segment and this window;
+// the actual peak is reported in ExifRemoveThumbnailResult.PeakBufferedBytes.
+// Sizes below 512 bytes are raised to 512.
+func WithWindowSize(n int) Option {
+	return func(o *options) {
+		o.windowSize = max(n, 512)
+	}
+}
+
+// WithVerifyPixels decodes the input and the output and fails with a *VerifyError
+// unless the decoded pixels are identical. See VerifyPixels for the decoders used.
+// It applies to ExifRemoveThumbnailBytes and ExifRemoveThumbnail; NewReader does
+// not hold the whole image and ignores it.
+func WithVerifyPixels() Option {
+	return func(o *options) {
+		o.verifyPixels = true
+	}
+}
+
+// WithSoftLimits sets best-effort resource limits. Instead of failing, optional
+// work that would exceed them is skipped and the downgrade is recorded in
+// ExifRemoveThumbnailResult.Warnings. Currently this affects WithVerifyPixels:
+// verification is skipped when decoding both images would need more than
+// maxMemory bytes, or when maxDuration has already elapsed. Zero disables a limit.
+func WithSoftLimits(maxMemory int64, maxDuration time.Duration) Option {
+	return func(o *options) {
+		o.softMemory = maxMemory
+		o.softTime = maxDuration
+	}
+}
+
+// WithCompactExif repacks the EXIF TIFF structure after removal. Value blocks
+// no longer referenced by any IFD, such as those of removed tags, and padding
+// are dropped so the APP1 segment shrinks to its minimal valid size.
+// Word alignment of the remaining data is kept. It has no effect while the
+// thumbnail is kept.
+func WithCompactExif() Option {
+	return func(o *options) {
+		o.compactExif = true
+	}
+}
+
+// WithConstantSize keeps the output the size of the input: the bytes removed
+// from the headers are replaced by padding segments (SegmentPadding) in front
+// of SOS, so the offsets of the image data and everything after it stay the
+// same. Fewer than 12 freed bytes become 0xFF fill bytes instead. Headers that
+// grow, as with WithReplaceICC, are not padded. The padding can be reclaimed
+// later with WithDropSegments(SegmentPadding).
+func WithConstantSize() Option {
+	return func(o *options) {
+		o.constantSize = true
+	}
+}
+
+// WithRetainedTagsReport lists the EXIF tags remaining in the output in
+// ExifRemoveThumbnailResult.RetainedTags, so reviewers can confirm that only the
+// intended metadata survived. Pointer tags linking IFDs are not listed.
+func WithRetainedTagsReport() Option {
+	return func(o *options) {
+		o.reportRetained = true
+	}
+}
+
+// WithSkipRiskyMakerNote leaves files with an offset-sensitive MakerNote untouched.
+// Such MakerNotes (Canon, Sony and most others) use absolute offsets into the
+// EXIF data; by default their layout is preserved, which may leave zero-filled
+// bytes instead of removing them. With this option the file is reported as
+// Skipped instead. ExifRemoveThumbnailBytes and ExifRemoveThumbnail then return
+// the input unchanged; NewReader passes everything from the EXIF segment on through
+// unchanged, while segments before it have already been processed.
+func WithSkipRiskyMakerNote() Option {
+	return func(o *options) {
+		o.skipRisky = true
+	}
+}
+
+// ParseMode selects how spec violations in the input are handled.
+type ParseMode int
+
+const (
+	// ParseDefault rejects structural damage and reports recoverable anomalies,
+	// such as trailing data after EOI, as warnings.
+	ParseDefault ParseMode = iota
+	// ParseStrict rejects any spec violation, including those otherwise reported as warnings.
+	ParseStrict
+	// ParseLenient recovers from common real-world damage, such as invalid segment
+	// lengths, garbage between markers, truncated segments and unparsable EXIF data,
+	// and still produces output. Every recovery is recorded in Warnings.
+	ParseLenient
+)
+
+// String returns "default", "strict" or "lenient".
+func (m ParseMode) String() string {
+	switch m {
+	case ParseStrict:
+		return "strict"
+	case ParseLenient:
+		return "lenient"
+	}
+	return "default"
+}
+
+// WithParseMode sets how spec violations in the input are handled.
+// Camera firmware bugs are common in large archives; ParseLenient processes
+// such files instead of failing, while ParseStrict accepts only clean files.
+func WithParseMode(m ParseMode) Option {
+	return func(o *options) {
+		o.parseMode = m
+	}
+}
+
+// WithMaxInputSize fails with ErrInputTooLarge when the input is larger than n bytes.
+// The limit covers everything read, including image data after SOS and data
+// concatenated after EOI, so a server can bound the memory spent on one request
+// even with the bytes API. Zero means no limit.
+func WithMaxInputSize(n int64) Option {
+	return func(o *options) {
+		o.maxInputSize = n
+	}
+}
+
+// WithIntegrityCheck verifies the output of ExifRemoveThumbnailBytes and
+// ExifRemoveThumbnail before it is returned or written: every segment the
+// options do not change must be copied byte for byte, only EXIF and the
+// segments selected for removal or rewriting may differ, and the image data
+// from SOS on must be identical. A mismatch fails with *IntegrityError. It has
+// no effect on NewReader, which streams the output before it is complete.
+func WithIntegrityCheck() Option {
+	return func(o *options) {
+		o.checkIntegrity = true
+	}
+}
+
+// WithConformance adjusts the output of ExifRemoveThumbnailBytes and
+// ExifRemoveThumbnail for strict decoders and legacy clients: the JFIF APP0
+// segment is placed directly after SOI and the EXIF APP1 segment directly after
+// it, where Windows Imaging Component expects them, and a missing EOI marker is
+// appended. Every change is reported in Warnings. The integrity check, when
+// enabled, runs on the output before these adjustments. It has no effect on NewReader.
+func WithConformance() Option {
+	return func(o *options) {
+		o.conformance = true
+	}
+}
+
+// WithModifiedAfter makes TopOffenders, SampleSavings and RemoveAll consider
+// only files modified after t, so that a nightly sweep touches only new arrivals.
+func WithModifiedAfter(t time.Time) Option {
+	return func(o *options) {
+		o.modifiedAfter = t
+	}
+}
+
+// WithModifiedBefore makes TopOffenders, SampleSavings and RemoveAll consider
+// only files modified before t.
+func WithModifiedBefore(t time.Time) Option {
+	return func(o *options) {
+		o.modifiedBefore = t
+	}
+}
+
+// WithNewerThan is WithModifiedAfter relative to the start of the walk:
+// only files modified within the last d are considered.
+func WithNewerThan(d time.Duration) Option {
+	return func(o *options) {
+		o.newerThan = d
+	}
+}
+
+// WithOlderThan is WithModifiedBefore relative to the start of the walk:
+// only files not modified within the last d are considered, for example
+// to leave files alone that may still be being written.
+func WithOlderThan(d time.Duration) Option {
+	return func(o *options) {
+		o.olderThan = d
+	}
+}
+
+// WithMinFileSize makes TopOffenders, SampleSavings and RemoveAll skip files
+// smaller than n bytes, which rarely hold enough metadata to be worth a pass.
+func WithMinFileSize(n int64) Option {
+	return func(o *options) {
+		o.minFileSize = n
+	}
+}
+
+// WithMaxFileSize makes TopOffenders, SampleSavings and RemoveAll skip files
+// larger than n bytes, such as videos in a mixed media tree. n <= 0 sets no
+// limit.
+func WithMaxFileSize(n int64) Option {
+	return func(o *options) {
+		o.maxFileSize = n
+	}
+}
+
+// WithExtensions makes TopOffenders, SampleSavings and RemoveAll consider only
+// files with one of the given extensions, compared without regard to case and
+// with or without the leading dot: WithExtensions("jpg", ".jpeg"). Repeated
+// uses add extensions.
+func WithExtensions(exts ...string) Option {
+	return func(o *options) {
+		for _, ext := range exts {
+			o.extensions = append(o.extensions, "."+strings.ToLower(strings.TrimPrefix(ext, ".")))
+		}
+	}
+}
+
+// WithIncludePath makes TopOffenders, SampleSavings and RemoveAll consider
+// only files whose slash-separated path matches re. Repeated uses accept a path matching any
+// of the expressions.
+func WithIncludePath(re *regexp.Regexp) Option {
+	return func(o *options) {
+		o.includePaths = append(o.includePaths, re)
+	}
+}
+
+// WithExcludePath makes TopOffenders, SampleSavings and RemoveAll skip files
+// whose slash-separated path matches re, such as `(^|/)\.` for hidden files.
+// Exclusions win over WithIncludePath and WithExtensions.
+func WithExcludePath(re *regexp.Regexp) Option {
+	return func(o *options) {
+		o.excludePaths = append(o.excludePaths, re)
+	}
+}
+
+// WithLargestFirst makes TopOffenders, SampleSavings and RemoveAll visit files
+// in order of decreasing size, so that a run stopped early has covered the
+// files likely to save the most.
+// The paths of all matching files are collected before the first one is processed.
+func WithLargestFirst() Option {
+	return func(o *options) {
+		o.largestFirst = true
+	}
+}
+
+// WithAllowTruncated processes input that ends early as far as it goes instead
+// of failing, in every parse mode: a segment cut off before the image data is
+// dropped, and image data without EOI is copied as it is. The thumbnail is
+// removed as usual and Truncated is set in the result, with a warning.
+// Damaged but viewable JPEG files are common in old archives.
+func WithAllowTruncated() Option {
+	return func(o *options) {
+		o.allowTruncated = true
+	}
+}
+
+// WithVerifyImageDataUnchanged proves that the image data was not modified:
+// ExifRemoveThumbnailBytes and ExifRemoveThumbnail hash the SOS..EOI region of
+// the returned output and fail with ErrImageDataChanged unless it matches
+// InputScanHash. The package always copies these bytes verbatim; the only
+// deliberate exception is the EOI marker WithConformance appends to input without one.
+func WithVerifyImageDataUnchanged() Option {
+	return func(o *options) {
+		o.verifyImageData = true
+	}
+}
+
+// WithValidateOutput makes ExifRemoveThumbnailBytes and ExifRemoveThumbnail
+// read the output back before returning it: the JPEG header is decoded and
+// every EXIF block is parsed. Output that cannot be read fails with
+// ErrInvalidOutput, so ExifRemoveThumbnail never writes a broken file. This is
+// cheaper than WithVerifyPixels, which decodes the whole image. Note that an
+// unreadable EXIF block copied from the input in ParseLenient mode fails too.
+func WithValidateOutput() Option {
+	return func(o *options) {
+		o.validateOutput = true
+	}
+}
+
+// WithDedupeExif keeps only the first EXIF APP1 segment. Some broken encoders
+// write several; by default each of them is processed. The dropped segments are
+// reported in RemovedSegments.
+func WithDedupeExif() Option {
+	return func(o *options) {
+		o.dedupeExif = true
+	}
+}
@@ -0,0 +1,109 @@
+package exifremovethumbnail
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Overlay writes processed files to a separate directory tree mirroring Root,
+// so that the originals are never touched until Promote moves the results
+// into place. It is the safest workflow for irreplaceable archives: the
+// overlay can be inspected, verified or simply deleted.
+type Overlay struct {
+	// Root is the directory holding the originals.
+	Root string
+	// Dir is the overlay directory receiving the processed files.
+	Dir string
+}
+
+// Process removes the thumbnail from the file at path, a slash-separated path
+// relative to Root, and writes the result to the same path under Dir.
+func (ov Overlay) Process(path string, opts ...Option) (ExifRemoveThumbnailResult, error) {
+	out := filepath.Join(ov.Dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return ExifRemoveThumbnailResult{}, fmt.Errorf("failed to create overlay directory: %w", err)
+	}
+	return ExifRemoveThumbnail(filepath.Join(ov.Root, filepath.FromSlash(path)), out, opts...)
+}
+
+// Preflight audits every file under Root as TopOffenders does with opts and
+// fails with ErrInsufficientSpace unless the file system holding Dir can take
+// the processed files, so that a sweep fails fast instead of running out of
+// space midway. Call it before the first Process with the same options.
+func (ov Overlay) Preflight(opts ...Option) error {
+	offenders, err := TopOffenders(os.DirFS(ov.Root), 0, opts...)
+	if err != nil {
+		return err
+	}
+	return CheckFreeSpace(ov.Dir, RequiredSpace(offenders))
+}
+
+// Promote replaces the originals with the files in the overlay and returns
+// their slash-separated paths relative to Root. When verify is not nil it is
+// called with the original and the processed data of every file, VerifyPixels
+// for example, and all files are verified before the first original is
+// replaced, so a failed verification leaves every original untouched. Each
+// original is replaced atomically by a rename, and promoted files are removed
+// from the overlay. Files in the overlay without an original are an error.
+// Promote must not run concurrently with Process on the same overlay.
+func (ov Overlay) Promote(verify func(original, processed []byte) error) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(ov.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(ov.Dir, p)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		original := filepath.Join(ov.Root, filepath.FromSlash(path))
+		if verify == nil {
+			if _, err := os.Stat(original); err != nil {
+				return nil, fmt.Errorf("%s: no original: %w", path, err)
+			}
+			continue
+		}
+		originalData, err := os.ReadFile(original)
+		if err != nil {
+			return nil, fmt.Errorf("%s: no original: %w", path, err)
+		}
+		processedData, err := os.ReadFile(filepath.Join(ov.Dir, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, err
+		}
+		if err := verify(originalData, processedData); err != nil {
+			return nil, fmt.Errorf("%s: verification failed: %w", path, err)
+		}
+	}
+
+	for i, path := range paths {
+		processed := filepath.Join(ov.Dir, filepath.FromSlash(path))
+		original := filepath.Join(ov.Root, filepath.FromSlash(path))
+		if err := os.Rename(processed, original); err != nil {
+			// The overlay may be on another file system; copy through a temporary file instead.
+			data, err := os.ReadFile(processed)
+			if err == nil {
+				err = writeFile(original, data, &options{})
+			}
+			if err == nil {
+				err = os.Remove(processed)
+			}
+			if err != nil {
+				return paths[:i], fmt.Errorf("%s: failed to promote: %w", path, err)
+			}
+		}
+	}
+	return paths, nil
+}
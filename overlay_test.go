@@ -0,0 +1,90 @@
+package exifremovethumbnail_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestOverlay(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+
+	// setup は原本のツリーと空のオーバーレイを用意する
+	setup := func(t *testing.T) exifremovethumbnail.Overlay {
+		ov := exifremovethumbnail.Overlay{Root: t.TempDir(), Dir: filepath.Join(t.TempDir(), "overlay")}
+		require.NoError(t, os.MkdirAll(filepath.Join(ov.Root, "2020", "01"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(ov.Root, "a.jpg"), inData, 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(ov.Root, "2020", "01", "b.jpg"), inData, 0644))
+		return ov
+	}
+
+	t.Run("原本には触れずにオーバーレイへ書き込む", func(t *testing.T) {
+		ov := setup(t)
+		res, err := ov.Process("2020/01/b.jpg")
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		outData, err := os.ReadFile(filepath.Join(ov.Dir, "2020", "01", "b.jpg"))
+		require.NoError(t, err)
+		require.Equal(t, want, outData)
+		original, err := os.ReadFile(filepath.Join(ov.Root, "2020", "01", "b.jpg"))
+		require.NoError(t, err)
+		require.Equal(t, inData, original)
+	})
+
+	t.Run("検証後に原本と置き換える", func(t *testing.T) {
+		ov := setup(t)
+		for _, path := range []string{"a.jpg", "2020/01/b.jpg"} {
+			_, err := ov.Process(path)
+			require.NoError(t, err)
+		}
+		promoted, err := ov.Promote(exifremovethumbnail.VerifyPixels)
+		require.NoError(t, err)
+		require.Equal(t, []string{"2020/01/b.jpg", "a.jpg"}, promoted)
+		for _, path := range promoted {
+			data, err := os.ReadFile(filepath.Join(ov.Root, filepath.FromSlash(path)))
+			require.NoError(t, err)
+			require.Equal(t, want, data)
+			_, err = os.Stat(filepath.Join(ov.Dir, filepath.FromSlash(path)))
+			require.True(t, os.IsNotExist(err), "オーバーレイから取り除かれるべき")
+		}
+	})
+
+	t.Run("一つでも検証に失敗すれば何も置き換えない", func(t *testing.T) {
+		ov := setup(t)
+		for _, path := range []string{"a.jpg", "2020/01/b.jpg"} {
+			_, err := ov.Process(path)
+			require.NoError(t, err)
+		}
+		errBroken := errors.New("broken")
+		_, err := ov.Promote(func(original, processed []byte) error {
+			if len(processed) > 0 {
+				return errBroken
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, errBroken)
+		for _, path := range []string{"a.jpg", "2020/01/b.jpg"} {
+			data, err := os.ReadFile(filepath.Join(ov.Root, filepath.FromSlash(path)))
+			require.NoError(t, err)
+			require.Equal(t, inData, data)
+		}
+	})
+
+	t.Run("原本のないファイルはエラー", func(t *testing.T) {
+		ov := setup(t)
+		require.NoError(t, os.MkdirAll(ov.Dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(ov.Dir, "stray.jpg"), want, 0644))
+		_, err := ov.Promote(nil)
+		require.Error(t, err)
+		_, err = os.Stat(filepath.Join(ov.Root, "stray.jpg"))
+		require.True(t, os.IsNotExist(err))
+	})
+}
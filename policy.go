@@ -0,0 +1,127 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Policy is a declarative description of the metadata to remove or keep.
+// It is evaluated in a single rewrite pass, so compliance rules can be
+// expressed as one value instead of composing multiple passes.
+//
+// The zero Policy changes nothing: even the thumbnail is kept unless
+// RemoveThumbnail is set.
+type Policy struct {
+	RemoveThumbnail bool
+	RemoveGPS       bool
+	RemoveMakerNote bool
+	RemoveOwnerInfo bool
+	RemoveComments  bool
+	RemoveICC       bool
+	RemoveIPTC      bool
+	RemoveTrailer   bool
+	StripAllExif    bool
+	KeepOrientation bool
+	KeepICC         bool
+	CompactExif     bool
+	RemoveTags      []TagRef
+}
+
+// DefaultPolicy is the policy matching the behavior without any options.
+var DefaultPolicy = Policy{RemoveThumbnail: true}
+
+// WithPolicy applies all rules of p. Every rule is set, including those p
+// leaves off, so WithPolicy replaces what earlier options chose for the same
+// rules; only the removed tags add up. Give WithPolicy first: options given
+// after it can still add to the rules.
+func WithPolicy(p Policy) Option {
+	return func(o *options) {
+		o.keepThumbnail = !p.RemoveThumbnail
+		o.removeGPS = p.RemoveGPS
+		o.stripComments = p.RemoveComments
+		o.stripICC = p.RemoveICC
+		o.stripIPTC = p.RemoveIPTC
+		o.stripTrailer = p.RemoveTrailer
+		o.stripAllExif = p.StripAllExif
+		o.keepOrientation = p.KeepOrientation
+		o.keepICC = p.KeepICC
+		o.compactExif = p.CompactExif
+		o.removeTags = append(o.removeTags, p.RemoveTags...)
+		if p.RemoveMakerNote {
+			WithRemoveMakerNote()(o)
+		}
+		if p.RemoveOwnerInfo {
+			WithRemoveOwnerInfo()(o)
+		}
+	}
+}
+
+// Validate reports rules that contradict each other or have no effect.
+func (p Policy) Validate() error {
+	var errs []error
+	if p.KeepOrientation && !p.StripAllExif {
+		errs = append(errs, fmt.Errorf("keep_orientation has no effect without strip_all_exif"))
+	}
+	if p.KeepICC && !p.StripAllExif {
+		errs = append(errs, fmt.Errorf("keep_icc has no effect without strip_all_exif"))
+	}
+	if p.KeepICC && p.RemoveICC {
+		errs = append(errs, fmt.Errorf("keep_icc and remove_icc contradict each other"))
+	}
+	seen := map[TagRef]bool{}
+	for _, t := range p.RemoveTags {
+		if t.IFD < IFD0 || t.IFD > IFD1 {
+			errs = append(errs, fmt.Errorf("remove_tags: unknown IFD %d", t.IFD))
+		}
+		if seen[t] {
+			errs = append(errs, fmt.Errorf("remove_tags: duplicate tag %s 0x%04X", t.IFD, t.ID))
+		}
+		seen[t] = true
+	}
+	return errors.Join(errs...)
+}
+
+// Explanation describes what a policy would do to a sample file.
+// Actions are human-readable lines; Result is what processing would report.
+type Explanation struct {
+	Actions []string
+	Result  ExifRemoveThumbnailResult
+}
+
+// Explain runs p against data without producing output and describes the changes,
+// so that policy changes can be reviewed before rollout.
+func Explain(p Policy, data []byte) (Explanation, error) {
+	result, err := removeThumbnail(io.Discard, bytes.NewReader(data), newOptions([]Option{WithPolicy(p)}))
+	if err != nil {
+		return Explanation{}, err
+	}
+	var ex Explanation
+	ex.Result = result
+	if result.HadThumbnail {
+		if result.ThumbnailSize > 0 {
+			ex.Actions = append(ex.Actions, fmt.Sprintf("remove %s thumbnail %dx%d (%d bytes)",
+				result.ThumbnailCompression, result.ThumbnailWidth, result.ThumbnailHeight, result.ThumbnailSize))
+		} else {
+			ex.Actions = append(ex.Actions, "keep thumbnail")
+		}
+	}
+	for _, t := range result.RemovedTags {
+		ex.Actions = append(ex.Actions, fmt.Sprintf("remove tag %s 0x%04X", t.IFD, t.ID))
+	}
+	names := make([]string, 0, len(result.RemovedSegments))
+	for name := range result.RemovedSegments {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ex.Actions = append(ex.Actions, fmt.Sprintf("remove segment %s (%d bytes)", name, result.RemovedSegments[Segment(name)]))
+	}
+	if result.HadIPTC && p.RemoveIPTC {
+		ex.Actions = append(ex.Actions, fmt.Sprintf("remove IPTC-NAA data (%d bytes)", result.IPTCSize))
+	}
+	ex.Actions = append(ex.Actions, fmt.Sprintf("size %d -> %d bytes", result.BeforeSize, result.AfterSize))
+	return ex, nil
+}
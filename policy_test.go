@@ -0,0 +1,130 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestPolicy(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+
+	t.Run("サムネイルとGPSを削除", func(t *testing.T) {
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithPolicy(exifremovethumbnail.Policy{
+			RemoveThumbnail: true,
+			RemoveGPS:       true,
+		}))
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Greater(t, res.ThumbnailSize, int64(0))
+		require.Equal(t, []exifremovethumbnail.TagRef{{IFD: exifremovethumbnail.IFD0, ID: 0x8825}}, res.RemovedTags)
+
+		outExif, err := exif.Decode(bytes.NewReader(outData))
+		require.NoError(t, err)
+		_, err = outExif.Get(exif.GPSInfoIFDPointer)
+		require.Error(t, err, "GPSは削除されるべき")
+		_, err = outExif.Get(exif.GPSLatitude)
+		require.Error(t, err, "GPSの値も残らないべき")
+		_, err = outExif.JpegThumbnail()
+		require.Error(t, err, "サムネイルは削除されるべき")
+		_, err = outExif.Get(exif.DateTimeOriginal)
+		require.NoError(t, err, "他のEXIFは保持されるべき")
+	})
+
+	t.Run("ゼロ値のポリシーは何も変更しない", func(t *testing.T) {
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithPolicy(exifremovethumbnail.Policy{}))
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Zero(t, res.ThumbnailSize)
+		require.Equal(t, inData, outData)
+	})
+
+	t.Run("EOI後のデータを削除", func(t *testing.T) {
+		withTrailer := append(append([]byte{}, inData...), []byte("TRAILER")...)
+		outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(withTrailer, exifremovethumbnail.WithPolicy(exifremovethumbnail.Policy{
+			RemoveTrailer: true,
+		}))
+		require.NoError(t, err)
+		require.Equal(t, inData, outData)
+		require.Equal(t, map[exifremovethumbnail.Segment]int64{exifremovethumbnail.SegmentTrailer: 7}, res.RemovedSegments)
+	})
+
+	t.Run("前のオプションは上書きし後のオプションは追加する", func(t *testing.T) {
+		_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithRemoveGPS(),
+			exifremovethumbnail.WithPolicy(exifremovethumbnail.Policy{RemoveThumbnail: true}))
+		require.NoError(t, err)
+		require.Empty(t, res.RemovedTags, "ポリシーより前のWithRemoveGPSは効かない")
+
+		_, res, err = exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithPolicy(exifremovethumbnail.Policy{RemoveThumbnail: true}),
+			exifremovethumbnail.WithRemoveGPS())
+		require.NoError(t, err)
+		require.Equal(t, []exifremovethumbnail.TagRef{{IFD: exifremovethumbnail.IFD0, ID: 0x8825}}, res.RemovedTags)
+	})
+
+	t.Run("DefaultPolicyはオプションなしと同じ", func(t *testing.T) {
+		withPolicy, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithPolicy(exifremovethumbnail.DefaultPolicy))
+		require.NoError(t, err)
+		plain, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, plain, withPolicy)
+	})
+
+	t.Run("EXIFを全削除しOrientationとICCを保持", func(t *testing.T) {
+		outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithPolicy(exifremovethumbnail.Policy{
+			StripAllExif:    true,
+			KeepOrientation: true,
+			KeepICC:         true,
+		}))
+		require.NoError(t, err)
+		outExif, err := exif.Decode(bytes.NewReader(outData))
+		require.NoError(t, err)
+		_, err = outExif.Get(exif.Orientation)
+		require.NoError(t, err)
+		_, err = outExif.Get(exif.Software)
+		require.Error(t, err)
+	})
+}
+
+func TestPolicyValidate(t *testing.T) {
+	require.NoError(t, exifremovethumbnail.DefaultPolicy.Validate())
+	require.NoError(t, exifremovethumbnail.Policy{StripAllExif: true, KeepOrientation: true, KeepICC: true}.Validate())
+
+	err := exifremovethumbnail.Policy{KeepOrientation: true}.Validate()
+	require.ErrorContains(t, err, "keep_orientation")
+
+	err = exifremovethumbnail.Policy{StripAllExif: true, KeepICC: true, RemoveICC: true}.Validate()
+	require.ErrorContains(t, err, "contradict")
+
+	tag := exifremovethumbnail.TagRef{IFD: exifremovethumbnail.IFDExif, ID: 0x9286}
+	err = exifremovethumbnail.Policy{RemoveTags: []exifremovethumbnail.TagRef{tag, tag}}.Validate()
+	require.ErrorContains(t, err, "duplicate")
+}
+
+func TestExplain(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	ex, err := exifremovethumbnail.Explain(exifremovethumbnail.Policy{RemoveThumbnail: true, RemoveGPS: true}, inData)
+	require.NoError(t, err)
+	require.True(t, ex.Result.HadThumbnail)
+	require.Len(t, ex.Actions, 3)
+	require.Contains(t, ex.Actions[0], "remove jpeg thumbnail")
+	require.Equal(t, "remove tag IFD0 0x8825", ex.Actions[1])
+	require.Contains(t, ex.Actions[2], "size 142050 -> ")
+
+	// 入力は変更されないこと
+	original, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	require.Equal(t, original, inData)
+
+	_, err = exifremovethumbnail.Explain(exifremovethumbnail.DefaultPolicy, []byte("not a jpeg"))
+	require.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// policyDocument is the serialized form of Policy.
+// Field names are snake_case so that policy files read naturally in JSON and YAML.
+type policyDocument struct {
+	RemoveThumbnail *bool         `json:"remove_thumbnail"`
+	RemoveGPS       bool          `json:"remove_gps"`
+	RemoveMakerNote bool          `json:"remove_maker_note"`
+	RemoveOwnerInfo bool          `json:"remove_owner_info"`
+	RemoveComments  bool          `json:"remove_comments"`
+	RemoveICC       bool          `json:"remove_icc"`
+	RemoveIPTC      bool          `json:"remove_iptc"`
+	RemoveTrailer   bool          `json:"remove_trailer"`
+	StripAllExif    bool          `json:"strip_all_exif"`
+	KeepOrientation bool          `json:"keep_orientation"`
+	KeepICC         bool          `json:"keep_icc"`
+	CompactExif     bool          `json:"compact_exif"`
+	RemoveTags      []tagDocument `json:"remove_tags"`
+}
+
+// tagDocument is the serialized form of TagRef, e.g. {"ifd": "Exif", "id": 37510}.
+type tagDocument struct {
+	IFD string `json:"ifd"`
+	ID  uint16 `json:"id"`
+}
+
+// ParsePolicy reads a JSON policy document.
+// remove_thumbnail defaults to true so that an empty document matches DefaultPolicy.
+// Unknown fields are rejected so that typos do not silently weaken a policy.
+func ParsePolicy(data []byte) (Policy, error) {
+	var doc policyDocument
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return Policy{}, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return Policy{}, fmt.Errorf("failed to parse policy: unexpected data after the document")
+	}
+	p := Policy{
+		RemoveThumbnail: doc.RemoveThumbnail == nil || *doc.RemoveThumbnail,
+		RemoveGPS:       doc.RemoveGPS,
+		RemoveMakerNote: doc.RemoveMakerNote,
+		RemoveOwnerInfo: doc.RemoveOwnerInfo,
+		RemoveComments:  doc.RemoveComments,
+		RemoveICC:       doc.RemoveICC,
+		RemoveIPTC:      doc.RemoveIPTC,
+		RemoveTrailer:   doc.RemoveTrailer,
+		StripAllExif:    doc.StripAllExif,
+		KeepOrientation: doc.KeepOrientation,
+		KeepICC:         doc.KeepICC,
+		CompactExif:     doc.CompactExif,
+	}
+	for _, t := range doc.RemoveTags {
+		ifd, err := parseIFDName(t.IFD)
+		if err != nil {
+			return Policy{}, fmt.Errorf("failed to parse policy: %w", err)
+		}
+		p.RemoveTags = append(p.RemoveTags, TagRef{IFD: ifd, ID: t.ID})
+	}
+	return p, nil
+}
+
+// ParsePolicyYAML reads a YAML policy document. It has the same fields as
+// the JSON form accepted by ParsePolicy and is validated the same way.
+func ParsePolicyYAML(data []byte) (Policy, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Policy{}, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	// Converting to JSON shares the schema and the validation of ParsePolicy.
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return Policy{}, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	return ParsePolicy(jsonData)
+}
+
+// LoadPolicyFile reads a policy document from path: YAML when the extension
+// is .yaml or .yml, JSON otherwise.
+func LoadPolicyFile(path string) (Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParsePolicyYAML(data)
+	}
+	return ParsePolicy(data)
+}
+
+// parseIFDName converts a name returned by IFD.String back to an IFD.
+func parseIFDName(name string) (IFD, error) {
+	for _, ifd := range []IFD{IFD0, IFDExif, IFDGPS, IFDInterop, IFD1} {
+		if ifd.String() == name {
+			return ifd, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown IFD %q", name)
+}
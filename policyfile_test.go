@@ -0,0 +1,98 @@
+package exifremovethumbnail_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestParsePolicy(t *testing.T) {
+	t.Run("全項目", func(t *testing.T) {
+		p, err := exifremovethumbnail.ParsePolicy([]byte(`{
+			"remove_thumbnail": true,
+			"remove_gps": true,
+			"remove_maker_note": true,
+			"remove_comments": true,
+			"remove_trailer": true,
+			"keep_icc": true,
+			"remove_tags": [{"ifd": "Exif", "id": 37510}]
+		}`))
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.Policy{
+			RemoveThumbnail: true,
+			RemoveGPS:       true,
+			RemoveMakerNote: true,
+			RemoveComments:  true,
+			RemoveTrailer:   true,
+			KeepICC:         true,
+			RemoveTags:      []exifremovethumbnail.TagRef{{IFD: exifremovethumbnail.IFDExif, ID: 0x9286}},
+		}, p)
+	})
+
+	t.Run("空のドキュメントはDefaultPolicy", func(t *testing.T) {
+		p, err := exifremovethumbnail.ParsePolicy([]byte(`{}`))
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.DefaultPolicy, p)
+	})
+
+	t.Run("サムネイルを残す", func(t *testing.T) {
+		p, err := exifremovethumbnail.ParsePolicy([]byte(`{"remove_thumbnail": false, "remove_gps": true}`))
+		require.NoError(t, err)
+		require.False(t, p.RemoveThumbnail)
+		require.True(t, p.RemoveGPS)
+	})
+
+	t.Run("不明なフィールドはエラー", func(t *testing.T) {
+		_, err := exifremovethumbnail.ParsePolicy([]byte(`{"remove_gsp": true}`))
+		require.Error(t, err)
+	})
+
+	t.Run("不明なIFDはエラー", func(t *testing.T) {
+		_, err := exifremovethumbnail.ParsePolicy([]byte(`{"remove_tags": [{"ifd": "MakerNote", "id": 1}]}`))
+		require.Error(t, err)
+	})
+
+	t.Run("ファイルから読み込み", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"remove_gps": true}`), 0644))
+		p, err := exifremovethumbnail.LoadPolicyFile(path)
+		require.NoError(t, err)
+		require.True(t, p.RemoveGPS)
+		require.True(t, p.RemoveThumbnail)
+	})
+
+	t.Run("YAMLを読み込む", func(t *testing.T) {
+		p, err := exifremovethumbnail.ParsePolicyYAML([]byte("remove_thumbnail: false\nremove_gps: true\nremove_trailer: true\nremove_tags:\n  - ifd: Exif\n    id: 37510\n"))
+		require.NoError(t, err)
+		require.False(t, p.RemoveThumbnail)
+		require.True(t, p.RemoveGPS)
+		require.True(t, p.RemoveTrailer)
+		require.Equal(t, []exifremovethumbnail.TagRef{{IFD: exifremovethumbnail.IFDExif, ID: 37510}}, p.RemoveTags)
+
+		// 空の文書は既定のポリシー
+		p, err = exifremovethumbnail.ParsePolicyYAML(nil)
+		require.NoError(t, err)
+		require.Equal(t, exifremovethumbnail.DefaultPolicy, p)
+
+		// JSONと同じく未知のフィールドは拒否する
+		_, err = exifremovethumbnail.ParsePolicyYAML([]byte("remove_gsp: true\n"))
+		require.Error(t, err)
+		_, err = exifremovethumbnail.ParsePolicyYAML([]byte("remove_gps: [\n"))
+		require.Error(t, err)
+	})
+
+	t.Run("拡張子でYAMLファイルを判別する", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"policy.yaml", "policy.YML"} {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, []byte("remove_gps: true\n"), 0644))
+			p, err := exifremovethumbnail.LoadPolicyFile(path)
+			require.NoError(t, err, name)
+			require.True(t, p.RemoveGPS, name)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package exifremovethumbnail
+
+import "sync"
+
+// windowPool and segmentPool recycle the scratch buffers of removeThumbnail,
+// so that a service processing many images per second does not allocate the
+// copy window and segment buffers anew for every call.
+var (
+	windowPool  = sync.Pool{New: func() any { b := make([]byte, defaultWindowSize); return &b }}
+	segmentPool = sync.Pool{New: func() any { b := make([]byte, maxSegmentPayload); return &b }}
+)
+
+// getWindow returns a copy window of n bytes and the function giving it back.
+// Only windows of the default size are pooled.
+func getWindow(n int) ([]byte, func()) {
+	if n != defaultWindowSize {
+		return make([]byte, n), func() {}
+	}
+	b := windowPool.Get().(*[]byte)
+	return *b, func() { windowPool.Put(b) }
+}
@@ -0,0 +1,74 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestBufferPool(t *testing.T) {
+	var inputs [][]byte
+	for _, name := range []string{"thumbnail_embedded.jpg", "thumbnail_none.jpg", "metadata_none.jpg"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		require.NoError(t, err)
+		inputs = append(inputs, data)
+	}
+
+	t.Run("並行して処理しても結果が混ざらない", func(t *testing.T) {
+		var want [][]byte
+		for _, in := range inputs {
+			out, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(in)
+			require.NoError(t, err)
+			want = append(want, out)
+		}
+		var wg sync.WaitGroup
+		errs := make(chan error, 64)
+		for i := 0; i < 64; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var out bytes.Buffer
+				in := inputs[i%len(inputs)]
+				if _, err := exifremovethumbnail.ExifRemoveThumbnailReaderAt(&out, bytes.NewReader(in), int64(len(in))); err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(want[i%len(inputs)], out.Bytes()) {
+					errs <- fmt.Errorf("output of input %d differs", i%len(inputs))
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("作業バッファを呼び出しごとに確保しない", func(t *testing.T) {
+		if raceEnabled {
+			t.Skip("競合検出器の下ではsync.Poolが要素を捨てる")
+		}
+		in := inputs[0]
+		exifremovethumbnail.ExifRemoveThumbnailReaderAt(io.Discard, bytes.NewReader(in), int64(len(in)))
+		const runs = 50
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		for i := 0; i < runs; i++ {
+			_, err := exifremovethumbnail.ExifRemoveThumbnailReaderAt(io.Discard, bytes.NewReader(in), int64(len(in)))
+			require.NoError(t, err)
+		}
+		runtime.ReadMemStats(&after)
+		// コピーウィンドウ(32KB)とセグメント用バッファ(64KB)は再利用される
+		require.Less(t, (after.TotalAlloc-before.TotalAlloc)/runs, uint64(48<<10))
+	})
+}
@@ -0,0 +1,43 @@
+package exifremovethumbnail
+
+import "io"
+
+// Processor applies one configuration to many images. The options are
+// resolved once by NewProcessor instead of on every call, and the scratch
+// buffers are pooled, so a service handling many requests per second spends
+// its time on the images rather than on setup. A Processor is safe for
+// concurrent use; options such as WithSkipHandler must be safe for concurrent
+// use themselves.
+type Processor struct {
+	o *options
+}
+
+// NewProcessor returns a Processor applying opts, for example a policy
+// selected with WithPolicy.
+func NewProcessor(opts ...Option) *Processor {
+	return &Processor{o: newOptions(opts)}
+}
+
+// Process streams the JPEG data read from src to dst with the EXIF thumbnail
+// removed, as NewReader does but without a background goroutine. Options
+// examining the whole output have no effect, as with NewReader;
+// WithVerifyImageDataUnchanged fails with ErrImageDataChanged after dst has
+// received the output.
+func (p *Processor) Process(dst io.Writer, src io.Reader) (ExifRemoveThumbnailResult, error) {
+	return removeThumbnailChecked(dst, src, p.o)
+}
+
+// ProcessBytes is ExifRemoveThumbnailBytes with the options of p.
+func (p *Processor) ProcessBytes(src []byte) ([]byte, ExifRemoveThumbnailResult, error) {
+	return removeThumbnailBytes(src, p.o)
+}
+
+// ProcessFile is ExifRemoveThumbnail with the options of p.
+func (p *Processor) ProcessFile(inputPath, outputPath string) (ExifRemoveThumbnailResult, error) {
+	return removeThumbnailFile(inputPath, outputPath, p.o)
+}
+
+// Behavior describes the behavior selected by the options of p.
+func (p *Processor) Behavior() Behavior {
+	return behaviorOf(p.o)
+}
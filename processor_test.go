@@ -0,0 +1,74 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestProcessor(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	opts := []exifremovethumbnail.Option{exifremovethumbnail.WithRemoveGPS(), exifremovethumbnail.WithVerifyImageDataUnchanged()}
+	want, wantRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, opts...)
+	require.NoError(t, err)
+	p := exifremovethumbnail.NewProcessor(opts...)
+
+	t.Run("関数と同じ結果", func(t *testing.T) {
+		out, res, err := p.ProcessBytes(inData)
+		require.NoError(t, err)
+		require.Equal(t, want, out)
+		require.Equal(t, wantRes.RemovedTags, res.RemovedTags)
+
+		var buf bytes.Buffer
+		res, err = p.Process(&buf, bytes.NewReader(inData))
+		require.NoError(t, err)
+		require.Equal(t, want, buf.Bytes())
+		require.True(t, res.HadThumbnail)
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.jpg")
+		_, err = p.ProcessFile(filepath.Join("testdata", "thumbnail_embedded.jpg"), path)
+		require.NoError(t, err)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+
+		require.False(t, p.Behavior().KeepGPS)
+	})
+
+	t.Run("並行して使える", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 32)
+		for i := 0; i < 32; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				out, _, err := p.ProcessBytes(inData)
+				if err == nil && !bytes.Equal(want, out) {
+					err = fmt.Errorf("output differs")
+				}
+				if err != nil {
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("フォーマットエラー", func(t *testing.T) {
+		_, err := p.Process(&bytes.Buffer{}, bytes.NewReader([]byte("not a jpeg")))
+		require.ErrorIs(t, err, exifremovethumbnail.ErrNotJPEG)
+	})
+}
@@ -0,0 +1,66 @@
+package exifremovethumbnail
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// NewReader returns a reader yielding the JPEG data read from r with the EXIF thumbnail removed.
+// The data is processed as it is read, so the reader can be inserted anywhere an io.Reader is
+// expected. Errors, including *FormatError, are returned from Read.
+// The caller must Close the reader to release the background worker when not reading to EOF.
+func NewReader(r io.Reader, opts ...Option) io.ReadCloser {
+	o := newOptions(opts)
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := removeThumbnail(pw, r, o)
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// EstimateSavings reports how many bytes ExifRemoveThumbnail with the same options
+// would reclaim from the JPEG data read from r. The rewritten data is discarded as
+// it is produced, so memory use is bounded as with NewReader.
+func EstimateSavings(r io.Reader, opts ...Option) (int64, error) {
+	result, err := removeThumbnail(io.Discard, r, newOptions(opts))
+	if err != nil {
+		return 0, err
+	}
+	return result.BeforeSize - result.AfterSize, nil
+}
+
+// readAtBufferSize is the read buffer ExifRemoveThumbnailReaderAt puts in front
+// of the input, so that marker headers do not cost a ReadAt each.
+const readAtBufferSize = 4096
+
+// ExifRemoveThumbnailReaderAt writes the size bytes of JPEG data read from r,
+// such as an *os.File, to w with the EXIF thumbnail removed. It is meant for
+// small containers and embedded devices: the headers are parsed one segment at
+// a time and everything after SOS is copied through the copy window, so peak
+// memory stays at a few hundred KB whatever the file size.
+//
+// As with NewReader, the output is written as it is produced: WithIntegrityCheck,
+// WithConformance, WithValidateOutput and WithVerifyPixels have no effect, and
+// with WithSkipRiskyMakerNote segments before the EXIF segment have already been
+// written when a file is skipped. WithVerifyImageDataUnchanged compares the
+// scan hashes of input and output and fails with ErrImageDataChanged, in which
+// case w has received the whole output.
+func ExifRemoveThumbnailReaderAt(w io.Writer, r io.ReaderAt, size int64, opts ...Option) (ExifRemoveThumbnailResult, error) {
+	return removeThumbnailAt(w, r, size, newOptions(opts))
+}
+
+func removeThumbnailAt(w io.Writer, r io.ReaderAt, size int64, o *options) (ExifRemoveThumbnailResult, error) {
+	return removeThumbnailChecked(w, bufio.NewReaderSize(io.NewSectionReader(r, 0, size), readAtBufferSize), o)
+}
+
+// removeThumbnailChecked is removeThumbnail followed by the check of
+// WithVerifyImageDataUnchanged, which compares the scan hashes.
+func removeThumbnailChecked(w io.Writer, r io.Reader, o *options) (ExifRemoveThumbnailResult, error) {
+	result, err := removeThumbnail(w, r, o)
+	if err == nil && o.verifyImageData && result.OutputScanHash != result.InputScanHash {
+		err = fmt.Errorf("%w: input %s, output %s", ErrImageDataChanged, result.InputScanHash, result.OutputScanHash)
+	}
+	return result, err
+}
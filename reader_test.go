@@ -0,0 +1,160 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestNewReader(t *testing.T) {
+	t.Run("バイト列APIと同じ結果", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		expected, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+
+		r := exifremovethumbnail.NewReader(bytes.NewReader(inData))
+		defer r.Close()
+		outData, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, expected, outData)
+	})
+
+	t.Run("io.Copyでファイルからファイルへ", func(t *testing.T) {
+		in, err := os.Open(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		defer in.Close()
+		r := exifremovethumbnail.NewReader(in)
+		defer r.Close()
+		var out bytes.Buffer
+		n, err := io.Copy(&out, r)
+		require.NoError(t, err)
+		require.Less(t, n, int64(142050), "サムネイル分小さくなるべき")
+	})
+
+	t.Run("フォーマットエラー", func(t *testing.T) {
+		in, err := os.Open(filepath.Join("testdata", "actual_png.jpg"))
+		require.NoError(t, err)
+		defer in.Close()
+		r := exifremovethumbnail.NewReader(in)
+		defer r.Close()
+		_, err = io.ReadAll(r)
+		require.Error(t, err)
+		_, ok := err.(*exifremovethumbnail.FormatError)
+		require.True(t, ok, "FormatErrorであるべき")
+	})
+
+	t.Run("途中でClose", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		r := exifremovethumbnail.NewReader(bytes.NewReader(inData))
+		buf := make([]byte, 16)
+		_, err = io.ReadFull(r, buf)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0xFF, 0xD8}, buf[:2])
+		require.NoError(t, r.Close())
+	})
+}
+
+func TestWindowSize(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	expected, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+
+	// ウィンドウサイズを変えても出力は変わらないこと
+	r := exifremovethumbnail.NewReader(io.MultiReader(bytes.NewReader(inData)), exifremovethumbnail.WithWindowSize(4096))
+	defer r.Close()
+	outData, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, expected, outData)
+
+	_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithWindowSize(4096))
+	require.NoError(t, err)
+	// 最大のセグメントはEXIF(8583バイト)
+	require.Equal(t, int64(8583), res.PeakBufferedBytes)
+
+	_, res, err = exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithWindowSize(64*1024))
+	require.NoError(t, err)
+	require.Equal(t, int64(64*1024), res.PeakBufferedBytes)
+}
+
+func TestEstimateSavings(t *testing.T) {
+	t.Run("実際の削減量と一致する", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithRemoveGPS())
+		require.NoError(t, err)
+
+		in, err := os.Open(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		defer in.Close()
+		saved, err := exifremovethumbnail.EstimateSavings(in, exifremovethumbnail.WithRemoveGPS())
+		require.NoError(t, err)
+		require.Equal(t, int64(len(inData)-len(outData)), saved)
+		require.Greater(t, saved, int64(0))
+	})
+
+	t.Run("サムネイルなしなら0", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_none.jpg"))
+		require.NoError(t, err)
+		saved, err := exifremovethumbnail.EstimateSavings(bytes.NewReader(inData))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), saved)
+	})
+
+	t.Run("フォーマットエラー", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "actual_png.jpg"))
+		require.NoError(t, err)
+		_, err = exifremovethumbnail.EstimateSavings(bytes.NewReader(inData))
+		_, ok := err.(*exifremovethumbnail.FormatError)
+		require.True(t, ok, "FormatErrorであるべき")
+	})
+}
+
+// countingReaderAt は読み込んだバイト数を数える
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestExifRemoveThumbnailReaderAt(t *testing.T) {
+	t.Run("バイト列APIと同じ結果", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		expected, expectedRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+
+		in, err := os.Open(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		defer in.Close()
+		r := &countingReaderAt{r: in}
+		var out bytes.Buffer
+		res, err := exifremovethumbnail.ExifRemoveThumbnailReaderAt(&out, r, int64(len(inData)), exifremovethumbnail.WithVerifyImageDataUnchanged())
+		require.NoError(t, err)
+		require.Equal(t, expected, out.Bytes())
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, expectedRes.ThumbnailSize, res.ThumbnailSize)
+		require.Equal(t, int64(len(inData)), r.n, "入力は一度だけ読む")
+		require.Less(t, res.PeakBufferedBytes, int64(256<<10))
+	})
+
+	t.Run("フォーマットエラー", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "actual_png.jpg"))
+		require.NoError(t, err)
+		_, err = exifremovethumbnail.ExifRemoveThumbnailReaderAt(io.Discard, bytes.NewReader(inData), int64(len(inData)))
+		require.ErrorIs(t, err, exifremovethumbnail.ErrNotJPEG)
+	})
+}
@@ -0,0 +1,151 @@
+package exifremovethumbnail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// resultDocument is the stable JSON form of ExifRemoveThumbnailResult.
+type resultDocument struct {
+	Format               string           `json:"format"`
+	HadThumbnail         bool             `json:"had_thumbnail"`
+	BeforeSize           int64            `json:"before_size"`
+	AfterSize            int64            `json:"after_size"`
+	ThumbnailSize        int64            `json:"thumbnail_size"`
+	ThumbnailWidth       int              `json:"thumbnail_width"`
+	ThumbnailHeight      int              `json:"thumbnail_height"`
+	ThumbnailCompression string           `json:"thumbnail_compression"`
+	ThumbnailOffset      int64            `json:"thumbnail_offset"`
+	RemovedTags          []tagDocument    `json:"removed_tags"`
+	RemovedSegments      map[string]int64 `json:"removed_segments"`
+	HadIPTC              bool             `json:"had_iptc"`
+	IPTCSize             int64            `json:"iptc_size"`
+	Exif                 summaryDocument  `json:"exif"`
+	Warnings             []string         `json:"warnings"`
+	PeakBufferedBytes    int64            `json:"peak_buffered_bytes"`
+	InputScanHash        string           `json:"input_scan_hash"`
+	OutputScanHash       string           `json:"output_scan_hash"`
+	RetainedTags         []tagDocument    `json:"retained_tags"`
+	RiskyMakerNote       bool             `json:"risky_maker_note"`
+	Skipped              bool             `json:"skipped"`
+	SkipReason           string           `json:"skip_reason"`
+	Truncated            bool             `json:"truncated"`
+	ExifSegments         int              `json:"exif_segments"`
+	Unchanged            bool             `json:"unchanged"`
+}
+
+// summaryDocument is the JSON form of ExifSummary.
+type summaryDocument struct {
+	Make             string `json:"make"`
+	Model            string `json:"model"`
+	Orientation      int    `json:"orientation"`
+	DateTimeOriginal string `json:"date_time_original"`
+}
+
+// MarshalJSON encodes the result with stable snake_case field names.
+// Every field is always present: sizes and offsets are integers in bytes,
+// thumbnail_compression is "jpeg", "uncompressed" or "unknown",
+// removed_tags entries use the policy file form {"ifd":"Exif","id":37510},
+// removed_segments maps segment names to byte counts, and empty lists are [] rather than null.
+func (r ExifRemoveThumbnailResult) MarshalJSON() ([]byte, error) {
+	doc := resultDocument{
+		Format:               r.Format,
+		HadThumbnail:         r.HadThumbnail,
+		BeforeSize:           r.BeforeSize,
+		AfterSize:            r.AfterSize,
+		ThumbnailSize:        r.ThumbnailSize,
+		ThumbnailWidth:       r.ThumbnailWidth,
+		ThumbnailHeight:      r.ThumbnailHeight,
+		ThumbnailCompression: r.ThumbnailCompression.String(),
+		ThumbnailOffset:      r.ThumbnailOffset,
+		RemovedTags:          make([]tagDocument, 0, len(r.RemovedTags)),
+		RetainedTags:         make([]tagDocument, 0, len(r.RetainedTags)),
+		RemovedSegments:      make(map[string]int64, len(r.RemovedSegments)),
+		HadIPTC:              r.HadIPTC,
+		IPTCSize:             r.IPTCSize,
+		Exif: summaryDocument{
+			Make:             r.Exif.Make,
+			Model:            r.Exif.Model,
+			Orientation:      r.Exif.Orientation,
+			DateTimeOriginal: r.Exif.DateTimeOriginal,
+		},
+		Warnings:          append([]string{}, r.Warnings...),
+		PeakBufferedBytes: r.PeakBufferedBytes,
+		InputScanHash:     r.InputScanHash,
+		OutputScanHash:    r.OutputScanHash,
+		RiskyMakerNote:    r.RiskyMakerNote,
+		Skipped:           r.Skipped,
+		SkipReason:        string(r.SkipReason),
+		Truncated:         r.Truncated,
+		ExifSegments:      r.ExifSegments,
+		Unchanged:         r.Unchanged,
+	}
+	for _, ref := range r.RemovedTags {
+		doc.RemovedTags = append(doc.RemovedTags, tagDocument{IFD: ref.IFD.String(), ID: ref.ID})
+	}
+	for _, ref := range r.RetainedTags {
+		doc.RetainedTags = append(doc.RetainedTags, tagDocument{IFD: ref.IFD.String(), ID: ref.ID})
+	}
+	for name, n := range r.RemovedSegments {
+		doc.RemovedSegments[string(name)] = n
+	}
+	return json.Marshal(doc)
+}
+
+// errorDocument is the JSON form of FormatError.
+type errorDocument struct {
+	Code    ErrorCode `json:"code"`
+	Offset  int64     `json:"offset"`
+	Message string    `json:"message"`
+}
+
+// MarshalJSON encodes the error as {"code", "offset", "message"} for structured telemetry.
+func (e *FormatError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(errorDocument{Code: e.Code, Offset: e.Offset, Message: e.msg})
+}
+
+// fileDocument is the JSON form of a FileResult, one line of the stream set
+// by WithResultStream.
+type fileDocument struct {
+	Path   string                    `json:"path"`
+	Result ExifRemoveThumbnailResult `json:"result"`
+	Error  *errorDocument            `json:"error"`
+}
+
+// WithResultStream makes RemoveAll write one JSON object per file to w as
+// soon as the file is done, so long runs can be tailed and post-processed
+// while they go: {"path", "result", "error"}, where result is the form of
+// ExifRemoveThumbnailResult.MarshalJSON and error is null or
+// {"code", "offset", "message"} with the ErrorClass as code. Each object is
+// written in one call ending with a newline. A failed write stops the run.
+func WithResultStream(w io.Writer) Option {
+	return func(o *options) {
+		o.resultStream = w
+	}
+}
+
+// streamResult writes the result of one file to the stream set by
+// WithResultStream, if any.
+func (o *options) streamResult(path string, f FileResult) error {
+	if o.resultStream == nil {
+		return nil
+	}
+	doc := fileDocument{Path: path, Result: f.Result}
+	if f.Err != nil {
+		doc.Error = &errorDocument{Code: ErrorClass(f.Err), Offset: -1, Message: f.Err.Error()}
+		var formatErr *FormatError
+		if errors.As(f.Err, &formatErr) {
+			doc.Error.Offset = formatErr.Offset
+		}
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if _, err := o.resultStream.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write result stream: %w", err)
+	}
+	return nil
+}
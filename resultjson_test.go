@@ -0,0 +1,52 @@
+package exifremovethumbnail_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestResultMarshalJSON(t *testing.T) {
+	t.Run("snake_caseのフィールド名と整数のバイト数", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithRemoveGPS())
+		require.NoError(t, err)
+
+		data, err := json.Marshal(result)
+		require.NoError(t, err)
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal(data, &doc))
+
+		require.Equal(t, "jpeg", doc["format"])
+		require.Equal(t, true, doc["had_thumbnail"])
+		require.Equal(t, float64(142050), doc["before_size"])
+		require.Equal(t, float64(result.AfterSize), doc["after_size"])
+		require.Equal(t, float64(result.ThumbnailSize), doc["thumbnail_size"])
+		require.Equal(t, float64(160), doc["thumbnail_width"])
+		require.Equal(t, float64(120), doc["thumbnail_height"])
+		require.Equal(t, "jpeg", doc["thumbnail_compression"])
+		require.Equal(t, []any{map[string]any{"ifd": "IFD0", "id": float64(0x8825)}}, doc["removed_tags"])
+		require.Equal(t, float64(1), doc["exif"].(map[string]any)["orientation"])
+	})
+
+	t.Run("空の値もnullではなく出力される", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "metadata_none.jpg"))
+		require.NoError(t, err)
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+
+		data, err := json.Marshal(result)
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"removed_tags":[]`)
+		require.Contains(t, string(data), `"removed_segments":{}`)
+		require.Contains(t, string(data), `"warnings":[]`)
+		require.Contains(t, string(data), `"thumbnail_compression":"unknown"`)
+		require.Contains(t, string(data), `"exif":{"make":"","model":"","orientation":0,"date_time_original":""}`)
+	})
+}
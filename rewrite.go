@@ -0,0 +1,229 @@
+package exifremovethumbnail
+
+import "sort"
+
+// span is a byte range [start, end) within a TIFF block.
+type span struct {
+	start, end int
+}
+
+// pointerTags lists the tags holding the offset of a sub-IFD, by the IFD they appear in.
+var pointerTags = map[IFD][]uint16{
+	IFD0:    {tagExifIFD, tagGPSIFD},
+	IFDExif: {tagInteropIFD},
+}
+
+// isPointer reports whether e in an IFD of the given kind points to a sub-IFD.
+func isPointer(kind IFD, e ifdEntry) bool {
+	for _, tag := range pointerTags[kind] {
+		if e.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// dirSpans returns the ranges occupied by d: the directory itself and the out-of-line values of its entries.
+func (t *tiffBlock) dirSpans(d *ifd) []span {
+	spans := []span{{d.Offset, d.NextPos() + 4}}
+	for _, e := range d.Entries {
+		if size := e.ValueSize(); size > 4 && int64(e.Value)+int64(size) <= int64(len(t.Data)) {
+			spans = append(spans, span{int(e.Value), int(e.Value) + size})
+		}
+	}
+	return spans
+}
+
+// liveSpans returns the sorted, merged ranges referenced from the TIFF header and dirs.
+func (t *tiffBlock) liveSpans(dirs map[IFD]*ifd) []span {
+	spans := []span{{0, 8}}
+	for _, d := range dirs {
+		spans = append(spans, t.dirSpans(d)...)
+	}
+	return mergeSpans(spans)
+}
+
+// mergeSpans sorts spans and joins overlapping or adjacent ones.
+func mergeSpans(spans []span) []span {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var merged []span
+	for _, s := range spans {
+		if s.end <= s.start {
+			continue
+		}
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// subtractSpans returns the parts of s not covered by the sorted, merged spans in live.
+func subtractSpans(s span, live []span) []span {
+	var rest []span
+	pos := s.start
+	for _, l := range live {
+		if l.end <= pos || l.start >= s.end {
+			continue
+		}
+		if l.start > pos {
+			rest = append(rest, span{pos, l.start})
+		}
+		pos = max(pos, l.end)
+	}
+	if pos < s.end {
+		rest = append(rest, span{pos, s.end})
+	}
+	return rest
+}
+
+// alignCuts shortens cuts followed by retained data to an even length, so that
+// word-aligned offsets stay aligned after the shift. size is the block length.
+func alignCuts(cuts []span, size int) []span {
+	var aligned []span
+	for _, c := range cuts {
+		if c.end < size {
+			c.end -= (c.end - c.start) % 2
+		}
+		if c.end > c.start {
+			aligned = append(aligned, c)
+		}
+	}
+	return aligned
+}
+
+// cut removes the sorted, non-overlapping spans from the block and returns the new TIFF data.
+// Offsets in the header and in dirs are adjusted to the shifted positions first, so dirs
+// must hold every IFD that stays in the block and none of them may lie inside a cut.
+func (t *tiffBlock) cut(dirs map[IFD]*ifd, cuts []span) []byte {
+	if len(cuts) == 0 {
+		return t.Data
+	}
+	moved := func(off uint32) uint32 {
+		shift := 0
+		for _, c := range cuts {
+			if c.start >= int(off) {
+				break
+			}
+			shift += min(c.end, int(off)) - c.start
+		}
+		return off - uint32(shift)
+	}
+	t.Order.PutUint32(t.Data[4:], moved(uint32(t.IFD0Offset())))
+	for kind, d := range dirs {
+		for _, e := range d.Entries {
+			if isPointer(kind, e) || e.ValueSize() > 4 {
+				t.Order.PutUint32(t.Data[e.Pos+8:], moved(e.Value))
+			}
+		}
+	}
+	out := make([]byte, 0, len(t.Data))
+	pos := 0
+	for _, c := range cuts {
+		out = append(out, t.Data[pos:c.start]...)
+		pos = c.end
+	}
+	return append(out, t.Data[pos:]...)
+}
+
+// compactExif repacks the TIFF structure of an EXIF payload when WithCompactExif is set.
+// Ranges not referenced from the header or the IFDs are cut.
+func compactExif(exifData []byte, o *options) []byte {
+	if !o.compactExif {
+		return exifData
+	}
+	t, err := parseTIFF(exifData[len(exifHeader):])
+	if err != nil {
+		return exifData
+	}
+	dirs, err := t.ifds()
+	if err != nil {
+		return exifData
+	}
+	if _, ok := dirs[IFD1]; ok {
+		// The thumbnail data is only referenced by offset and would be lost.
+		return exifData
+	}
+	cuts := alignCuts(subtractSpans(span{8, len(t.Data)}, t.liveSpans(dirs)), len(t.Data))
+	// Unreferenced data before a MakerNote that cannot move is left in place.
+	cuts, _ = t.pinnedCuts(dirs, cuts)
+	return append(exifData[:len(exifHeader)], t.cut(dirs, cuts)...)
+}
+
+// relocatableMakerNotes lists MakerNote prefixes whose internal offsets are relative
+// to the MakerNote itself, so the MakerNote may be moved within the EXIF data.
+var relocatableMakerNotes = []string{
+	"Nikon\x00\x02", // Nikon type 3 embeds its own TIFF header
+	"FUJIFILM",
+	"OLYMPUS\x00",
+	"OM SYSTEM\x00",
+	"Apple iOS\x00",
+}
+
+// offsetSensitiveMakerNote returns the MakerNote entry of dirs if it relies on
+// absolute offsets into the EXIF data (Canon, Sony, most others), so moving it
+// would make it unreadable.
+func (t *tiffBlock) offsetSensitiveMakerNote(dirs map[IFD]*ifd) (ifdEntry, bool) {
+	exifIFD, ok := dirs[IFDExif]
+	if !ok {
+		return ifdEntry{}, false
+	}
+	e, ok := exifIFD.Find(tagMakerNote)
+	if !ok {
+		return ifdEntry{}, false
+	}
+	value, ok := t.ValueBytes(e)
+	if !ok || e.ValueSize() <= 4 {
+		return ifdEntry{}, false
+	}
+	for _, prefix := range relocatableMakerNotes {
+		if len(value) >= len(prefix) && string(value[:len(prefix)]) == prefix {
+			return ifdEntry{}, false
+		}
+	}
+	return e, true
+}
+
+// pinnedCuts splits cuts into those that may shift data and those that must not,
+// because they lie before the end of an offset-sensitive MakerNote.
+// The layout up to the end of such a MakerNote is preserved.
+func (t *tiffBlock) pinnedCuts(dirs map[IFD]*ifd, cuts []span) (movable, pinned []span) {
+	e, ok := t.offsetSensitiveMakerNote(dirs)
+	if !ok {
+		return cuts, nil
+	}
+	end := int(e.Value) + e.ValueSize()
+	for _, c := range cuts {
+		if c.start < end {
+			pinned = append(pinned, c)
+		} else {
+			movable = append(movable, c)
+		}
+	}
+	return movable, pinned
+}
+
+// riskyMakerNote reports whether an EXIF segment payload holds an offset-sensitive
+// MakerNote that processing with o would keep.
+func riskyMakerNote(exifData []byte, o *options) bool {
+	if o.stripAllExif {
+		return false
+	}
+	for _, ref := range o.removeTags {
+		if ref == (TagRef{IFD: IFDExif, ID: tagMakerNote}) {
+			return false
+		}
+	}
+	t, err := parseTIFF(exifData[len(exifHeader):])
+	if err != nil {
+		return false
+	}
+	dirs, err := t.ifds()
+	if err != nil {
+		return false
+	}
+	_, risky := t.offsetSensitiveMakerNote(dirs)
+	return risky
+}
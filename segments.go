@@ -0,0 +1,139 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+// Segment names a JPEG application segment, optionally qualified by its identifier.
+// An unqualified name such as "APP1" matches every APP1 segment while
+// "APP1-Exif" only matches APP1 segments holding EXIF data.
+type Segment string
+
+// Well-known application segments.
+const (
+	SegmentJFIF      Segment = "APP0-JFIF"
+	SegmentExif      Segment = "APP1-Exif"
+	SegmentXMP       Segment = "APP1-XMP"
+	SegmentICC       Segment = "APP2-ICC"
+	SegmentPhotoshop Segment = "APP13-Photoshop"
+	SegmentAdobe     Segment = "APP14-Adobe"
+	// SegmentPadding is the padding written by WithConstantSize and
+	// ExifRemoveThumbnailInPlace.
+	SegmentPadding Segment = "APP15-Padding"
+	// SegmentTrailer is the data after EOI removed by WithStripTrailer.
+	SegmentTrailer Segment = "Trailer"
+)
+
+const (
+	markerAPP0  = spec.APP0
+	markerAPP2  = spec.APP2
+	markerAPP15 = spec.APP15
+
+	iccHeader = "ICC_PROFILE\x00"
+	// maxICCChunk is the largest profile chunk fitting in one APP2 segment.
+	maxICCChunk = 0xFFFF - 2 - len(iccHeader) - 2
+)
+
+// isAPPn reports whether marker is one of APP0 to APP15.
+func isAPPn(marker uint16) bool {
+	return marker >= markerAPP0 && marker <= markerAPP15
+}
+
+// segmentName returns the most specific name of an application segment.
+func segmentName(marker uint16, segmentData []byte) Segment {
+	hasPrefix := func(prefix string) bool {
+		return bytes.HasPrefix(segmentData, []byte(prefix))
+	}
+	switch {
+	case marker == markerAPP0 && (hasPrefix("JFIF\x00") || hasPrefix("JFXX\x00")):
+		return SegmentJFIF
+	case marker == markerAPP0+1 && isExifSegment(segmentData):
+		return SegmentExif
+	case marker == markerAPP0+1 && isXMPSegment(segmentData):
+		return SegmentXMP
+	case marker == markerAPP0+2 && isICCSegment(segmentData):
+		return SegmentICC
+	case marker == markerAPP0+13 && hasPrefix("Photoshop 3.0\x00"):
+		return SegmentPhotoshop
+	case marker == markerAPP0+14 && hasPrefix("Adobe"):
+		return SegmentAdobe
+	case marker == markerAPP0+15 && hasPrefix(paddingID):
+		return SegmentPadding
+	}
+	return Segment(fmt.Sprintf("APP%d", marker-markerAPP0))
+}
+
+// matches reports whether name is selected by s.
+func (s Segment) matches(name Segment) bool {
+	if s == name {
+		return true
+	}
+	base, _, _ := strings.Cut(string(name), "-")
+	return string(s) == base
+}
+
+// dropSegment reports whether an application segment is removed by the keep/drop lists.
+func (o *options) dropSegment(name Segment) bool {
+	for _, s := range o.dropSegments {
+		if s.matches(name) {
+			return true
+		}
+	}
+	if len(o.keepSegments) == 0 {
+		return false
+	}
+	for _, s := range o.keepSegments {
+		if s.matches(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeICCSegments writes profile as a sequence of APP2 ICC_PROFILE segments.
+func writeICCSegments(w io.Writer, profile []byte) {
+	count := (len(profile) + maxICCChunk - 1) / maxICCChunk
+	for i := 0; i < count; i++ {
+		chunk := profile[i*maxICCChunk : min((i+1)*maxICCChunk, len(profile))]
+		payload := make([]byte, 0, len(iccHeader)+2+len(chunk))
+		payload = append(payload, iccHeader...)
+		payload = append(payload, byte(i+1), byte(count))
+		payload = append(payload, chunk...)
+		writeSegment(w, markerAPP2, payload)
+	}
+}
+
+// paddingID identifies the padding segments written by padding.
+const paddingID = "Padding\x00"
+
+// minPadding is the size of the smallest padding segment.
+const minPadding int64 = 4 + int64(len(paddingID))
+
+// padding returns n bytes that JPEG decoders skip in front of a marker:
+// APP15 segments identified by paddingID and filled with zeros, and 0xFF fill
+// bytes when fewer than minPadding bytes are left.
+func padding(n int64) []byte {
+	var out []byte
+	for n > 0 {
+		if n < minPadding {
+			return append(out, bytes.Repeat([]byte{0xFF}, int(n))...)
+		}
+		size := min(n, 2+0xFFFF)
+		if rest := n - size; rest > 0 && rest < minPadding {
+			size -= minPadding
+		}
+		segment := make([]byte, size)
+		binary.BigEndian.PutUint16(segment, spec.APP15)
+		binary.BigEndian.PutUint16(segment[2:], uint16(size-2))
+		copy(segment[4:], paddingID)
+		out = append(out, segment...)
+		n -= size
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package exifremovethumbnail
+
+// SkipReason tells why a file was left alone, so that reports can distinguish
+// intentional skips from files that were silently missed.
+type SkipReason string
+
+const (
+	// SkipUnsupportedFormat is a file that is neither JPEG nor handled by a registered FormatHandler.
+	SkipUnsupportedFormat SkipReason = "unsupported_format"
+	// SkipUnreadable is a file in a supported format that cannot be parsed.
+	SkipUnreadable SkipReason = "unreadable"
+	// SkipTooSmall is a file below the size set by WithMinFileSize.
+	SkipTooSmall SkipReason = "too_small"
+	// SkipTooLarge is a file above the size set by WithMaxFileSize.
+	SkipTooLarge SkipReason = "too_large"
+	// SkipExcluded is a file left out by WithExtensions, WithIncludePath or
+	// WithExcludePath.
+	SkipExcluded SkipReason = "excluded"
+	// SkipModifiedTime is a file outside the modification times set by
+	// WithModifiedAfter, WithModifiedBefore, WithNewerThan or WithOlderThan.
+	SkipModifiedTime SkipReason = "modified_time"
+	// SkipRiskyMakerNote is a file left unchanged by WithSkipRiskyMakerNote.
+	SkipRiskyMakerNote SkipReason = "risky_maker_note"
+	// SkipCompleted is a file recorded as done in the file set by WithCheckpoint.
+	SkipCompleted SkipReason = "completed"
+	// SkipKnownClean is a file whose content is recorded as clean in the
+	// store set by WithStateStore.
+	SkipKnownClean SkipReason = "known_clean"
+)
+
+// WithSkipHandler makes TopOffenders, SampleSavings and RemoveAll call fn with
+// the path and the reason of every file they skip.
+func WithSkipHandler(fn func(path string, reason SkipReason)) Option {
+	return func(o *options) {
+		o.onSkip = fn
+	}
+}
+
+// skip reports a skipped file to the handler set by WithSkipHandler.
+func (o *options) skip(path string, reason SkipReason) {
+	if o.onSkip != nil {
+		o.onSkip(path, reason)
+	}
+}
@@ -0,0 +1,47 @@
+// Package spec defines the JPEG marker and EXIF tag values that
+// exifremovethumbnail relies on.
+//
+// The package uses these constants itself, so custom transformers and tests
+// written against them always agree with it. The values come from the JPEG
+// (ITU-T T.81), JFIF, TIFF 6.0 and Exif 2.3 specifications and never change.
+package spec
+
+// JPEG markers, as the two bytes read big-endian.
+const (
+	SOI   = 0xFFD8
+	EOI   = 0xFFD9
+	SOS   = 0xFFDA
+	APP0  = 0xFFE0
+	APP1  = 0xFFE1
+	APP2  = 0xFFE2
+	APP13 = 0xFFED
+	APP15 = 0xFFEF
+	COM   = 0xFFFE
+)
+
+// ExifHeader precedes the TIFF data in an APP1 EXIF segment.
+const ExifHeader = "Exif\x00\x00"
+
+// TIFF and EXIF tags.
+const (
+	TagImageWidth                  = 0x0100
+	TagImageLength                 = 0x0101
+	TagCompression                 = 0x0103
+	TagMake                        = 0x010F
+	TagModel                       = 0x0110
+	TagStripOffsets                = 0x0111
+	TagOrientation                 = 0x0112
+	TagStripByteCounts             = 0x0117
+	TagDateTime                    = 0x0132
+	TagArtist                      = 0x013B
+	TagJPEGInterchangeFormat       = 0x0201
+	TagJPEGInterchangeFormatLength = 0x0202
+	TagExifIFD                     = 0x8769
+	TagGPSIFD                      = 0x8825
+	TagDateTimeOriginal            = 0x9003
+	TagMakerNote                   = 0x927C
+	TagInteropIFD                  = 0xA005
+	TagCameraOwnerName             = 0xA430
+	TagBodySerialNumber            = 0xA431
+	TagLensSerialNumber            = 0xA435
+)
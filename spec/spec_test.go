@@ -0,0 +1,45 @@
+package spec_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+func TestMarkers(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+
+	t.Run("テスト画像のマーカーと一致する", func(t *testing.T) {
+		// SOI, APP0-JFIF (2..20), APP1-Exif (20..)
+		require.Equal(t, uint16(spec.SOI), binary.BigEndian.Uint16(data))
+		require.Equal(t, uint16(spec.APP0), binary.BigEndian.Uint16(data[2:]))
+		require.Equal(t, uint16(spec.APP1), binary.BigEndian.Uint16(data[20:]))
+		require.Equal(t, spec.ExifHeader, string(data[24:30]))
+		require.Equal(t, uint16(spec.EOI), binary.BigEndian.Uint16(data[len(data)-2:]))
+	})
+
+	t.Run("値は仕様どおりで変わらない", func(t *testing.T) {
+		for _, c := range []struct {
+			name      string
+			got, want uint16
+		}{
+			{"SOS", spec.SOS, 0xFFDA},
+			{"APP2", spec.APP2, 0xFFE2},
+			{"APP13", spec.APP13, 0xFFED},
+			{"APP15", spec.APP15, 0xFFEF},
+			{"COM", spec.COM, 0xFFFE},
+		} {
+			require.Equal(t, c.want, c.got, c.name)
+		}
+		require.Equal(t, uint16(0x0201), uint16(spec.TagJPEGInterchangeFormat))
+		require.Equal(t, uint16(0x0202), uint16(spec.TagJPEGInterchangeFormatLength))
+		require.Equal(t, uint16(0x8769), uint16(spec.TagExifIFD))
+		require.Equal(t, uint16(0x927C), uint16(spec.TagMakerNote))
+	})
+}
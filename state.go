@@ -0,0 +1,115 @@
+package exifremovethumbnail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// StateStore remembers the content hashes of files known to need no change,
+// so that RemoveAll can skip them on later runs without parsing them.
+// Hashes are hex SHA-256 digests of whole files. A store used by concurrent
+// runs must be safe for concurrent use.
+type StateStore interface {
+	// IsClean reports whether hash was recorded by MarkClean.
+	IsClean(hash string) (bool, error)
+	// MarkClean records hash as the content of a file that needs no change.
+	MarkClean(hash string) error
+}
+
+// WithStateStore makes RemoveAll look up the content hash of every file in s
+// and skip the files known to be clean with SkipKnownClean, and record the
+// files it leaves unchanged or rewrites. Repeat runs over mostly unchanged
+// archives then only read the files. What is clean depends on the options,
+// so use one store per set of options.
+func WithStateStore(s StateStore) Option {
+	return func(o *options) {
+		o.stateStore = s
+	}
+}
+
+// StateFile is a StateStore kept in a JSON file. Changes are kept in memory
+// until Save. It is safe for concurrent use.
+type StateFile struct {
+	path  string
+	mu    sync.Mutex
+	clean map[string]bool
+	dirty bool
+}
+
+// stateDocument is the JSON form of a StateFile. The hashes recorded by
+// another BehaviorVersion are discarded, since the default output changed.
+type stateDocument struct {
+	BehaviorVersion int      `json:"behavior_version"`
+	Clean           []string `json:"clean"`
+}
+
+// OpenStateFile reads the StateFile at path, or returns an empty one when the
+// file does not exist yet.
+func OpenStateFile(path string) (*StateFile, error) {
+	s := &StateFile{path: path, clean: map[string]bool{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	var doc stateDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+	if doc.BehaviorVersion != BehaviorVersion {
+		s.dirty = true
+		return s, nil
+	}
+	for _, hash := range doc.Clean {
+		s.clean[hash] = true
+	}
+	return s, nil
+}
+
+// IsClean reports whether hash is recorded in the file.
+func (s *StateFile) IsClean(hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clean[hash], nil
+}
+
+// MarkClean records hash in memory; Save writes it to the file.
+func (s *StateFile) MarkClean(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.clean[hash] {
+		s.clean[hash] = true
+		s.dirty = true
+	}
+	return nil
+}
+
+// Save writes the recorded hashes to the file, through a temporary file and
+// a rename, if anything changed since it was opened or last saved.
+func (s *StateFile) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	doc := stateDocument{BehaviorVersion: BehaviorVersion, Clean: make([]string, 0, len(s.clean))}
+	for hash := range s.clean {
+		doc.Clean = append(doc.Clean, hash)
+	}
+	sort.Strings(doc.Clean)
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(s.path, data, &options{}); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
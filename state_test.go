@@ -0,0 +1,118 @@
+package exifremovethumbnail_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+// errStore は常に失敗するStateStore
+type errStore struct{}
+
+func (errStore) IsClean(string) (bool, error) { return false, errors.New("store down") }
+func (errStore) MarkClean(string) error       { return errors.New("store down") }
+
+func TestStateStore(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+	noExif, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithStripAllExif())
+	require.NoError(t, err)
+	newFS := func() mapWriteFS {
+		return mapWriteFS{fstest.MapFS{
+			"a.jpg":     {Data: inData},
+			"b.jpg":     {Data: noExif},
+			"notes.txt": {Data: []byte("not an image")},
+		}}
+	}
+	t.Run("きれいなファイルは次の実行で解析しない", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		fsys := newFS()
+		store, err := exifremovethumbnail.OpenStateFile(path)
+		require.NoError(t, err)
+		report, err := exifremovethumbnail.RemoveAll(fsys, "*", exifremovethumbnail.WithStateStore(store))
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Changed)
+		require.True(t, report.Files["b.jpg"].Result.Unchanged)
+		require.NoError(t, store.Save())
+
+		store, err = exifremovethumbnail.OpenStateFile(path)
+		require.NoError(t, err)
+		sum := sha256.Sum256(want)
+		clean, err := store.IsClean(hex.EncodeToString(sum[:]))
+		require.NoError(t, err)
+		require.True(t, clean)
+
+		// 新しく届いたファイルだけを解析する
+		fsys.MapFS["c.jpg"] = &fstest.MapFile{Data: append([]byte{}, noExif...)}
+		fsys.MapFS["c.jpg"].Data[len(noExif)-3] ^= 1
+		skipped := map[string]exifremovethumbnail.SkipReason{}
+		report, err = exifremovethumbnail.RemoveAll(fsys, "*", exifremovethumbnail.WithStateStore(store),
+			exifremovethumbnail.WithSkipHandler(func(name string, reason exifremovethumbnail.SkipReason) {
+				skipped[name] = reason
+			}))
+		require.NoError(t, err)
+		require.Zero(t, report.Changed)
+		require.Equal(t, map[string]exifremovethumbnail.SkipReason{
+			"a.jpg":     exifremovethumbnail.SkipKnownClean,
+			"b.jpg":     exifremovethumbnail.SkipKnownClean,
+			"notes.txt": exifremovethumbnail.SkipUnsupportedFormat,
+		}, skipped)
+		require.True(t, report.Files["a.jpg"].Result.Skipped)
+		require.False(t, report.Files["a.jpg"].Result.HadThumbnail, "解析していない")
+		require.True(t, report.Files["c.jpg"].Result.Unchanged)
+
+		// 内容で判断するので、別のパスのファイルもスキップする
+		fsys.MapFS["d.jpg"] = &fstest.MapFile{Data: inData}
+		fsys.MapFS["e.jpg"] = &fstest.MapFile{Data: want}
+		report, err = exifremovethumbnail.RemoveAll(fsys, "[de].jpg", exifremovethumbnail.WithStateStore(store))
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Changed)
+		require.Equal(t, exifremovethumbnail.SkipKnownClean, report.Files["e.jpg"].Result.SkipReason)
+		require.Equal(t, want, fsys.MapFS["d.jpg"].Data)
+	})
+
+	t.Run("変更がなければ保存しない", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		store, err := exifremovethumbnail.OpenStateFile(path)
+		require.NoError(t, err)
+		require.NoError(t, store.Save())
+		require.NoFileExists(t, path)
+	})
+
+	t.Run("別の動作バージョンの記録は捨てる", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		sum := sha256.Sum256(want)
+		hash := hex.EncodeToString(sum[:])
+		require.NoError(t, os.WriteFile(path, []byte(`{"behavior_version":1,"clean":["`+hash+`"]}`), 0644))
+		store, err := exifremovethumbnail.OpenStateFile(path)
+		require.NoError(t, err)
+		clean, err := store.IsClean(hash)
+		require.NoError(t, err)
+		require.False(t, clean)
+	})
+
+	t.Run("壊れた状態ファイル", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+		_, err := exifremovethumbnail.OpenStateFile(path)
+		require.Error(t, err)
+	})
+
+	t.Run("ストアのエラーはファイルごとに記録する", func(t *testing.T) {
+		fsys := newFS()
+		report, err := exifremovethumbnail.RemoveAll(fsys, "*.jpg", exifremovethumbnail.WithStateStore(errStore{}))
+		require.NoError(t, err)
+		require.Equal(t, 2, report.Failed)
+		require.Equal(t, inData, fsys.MapFS["a.jpg"].Data)
+	})
+}
@@ -0,0 +1,51 @@
+package exifremovethumbnail
+
+import "github.com/ideamans/go-exif-remove-thumbnail/spec"
+
+const (
+	tagMake             = spec.TagMake
+	tagModel            = spec.TagModel
+	tagDateTime         = spec.TagDateTime
+	tagDateTimeOriginal = spec.TagDateTimeOriginal
+)
+
+// ExifSummary holds basic EXIF facts gathered during processing.
+// Values are read from the input before any tag is removed.
+// DateTimeOriginal is the raw EXIF string ("YYYY:MM:DD HH:MM:SS"),
+// falling back to the IFD0 DateTime when the original capture date is missing.
+type ExifSummary struct {
+	Make             string
+	Model            string
+	Orientation      int
+	DateTimeOriginal string
+}
+
+// summary reads the EXIF summary fields from the block.
+func (t *tiffBlock) summary() ExifSummary {
+	var s ExifSummary
+	dirs, err := t.ifds()
+	if err != nil {
+		return s
+	}
+	ifd0 := dirs[IFD0]
+	if e, ok := ifd0.Find(tagMake); ok {
+		s.Make, _ = t.ASCII(e)
+	}
+	if e, ok := ifd0.Find(tagModel); ok {
+		s.Model, _ = t.ASCII(e)
+	}
+	if e, ok := ifd0.Find(tagOrientation); ok {
+		s.Orientation, _ = t.uintValue(e)
+	}
+	if exif, ok := dirs[IFDExif]; ok {
+		if e, ok := exif.Find(tagDateTimeOriginal); ok {
+			s.DateTimeOriginal, _ = t.ASCII(e)
+		}
+	}
+	if s.DateTimeOriginal == "" {
+		if e, ok := ifd0.Find(tagDateTime); ok {
+			s.DateTimeOriginal, _ = t.ASCII(e)
+		}
+	}
+	return s
+}
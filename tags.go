@@ -0,0 +1,104 @@
+package exifremovethumbnail
+
+import "fmt"
+
+// IFD identifies an image file directory inside EXIF data.
+type IFD int
+
+const (
+	// IFD0 is the primary image directory.
+	IFD0 IFD = iota
+	// IFDExif is the EXIF sub-IFD referenced from IFD0.
+	IFDExif
+	// IFDGPS is the GPS sub-IFD referenced from IFD0.
+	IFDGPS
+	// IFDInterop is the interoperability sub-IFD referenced from the EXIF IFD.
+	IFDInterop
+	// IFD1 is the thumbnail directory chained after IFD0.
+	IFD1
+)
+
+// String returns the conventional name of the IFD.
+func (i IFD) String() string {
+	switch i {
+	case IFD0:
+		return "IFD0"
+	case IFDExif:
+		return "Exif"
+	case IFDGPS:
+		return "GPS"
+	case IFDInterop:
+		return "Interop"
+	case IFD1:
+		return "IFD1"
+	}
+	return "Unknown"
+}
+
+// TagRef identifies a tag within a specific IFD.
+type TagRef struct {
+	IFD IFD
+	ID  uint16
+}
+
+// tiffTagNames names the TIFF tags used in IFD0 and IFD1.
+var tiffTagNames = map[uint16]string{
+	0x0100: "ImageWidth", 0x0101: "ImageLength", 0x0102: "BitsPerSample", 0x0103: "Compression",
+	0x0106: "PhotometricInterpretation", 0x010E: "ImageDescription", 0x010F: "Make", 0x0110: "Model",
+	0x0111: "StripOffsets", 0x0112: "Orientation", 0x0115: "SamplesPerPixel", 0x0116: "RowsPerStrip",
+	0x0117: "StripByteCounts", 0x011A: "XResolution", 0x011B: "YResolution", 0x011C: "PlanarConfiguration",
+	0x0128: "ResolutionUnit", 0x0131: "Software", 0x0132: "DateTime", 0x013B: "Artist",
+	0x013E: "WhitePoint", 0x013F: "PrimaryChromaticities", 0x0201: "JPEGInterchangeFormat",
+	0x0202: "JPEGInterchangeFormatLength", 0x0211: "YCbCrCoefficients", 0x0212: "YCbCrSubSampling",
+	0x0213: "YCbCrPositioning", 0x0214: "ReferenceBlackWhite", 0x4746: "Rating", 0x8298: "Copyright",
+	0x8769: "ExifIFDPointer", 0x8825: "GPSInfoIFDPointer",
+}
+
+// tagNames names the tags of each IFD.
+var tagNames = map[IFD]map[uint16]string{
+	IFD0: tiffTagNames,
+	IFD1: tiffTagNames,
+	IFDExif: {
+		0x829A: "ExposureTime", 0x829D: "FNumber", 0x8822: "ExposureProgram", 0x8827: "ISOSpeedRatings",
+		0x8830: "SensitivityType", 0x9000: "ExifVersion", 0x9003: "DateTimeOriginal", 0x9004: "DateTimeDigitized",
+		0x9010: "OffsetTime", 0x9011: "OffsetTimeOriginal", 0x9012: "OffsetTimeDigitized",
+		0x9101: "ComponentsConfiguration", 0x9102: "CompressedBitsPerPixel", 0x9201: "ShutterSpeedValue",
+		0x9202: "ApertureValue", 0x9203: "BrightnessValue", 0x9204: "ExposureBiasValue", 0x9205: "MaxApertureValue",
+		0x9206: "SubjectDistance", 0x9207: "MeteringMode", 0x9208: "LightSource", 0x9209: "Flash",
+		0x920A: "FocalLength", 0x9214: "SubjectArea", 0x927C: "MakerNote", 0x9286: "UserComment",
+		0x9290: "SubSecTime", 0x9291: "SubSecTimeOriginal", 0x9292: "SubSecTimeDigitized",
+		0xA000: "FlashpixVersion", 0xA001: "ColorSpace", 0xA002: "PixelXDimension", 0xA003: "PixelYDimension",
+		0xA005: "InteroperabilityIFDPointer", 0xA20E: "FocalPlaneXResolution", 0xA20F: "FocalPlaneYResolution",
+		0xA210: "FocalPlaneResolutionUnit", 0xA217: "SensingMethod", 0xA300: "FileSource", 0xA301: "SceneType",
+		0xA401: "CustomRendered", 0xA402: "ExposureMode", 0xA403: "WhiteBalance", 0xA404: "DigitalZoomRatio",
+		0xA405: "FocalLengthIn35mmFilm", 0xA406: "SceneCaptureType", 0xA408: "Contrast", 0xA409: "Saturation",
+		0xA40A: "Sharpness", 0xA40C: "SubjectDistanceRange", 0xA420: "ImageUniqueID", 0xA430: "CameraOwnerName",
+		0xA431: "BodySerialNumber", 0xA432: "LensSpecification", 0xA433: "LensMake", 0xA434: "LensModel",
+		0xA435: "LensSerialNumber",
+	},
+	IFDGPS: {
+		0x0000: "GPSVersionID", 0x0001: "GPSLatitudeRef", 0x0002: "GPSLatitude", 0x0003: "GPSLongitudeRef",
+		0x0004: "GPSLongitude", 0x0005: "GPSAltitudeRef", 0x0006: "GPSAltitude", 0x0007: "GPSTimeStamp",
+		0x0008: "GPSSatellites", 0x0009: "GPSStatus", 0x000A: "GPSMeasureMode", 0x000B: "GPSDOP",
+		0x000C: "GPSSpeedRef", 0x000D: "GPSSpeed", 0x000E: "GPSTrackRef", 0x000F: "GPSTrack",
+		0x0010: "GPSImgDirectionRef", 0x0011: "GPSImgDirection", 0x0012: "GPSMapDatum",
+		0x001B: "GPSProcessingMethod", 0x001D: "GPSDateStamp",
+	},
+	IFDInterop: {
+		0x0001: "InteroperabilityIndex", 0x0002: "InteroperabilityVersion",
+	},
+}
+
+// Name returns the conventional name of the tag, or "" if it is not known.
+func (r TagRef) Name() string {
+	return tagNames[r.IFD][r.ID]
+}
+
+// String returns the tag as "IFD.Name", or "IFD.0xNNNN" for unknown tags,
+// e.g. "Exif.DateTimeOriginal".
+func (r TagRef) String() string {
+	if name := r.Name(); name != "" {
+		return r.IFD.String() + "." + name
+	}
+	return fmt.Sprintf("%s.0x%04X", r.IFD, r.ID)
+}
@@ -0,0 +1,134 @@
+package exifremovethumbnail
+
+import (
+	"encoding/binary"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+const (
+	tagImageWidth   = spec.TagImageWidth
+	tagImageLength  = spec.TagImageLength
+	tagCompression  = spec.TagCompression
+	tagStripOffsets = spec.TagStripOffsets
+
+	tagStripByteCounts = spec.TagStripByteCounts
+
+	compressionNone    = 1
+	compressionOldJPEG = 6
+	compressionJPEG    = 7
+)
+
+// ThumbnailCompression is the storage format of an IFD1 thumbnail.
+type ThumbnailCompression int
+
+const (
+	// ThumbnailUnknown means the format could not be determined.
+	ThumbnailUnknown ThumbnailCompression = iota
+	// ThumbnailJPEG is a JPEG compressed thumbnail.
+	ThumbnailJPEG
+	// ThumbnailUncompressed is an uncompressed (strip based) thumbnail.
+	ThumbnailUncompressed
+)
+
+// String returns a lower-case name of the compression.
+func (c ThumbnailCompression) String() string {
+	switch c {
+	case ThumbnailJPEG:
+		return "jpeg"
+	case ThumbnailUncompressed:
+		return "uncompressed"
+	}
+	return "unknown"
+}
+
+// thumbnailInfo describes the thumbnail referenced by IFD1.
+// offset is relative to the TIFF header; -1 when unknown.
+// size is the byte count recorded in IFD1, 0 when unknown.
+type thumbnailInfo struct {
+	width       int
+	height      int
+	compression ThumbnailCompression
+	offset      int64
+	size        int64
+}
+
+// uintValue returns the first SHORT or LONG value stored inline in e.
+func (t *tiffBlock) uintValue(e ifdEntry) (int, bool) {
+	switch e.Type {
+	case typeShort:
+		return int(t.Short(e)), true
+	case typeLong:
+		return int(e.Value), true
+	}
+	return 0, false
+}
+
+// thumbnailInfo collects the format, dimensions and location of the IFD1 thumbnail.
+func (t *tiffBlock) thumbnailInfo(ifd1 *ifd) thumbnailInfo {
+	info := thumbnailInfo{offset: -1}
+	compression := 0
+	if e, ok := ifd1.Find(tagCompression); ok {
+		compression, _ = t.uintValue(e)
+	}
+	if e, ok := ifd1.Find(tagJPEGInterchangeFormat); ok && compression != compressionNone {
+		info.compression = ThumbnailJPEG
+		info.offset = int64(e.Value)
+		if length, ok := ifd1.Find(tagJPEGInterchangeFormatLength); ok {
+			info.size = int64(length.Value)
+			if info.offset+info.size <= int64(len(t.Data)) {
+				info.width, info.height = jpegDimensions(t.Data[e.Value : e.Value+length.Value])
+			}
+		}
+		return info
+	}
+	switch compression {
+	case compressionOldJPEG, compressionJPEG:
+		info.compression = ThumbnailJPEG
+	case compressionNone:
+		info.compression = ThumbnailUncompressed
+	}
+	if e, ok := ifd1.Find(tagImageWidth); ok {
+		info.width, _ = t.uintValue(e)
+	}
+	if e, ok := ifd1.Find(tagImageLength); ok {
+		info.height, _ = t.uintValue(e)
+	}
+	if e, ok := ifd1.Find(tagStripOffsets); ok && e.Count == 1 {
+		if v, ok := t.uintValue(e); ok {
+			info.offset = int64(v)
+		}
+	}
+	if e, ok := ifd1.Find(tagStripByteCounts); ok && e.Count == 1 {
+		if v, ok := t.uintValue(e); ok {
+			info.size = int64(v)
+		}
+	}
+	return info
+}
+
+// jpegDimensions reads the frame size from the SOF segment of a JPEG stream.
+func jpegDimensions(data []byte) (int, int) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0, 0
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 0, 0
+		}
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		isSOF := marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+		if isSOF && pos+9 <= len(data) {
+			height := int(binary.BigEndian.Uint16(data[pos+5 : pos+7]))
+			width := int(binary.BigEndian.Uint16(data[pos+7 : pos+9]))
+			return width, height
+		}
+		if marker == 0xDA {
+			return 0, 0
+		}
+		pos += 2 + length
+	}
+	return 0, 0
+}
@@ -0,0 +1,174 @@
+package exifremovethumbnail
+
+import (
+	"encoding/binary"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+	"github.com/ideamans/go-exif-remove-thumbnail/tiff"
+)
+
+const (
+	exifHeader = spec.ExifHeader
+
+	tagOrientation = spec.TagOrientation
+	tagExifIFD     = spec.TagExifIFD
+	tagGPSIFD      = spec.TagGPSIFD
+	tagInteropIFD  = spec.TagInteropIFD
+	tagMakerNote   = spec.TagMakerNote
+
+	tagArtist           = spec.TagArtist
+	tagCameraOwnerName  = spec.TagCameraOwnerName
+	tagBodySerialNumber = spec.TagBodySerialNumber
+	tagLensSerialNumber = spec.TagLensSerialNumber
+
+	typeShort = tiff.TypeShort
+	typeLong  = tiff.TypeLong
+)
+
+// tiffBlock is a TIFF structure embedded in an EXIF segment, with the EXIF
+// specific operations on top of the tiff package.
+type tiffBlock struct {
+	*tiff.Block
+}
+
+type (
+	ifdEntry = tiff.Entry
+	ifd      = tiff.IFD
+)
+
+// parseTIFF reads the TIFF header from data.
+func parseTIFF(data []byte) (*tiffBlock, error) {
+	t, err := tiff.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return &tiffBlock{t}, nil
+}
+
+// ifds returns the IFDs of the block keyed by their kind.
+// Missing or unreadable sub-IFDs are left out, and so are IFDs overlapping
+// one found before: a crafted chain pointing back into itself would otherwise
+// have the same directory rewritten twice.
+func (t *tiffBlock) ifds() (map[IFD]*ifd, error) {
+	ifd0, err := t.ReadIFD(t.IFD0Offset())
+	if err != nil {
+		return nil, err
+	}
+	m := map[IFD]*ifd{IFD0: ifd0}
+	add := func(kind IFD, d *ifd) bool {
+		for _, o := range m {
+			if d.Overlaps(o) {
+				return false
+			}
+		}
+		m[kind] = d
+		return true
+	}
+	if d, ok := t.SubIFD(ifd0, tagExifIFD); ok && add(IFDExif, d) {
+		if d, ok := t.SubIFD(d, tagInteropIFD); ok {
+			add(IFDInterop, d)
+		}
+	}
+	if d, ok := t.SubIFD(ifd0, tagGPSIFD); ok {
+		add(IFDGPS, d)
+	}
+	if ifd0.Next != 0 {
+		if d, err := t.ReadIFD(ifd0.Next); err == nil {
+			add(IFD1, d)
+		}
+	}
+	return m, nil
+}
+
+// removeGPS drops the GPS IFD pointer from IFD0 and zero-fills the GPS IFD with its values.
+// It reports whether a GPS IFD was present.
+func (t *tiffBlock) removeGPS() (bool, error) {
+	dirs, err := t.ifds()
+	if err != nil {
+		return false, err
+	}
+	ifd0 := dirs[IFD0]
+	if gps, ok := dirs[IFDGPS]; ok {
+		for _, e := range gps.Entries {
+			if size := e.ValueSize(); size > 4 && int(e.Value)+size <= len(t.Data) {
+				clear(t.Data[e.Value : int(e.Value)+size])
+			}
+		}
+		clear(t.Data[gps.Offset : gps.NextPos()+4])
+	}
+	dropped := t.RemoveEntries(ifd0, func(e ifdEntry) bool { return e.Tag == tagGPSIFD })
+	return len(dropped) > 0, nil
+}
+
+// removeTags removes the referenced tags from their IFDs and returns the ones found.
+func (t *tiffBlock) removeTags(tags []TagRef) ([]TagRef, error) {
+	dirs, err := t.ifds()
+	if err != nil {
+		return nil, err
+	}
+	var removed []TagRef
+	for _, kind := range []IFD{IFD0, IFDExif, IFDGPS, IFDInterop, IFD1} {
+		d, ok := dirs[kind]
+		if !ok {
+			continue
+		}
+		dropped := t.RemoveEntries(d, func(e ifdEntry) bool {
+			for _, ref := range tags {
+				if ref.IFD == kind && ref.ID == e.Tag {
+					return true
+				}
+			}
+			return false
+		})
+		for _, e := range dropped {
+			removed = append(removed, TagRef{IFD: kind, ID: e.Tag})
+		}
+	}
+	return removed, nil
+}
+
+// buildOrientationExif builds a minimal EXIF segment payload holding only the Orientation tag.
+func buildOrientationExif(order binary.ByteOrder, orientation uint16) []byte {
+	value := make([]byte, 2)
+	order.PutUint16(value, orientation)
+	t, _ := tiff.Build(order, []tiff.Field{{Tag: tagOrientation, Type: typeShort, Count: 1, Value: value}})
+	return append([]byte(exifHeader), t...)
+}
+
+// exifOrientation returns the Orientation value stored in IFD0 of an EXIF segment payload.
+func exifOrientation(exifData []byte) (*tiffBlock, uint16, bool) {
+	t, err := parseTIFF(exifData[len(exifHeader):])
+	if err != nil {
+		return nil, 0, false
+	}
+	d, err := t.ReadIFD(t.IFD0Offset())
+	if err != nil {
+		return nil, 0, false
+	}
+	e, ok := d.Find(tagOrientation)
+	if !ok || e.Type != typeShort {
+		return nil, 0, false
+	}
+	return t, t.Short(e), true
+}
+
+// tagRefs lists the tags of all IFDs in the block, leaving out the pointers linking IFDs.
+func (t *tiffBlock) tagRefs() []TagRef {
+	dirs, err := t.ifds()
+	if err != nil {
+		return nil
+	}
+	var refs []TagRef
+	for _, kind := range []IFD{IFD0, IFDExif, IFDGPS, IFDInterop, IFD1} {
+		d, ok := dirs[kind]
+		if !ok {
+			continue
+		}
+		for _, e := range d.Entries {
+			if !isPointer(kind, e) {
+				refs = append(refs, TagRef{IFD: kind, ID: e.Tag})
+			}
+		}
+	}
+	return refs
+}
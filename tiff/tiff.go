@@ -0,0 +1,299 @@
+// Package tiff reads and edits the TIFF structure stored in EXIF data: the
+// header, the image file directories (IFDs) and their entries.
+//
+// It is the TIFF layer exifremovethumbnail uses itself, exported for custom
+// EXIF edits. A Block works on the data in place: entries can be removed and
+// values overwritten without moving anything else, so the offsets stored in
+// the data stay valid. Build writes a new block from scratch.
+package tiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrTruncated means the data is too short for a TIFF header.
+	ErrTruncated = errors.New("truncated data")
+	// ErrInvalidIFD means an IFD cannot be parsed.
+	ErrInvalidIFD = errors.New("invalid IFD")
+)
+
+// Field types.
+const (
+	TypeByte      = 1
+	TypeASCII     = 2
+	TypeShort     = 3
+	TypeLong      = 4
+	TypeRational  = 5
+	TypeSByte     = 6
+	TypeUndefined = 7
+	TypeSShort    = 8
+	TypeSLong     = 9
+	TypeSRational = 10
+	TypeFloat     = 11
+	TypeDouble    = 12
+)
+
+// typeSizes maps field types to the byte size of a single value.
+var typeSizes = map[uint16]int{
+	TypeByte: 1, TypeASCII: 1, TypeShort: 2, TypeLong: 4, TypeRational: 8, TypeSByte: 1,
+	TypeUndefined: 1, TypeSShort: 2, TypeSLong: 4, TypeSRational: 8, TypeFloat: 4, TypeDouble: 8,
+}
+
+// TypeSize returns the byte size of a single value of the field type, or
+// false for an unknown type.
+func TypeSize(typ uint16) (int, bool) {
+	size, ok := typeSizes[typ]
+	return size, ok
+}
+
+// Block is a TIFF structure, such as the one embedded in an EXIF segment
+// after the EXIF header. Offsets inside the block are relative to the start
+// of Data.
+type Block struct {
+	Data  []byte
+	Order binary.ByteOrder
+}
+
+// Entry is a single 12-byte IFD entry.
+type Entry struct {
+	Tag   uint16
+	Type  uint16
+	Count uint32
+	// Value is the raw value field: the offset of the value, or the value
+	// itself when it fits in 4 bytes.
+	Value uint32
+	// Pos is the offset of the entry itself within the block.
+	Pos int
+}
+
+// IFD is a parsed image file directory.
+type IFD struct {
+	Offset  int
+	Entries []Entry
+	// Next is the offset of the next IFD in the chain, 0 for none.
+	Next int
+}
+
+// Parse reads the TIFF header from data. Anything other than "II" is read as
+// big-endian.
+func Parse(data []byte) (*Block, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("invalid TIFF header: %w", ErrTruncated)
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if string(data[0:2]) == "II" {
+		order = binary.LittleEndian
+	}
+	return &Block{Data: data, Order: order}, nil
+}
+
+// IFD0Offset returns the offset of IFD0 stored in the header.
+func (t *Block) IFD0Offset() int {
+	return int(t.Order.Uint32(t.Data[4:8]))
+}
+
+// ReadIFD parses the IFD located at offset.
+func (t *Block) ReadIFD(offset int) (*IFD, error) {
+	if offset < 8 || len(t.Data) < offset+2 {
+		return nil, fmt.Errorf("%w offset %d", ErrInvalidIFD, offset)
+	}
+	count := int(t.Order.Uint16(t.Data[offset : offset+2]))
+	nextPos := offset + 2 + count*12
+	if len(t.Data) < nextPos+4 {
+		return nil, fmt.Errorf("%w at %d", ErrInvalidIFD, offset)
+	}
+	d := &IFD{Offset: offset, Entries: make([]Entry, count)}
+	for i := 0; i < count; i++ {
+		pos := offset + 2 + i*12
+		d.Entries[i] = Entry{
+			Tag:   t.Order.Uint16(t.Data[pos : pos+2]),
+			Type:  t.Order.Uint16(t.Data[pos+2 : pos+4]),
+			Count: t.Order.Uint32(t.Data[pos+4 : pos+8]),
+			Value: t.Order.Uint32(t.Data[pos+8 : pos+12]),
+			Pos:   pos,
+		}
+	}
+	d.Next = int(t.Order.Uint32(t.Data[nextPos : nextPos+4]))
+	return d, nil
+}
+
+// SubIFD follows the pointer tag in parent to a child IFD.
+func (t *Block) SubIFD(parent *IFD, tag uint16) (*IFD, bool) {
+	e, ok := parent.Find(tag)
+	if !ok {
+		return nil, false
+	}
+	d, err := t.ReadIFD(int(e.Value))
+	if err != nil {
+		return nil, false
+	}
+	return d, true
+}
+
+// ValueSize returns the byte size of the entry's value, or -1 for an unknown type.
+func (e Entry) ValueSize() int {
+	size, ok := typeSizes[e.Type]
+	if !ok {
+		return -1
+	}
+	return size * int(e.Count)
+}
+
+// NextPos returns the position of the next-IFD pointer of d.
+func (d *IFD) NextPos() int {
+	return d.Offset + 2 + len(d.Entries)*12
+}
+
+// Find returns the entry with the given tag.
+func (d *IFD) Find(tag uint16) (Entry, bool) {
+	for _, e := range d.Entries {
+		if e.Tag == tag {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Overlaps reports whether the directories of d and o share any bytes.
+func (d *IFD) Overlaps(o *IFD) bool {
+	return d.Offset < o.NextPos()+4 && o.Offset < d.NextPos()+4
+}
+
+// Short returns the first SHORT value stored inline in e.
+func (t *Block) Short(e Entry) uint16 {
+	return t.Order.Uint16(t.Data[e.Pos+8 : e.Pos+10])
+}
+
+// ValueBytes returns the raw value bytes of e, inline or out-of-line. They
+// share the memory of the block.
+func (t *Block) ValueBytes(e Entry) ([]byte, bool) {
+	size := e.ValueSize()
+	if size < 0 {
+		return nil, false
+	}
+	if size <= 4 {
+		return t.Data[e.Pos+8 : e.Pos+8+size], true
+	}
+	if int64(e.Value)+int64(size) > int64(len(t.Data)) {
+		return nil, false
+	}
+	return t.Data[e.Value : int(e.Value)+size], true
+}
+
+// ASCII returns the string value of an ASCII entry without trailing NULs and spaces.
+func (t *Block) ASCII(e Entry) (string, bool) {
+	if e.Type != TypeASCII {
+		return "", false
+	}
+	b, ok := t.ValueBytes(e)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimRight(string(b), "\x00 "), true
+}
+
+// SetValue overwrites the value bytes of e in place. value must have the
+// size of the current value, so nothing else in the block moves.
+func (t *Block) SetValue(e Entry, value []byte) error {
+	b, ok := t.ValueBytes(e)
+	if !ok {
+		return fmt.Errorf("tag 0x%04X: value out of range", e.Tag)
+	}
+	if len(b) != len(value) {
+		return fmt.Errorf("tag 0x%04X: value of %d bytes replaced with %d bytes", e.Tag, len(b), len(value))
+	}
+	copy(b, value)
+	return nil
+}
+
+// RemoveEntries deletes the entries matched by drop from d in place and
+// returns them. Remaining entries are shifted down, the next-IFD pointer
+// follows them and the freed tail is zero-filled. Out-of-line values of
+// dropped entries are zero-filled too so the removed data does not linger in
+// the block.
+func (t *Block) RemoveEntries(d *IFD, drop func(Entry) bool) []Entry {
+	var kept, dropped []Entry
+	for _, e := range d.Entries {
+		if drop(e) {
+			dropped = append(dropped, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	if len(dropped) == 0 {
+		return nil
+	}
+	raw := make([][]byte, len(kept))
+	for i, e := range kept {
+		raw[i] = append([]byte{}, t.Data[e.Pos:e.Pos+12]...)
+	}
+	for _, e := range dropped {
+		if size := e.ValueSize(); size > 4 && int(e.Value)+size <= len(t.Data) {
+			clear(t.Data[e.Value : int(e.Value)+size])
+		}
+	}
+	end := d.NextPos() + 4
+	t.Order.PutUint16(t.Data[d.Offset:], uint16(len(kept)))
+	pos := d.Offset + 2
+	for i := range kept {
+		copy(t.Data[pos:], raw[i])
+		kept[i].Pos = pos
+		pos += 12
+	}
+	t.Order.PutUint32(t.Data[pos:], uint32(d.Next))
+	clear(t.Data[pos+4 : end])
+	d.Entries = kept
+	return dropped
+}
+
+// Field is an entry with its value, as written by Build.
+type Field struct {
+	Tag   uint16
+	Type  uint16
+	Count uint32
+	// Value is the encoded value; its length must match Type and Count.
+	Value []byte
+}
+
+// Build writes a TIFF block holding fields in IFD0, in the order given.
+// Values larger than 4 bytes follow the directory, word-aligned.
+func Build(order binary.ByteOrder, fields []Field) ([]byte, error) {
+	size := 8 + 2 + len(fields)*12 + 4
+	for _, f := range fields {
+		n, ok := typeSizes[f.Type]
+		if !ok || n*int(f.Count) != len(f.Value) {
+			return nil, fmt.Errorf("tag 0x%04X: %d bytes do not hold %d values of type %d", f.Tag, len(f.Value), f.Count, f.Type)
+		}
+		if len(f.Value) > 4 {
+			size += len(f.Value) + len(f.Value)%2
+		}
+	}
+	buf := make([]byte, size)
+	if order == binary.LittleEndian {
+		copy(buf[0:2], "II")
+	} else {
+		copy(buf[0:2], "MM")
+	}
+	order.PutUint16(buf[2:4], 42)
+	order.PutUint32(buf[4:8], 8)
+	order.PutUint16(buf[8:10], uint16(len(fields)))
+	data := 8 + 2 + len(fields)*12 + 4
+	for i, f := range fields {
+		pos := 10 + i*12
+		order.PutUint16(buf[pos:], f.Tag)
+		order.PutUint16(buf[pos+2:], f.Type)
+		order.PutUint32(buf[pos+4:], f.Count)
+		if len(f.Value) <= 4 {
+			copy(buf[pos+8:pos+12], f.Value)
+			continue
+		}
+		order.PutUint32(buf[pos+8:], uint32(data))
+		copy(buf[data:], f.Value)
+		data += len(f.Value) + len(f.Value)%2
+	}
+	return buf, nil
+}
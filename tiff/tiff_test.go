@@ -0,0 +1,143 @@
+package tiff_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/jpegseg"
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+	"github.com/ideamans/go-exif-remove-thumbnail/tiff"
+)
+
+// exifTIFF はテスト画像のEXIFセグメントからTIFF部分をコピーして返す
+func exifTIFF(t *testing.T) []byte {
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	segments, _, err := jpegseg.Split(data)
+	require.NoError(t, err)
+	for _, s := range segments {
+		if s.Marker == spec.APP1 && string(s.Payload()[:6]) == spec.ExifHeader {
+			return append([]byte{}, s.Payload()[6:]...)
+		}
+	}
+	t.Fatal("no EXIF segment")
+	return nil
+}
+
+func TestBlock(t *testing.T) {
+	t.Run("IFDを読む", func(t *testing.T) {
+		b, err := tiff.Parse(exifTIFF(t))
+		require.NoError(t, err)
+		ifd0, err := b.ReadIFD(b.IFD0Offset())
+		require.NoError(t, err)
+		require.NotEmpty(t, ifd0.Entries)
+		require.NotZero(t, ifd0.Next)
+
+		ifd1, err := b.ReadIFD(ifd0.Next)
+		require.NoError(t, err)
+		require.False(t, ifd1.Overlaps(ifd0))
+		offset, ok := ifd1.Find(spec.TagJPEGInterchangeFormat)
+		require.True(t, ok)
+		length, ok := ifd1.Find(spec.TagJPEGInterchangeFormatLength)
+		require.True(t, ok)
+		// サムネイルはJPEG
+		require.Equal(t, []byte{0xFF, 0xD8}, b.Data[offset.Value:offset.Value+2])
+		require.LessOrEqual(t, int(offset.Value+length.Value), len(b.Data))
+
+		exif, ok := b.SubIFD(ifd0, spec.TagExifIFD)
+		require.True(t, ok)
+		require.NotEmpty(t, exif.Entries)
+		_, ok = b.SubIFD(ifd0, 0x1234)
+		require.False(t, ok)
+	})
+
+	t.Run("エントリを削除する", func(t *testing.T) {
+		b, err := tiff.Parse(exifTIFF(t))
+		require.NoError(t, err)
+		ifd0, err := b.ReadIFD(b.IFD0Offset())
+		require.NoError(t, err)
+		count := len(ifd0.Entries)
+		dropped := b.RemoveEntries(ifd0, func(e tiff.Entry) bool { return e.Tag == spec.TagExifIFD })
+		require.Len(t, dropped, 1)
+		require.Len(t, ifd0.Entries, count-1)
+
+		// 書き換えた後も読み直せて、次のIFDへのポインタは保たれる
+		reread, err := b.ReadIFD(b.IFD0Offset())
+		require.NoError(t, err)
+		require.Equal(t, ifd0.Entries, reread.Entries)
+		require.Equal(t, ifd0.Next, reread.Next)
+		_, ok := reread.Find(spec.TagExifIFD)
+		require.False(t, ok)
+	})
+
+	t.Run("値を書き換える", func(t *testing.T) {
+		b, err := tiff.Parse(exifTIFF(t))
+		require.NoError(t, err)
+		ifd0, err := b.ReadIFD(b.IFD0Offset())
+		require.NoError(t, err)
+		e, ok := ifd0.Find(spec.TagOrientation)
+		require.True(t, ok)
+		value := make([]byte, 2)
+		b.Order.PutUint16(value, 6)
+		require.NoError(t, b.SetValue(e, value))
+		require.Equal(t, uint16(6), b.Short(e))
+		require.Error(t, b.SetValue(e, []byte{1, 2, 3, 4}))
+	})
+
+	t.Run("作ったブロックを読める", func(t *testing.T) {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			orientation := make([]byte, 2)
+			order.PutUint16(orientation, 3)
+			data, err := tiff.Build(order, []tiff.Field{
+				{Tag: spec.TagOrientation, Type: tiff.TypeShort, Count: 1, Value: orientation},
+				{Tag: spec.TagMake, Type: tiff.TypeASCII, Count: 6, Value: []byte("Maker\x00")},
+				{Tag: spec.TagModel, Type: tiff.TypeASCII, Count: 3, Value: []byte("AB\x00")},
+			})
+			require.NoError(t, err)
+			b, err := tiff.Parse(data)
+			require.NoError(t, err)
+			require.Equal(t, order, b.Order)
+			ifd0, err := b.ReadIFD(b.IFD0Offset())
+			require.NoError(t, err)
+			require.Len(t, ifd0.Entries, 3)
+			require.Zero(t, ifd0.Next)
+
+			e, _ := ifd0.Find(spec.TagOrientation)
+			require.Equal(t, uint16(3), b.Short(e))
+			e, _ = ifd0.Find(spec.TagMake)
+			s, ok := b.ASCII(e)
+			require.True(t, ok)
+			require.Equal(t, "Maker", s)
+			// 4バイトを超える値は偶数位置に置かれる
+			require.Zero(t, e.Value%2)
+			e, _ = ifd0.Find(spec.TagModel)
+			s, _ = b.ASCII(e)
+			require.Equal(t, "AB", s)
+		}
+
+		_, err := tiff.Build(binary.BigEndian, []tiff.Field{{Tag: spec.TagOrientation, Type: tiff.TypeShort, Count: 2, Value: []byte{0, 1}}})
+		require.Error(t, err)
+	})
+
+	t.Run("壊れたデータ", func(t *testing.T) {
+		_, err := tiff.Parse([]byte("II*\x00"))
+		require.ErrorIs(t, err, tiff.ErrTruncated)
+
+		b, err := tiff.Parse([]byte("MM\x00*\x00\x00\x00\x08\x00\x05"))
+		require.NoError(t, err)
+		_, err = b.ReadIFD(b.IFD0Offset())
+		require.ErrorIs(t, err, tiff.ErrInvalidIFD)
+		_, err = b.ReadIFD(2)
+		require.ErrorIs(t, err, tiff.ErrInvalidIFD)
+
+		size, ok := tiff.TypeSize(tiff.TypeRational)
+		require.True(t, ok)
+		require.Equal(t, 8, size)
+		_, ok = tiff.TypeSize(99)
+		require.False(t, ok)
+	})
+}
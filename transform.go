@@ -0,0 +1,126 @@
+package exifremovethumbnail
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Transformer rewrites JPEG data streamed from src to dst. *Processor is a
+// Transformer; Chain combines several into one streaming pass.
+type Transformer interface {
+	Transform(dst io.Writer, src io.Reader) (ExifRemoveThumbnailResult, error)
+}
+
+// TransformerFunc adapts a function to the Transformer interface.
+type TransformerFunc func(dst io.Writer, src io.Reader) (ExifRemoveThumbnailResult, error)
+
+// Transform calls f(dst, src).
+func (f TransformerFunc) Transform(dst io.Writer, src io.Reader) (ExifRemoveThumbnailResult, error) {
+	return f(dst, src)
+}
+
+// Transform is Process, making a Processor a Transformer.
+func (p *Processor) Transform(dst io.Writer, src io.Reader) (ExifRemoveThumbnailResult, error) {
+	return p.Process(dst, src)
+}
+
+// Chain returns a Transformer running ts one after another in a single
+// streaming pass: every transformer runs in its own goroutine and feeds the
+// next one through a pipe, so the data is never held as a whole. When a
+// transformer fails, the chain stops and returns its error.
+//
+// The results are merged: the input is described by the first transformer
+// (BeforeSize, Exif, InputScanHash and so on), the output by the last one
+// (AfterSize, OutputScanHash, RetainedTags), and what the transformers removed
+// is added up.
+func Chain(ts ...Transformer) Transformer {
+	return chain(ts)
+}
+
+type chain []Transformer
+
+func (c chain) Transform(dst io.Writer, src io.Reader) (ExifRemoveThumbnailResult, error) {
+	if len(c) == 0 {
+		n, err := io.Copy(dst, src)
+		return ExifRemoveThumbnailResult{Format: FormatJPEG, BeforeSize: n, AfterSize: n, Unchanged: true}, err
+	}
+	results := make([]ExifRemoveThumbnailResult, len(c))
+	errs := make([]error, len(c))
+	var wg sync.WaitGroup
+	r := src
+	for i, t := range c {
+		w := dst
+		var pw *io.PipeWriter
+		var next *io.PipeReader
+		if i < len(c)-1 {
+			next, pw = io.Pipe()
+			w = pw
+		}
+		wg.Add(1)
+		go func(i int, t Transformer, w io.Writer, r io.Reader) {
+			defer wg.Done()
+			results[i], errs[i] = t.Transform(w, r)
+			if pw != nil {
+				pw.CloseWithError(errs[i])
+			}
+			// Unblock the transformer writing to r if this one stopped early.
+			if pr, ok := r.(*io.PipeReader); ok {
+				pr.CloseWithError(errors.Join(errs[i], io.ErrClosedPipe))
+			}
+		}(i, t, w, r)
+		r = next
+	}
+	wg.Wait()
+	var err error
+	for _, e := range errs {
+		// A transformer failing because its neighbor stopped is not the cause.
+		if e != nil && (err == nil || errors.Is(err, io.ErrClosedPipe) && !errors.Is(e, io.ErrClosedPipe)) {
+			err = e
+		}
+	}
+	return mergeResults(results), err
+}
+
+// mergeResults combines the results of the transformers of a chain.
+func mergeResults(results []ExifRemoveThumbnailResult) ExifRemoveThumbnailResult {
+	merged := results[0]
+	merged.RemovedTags = append([]TagRef{}, merged.RemovedTags...)
+	merged.Warnings = append([]string{}, merged.Warnings...)
+	if merged.RemovedSegments != nil {
+		segments := map[Segment]int64{}
+		for name, size := range merged.RemovedSegments {
+			segments[name] = size
+		}
+		merged.RemovedSegments = segments
+	}
+	for _, r := range results[1:] {
+		if r.HadThumbnail && !merged.HadThumbnail {
+			merged.HadThumbnail = true
+			merged.ThumbnailWidth = r.ThumbnailWidth
+			merged.ThumbnailHeight = r.ThumbnailHeight
+			merged.ThumbnailCompression = r.ThumbnailCompression
+			merged.ThumbnailOffset = -1
+		}
+		merged.ThumbnailSize += r.ThumbnailSize
+		merged.RemovedTags = append(merged.RemovedTags, r.RemovedTags...)
+		for name, size := range r.RemovedSegments {
+			if merged.RemovedSegments == nil {
+				merged.RemovedSegments = map[Segment]int64{}
+			}
+			merged.RemovedSegments[name] += size
+		}
+		merged.Warnings = append(merged.Warnings, r.Warnings...)
+		merged.PeakBufferedBytes += r.PeakBufferedBytes
+		if r.Skipped && !merged.Skipped {
+			merged.Skipped = true
+			merged.SkipReason = r.SkipReason
+		}
+		merged.Unchanged = merged.Unchanged && r.Unchanged
+	}
+	last := results[len(results)-1]
+	merged.AfterSize = last.AfterSize
+	merged.OutputScanHash = last.OutputScanHash
+	merged.RetainedTags = last.RetainedTags
+	return merged
+}
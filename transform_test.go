@@ -0,0 +1,64 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestChain(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	thumbnail := exifremovethumbnail.NewProcessor()
+	gps := exifremovethumbnail.NewProcessor(exifremovethumbnail.WithPolicy(exifremovethumbnail.Policy{RemoveGPS: true}))
+	comments := exifremovethumbnail.NewProcessor(exifremovethumbnail.WithPolicy(exifremovethumbnail.Policy{RemoveComments: true}))
+
+	t.Run("一度にまとめて処理したのと同じ結果", func(t *testing.T) {
+		want, wantRes, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithRemoveGPS(), exifremovethumbnail.WithStripComments())
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		res, err := exifremovethumbnail.Chain(thumbnail, gps, comments).Transform(&buf, bytes.NewReader(inData))
+		require.NoError(t, err)
+		require.Equal(t, want, buf.Bytes())
+		require.True(t, res.HadThumbnail)
+		require.Equal(t, wantRes.ThumbnailSize, res.ThumbnailSize)
+		require.Equal(t, int64(len(inData)), res.BeforeSize)
+		require.Equal(t, int64(len(want)), res.AfterSize)
+		require.ElementsMatch(t, wantRes.RemovedTags, res.RemovedTags)
+		require.False(t, res.Unchanged)
+	})
+
+	t.Run("変換がなければそのままコピー", func(t *testing.T) {
+		var buf bytes.Buffer
+		res, err := exifremovethumbnail.Chain().Transform(&buf, bytes.NewReader(inData))
+		require.NoError(t, err)
+		require.Equal(t, inData, buf.Bytes())
+		require.True(t, res.Unchanged)
+	})
+
+	t.Run("途中の変換のエラーを返す", func(t *testing.T) {
+		errBroken := errors.New("broken")
+		broken := exifremovethumbnail.TransformerFunc(func(dst io.Writer, src io.Reader) (exifremovethumbnail.ExifRemoveThumbnailResult, error) {
+			// 一部だけ読んで止まる
+			_, err := src.Read(make([]byte, 16))
+			require.NoError(t, err)
+			return exifremovethumbnail.ExifRemoveThumbnailResult{}, errBroken
+		})
+		large := append(append([]byte{}, inData...), bytes.Repeat([]byte{0}, 1<<20)...)
+		_, err := exifremovethumbnail.Chain(thumbnail, broken, comments).Transform(io.Discard, bytes.NewReader(large))
+		require.ErrorIs(t, err, errBroken)
+	})
+
+	t.Run("JPEGでなければエラー", func(t *testing.T) {
+		_, err := exifremovethumbnail.Chain(thumbnail, gps).Transform(io.Discard, bytes.NewReader([]byte("not a jpeg")))
+		require.ErrorIs(t, err, exifremovethumbnail.ErrNotJPEG)
+	})
+}
@@ -0,0 +1,88 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/jpegseg"
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+// errOutputDiffers stops the comparing pass of ExifRemoveThumbnailBytes at the
+// first byte where the output would differ from the input.
+var errOutputDiffers = errors.New("output differs from input")
+
+// matchWriter compares the bytes written with ref instead of storing them.
+type matchWriter struct {
+	ref []byte
+	n   int
+}
+
+func (m *matchWriter) Write(p []byte) (int, error) {
+	if len(p) > len(m.ref)-m.n || !bytes.Equal(p, m.ref[m.n:m.n+len(p)]) {
+		return 0, errOutputDiffers
+	}
+	m.n += len(p)
+	return len(p), nil
+}
+
+// fileMatchWriter is matchWriter for a reference read from a file, so that it
+// need not be held in memory.
+type fileMatchWriter struct {
+	ref io.ReaderAt
+	n   int64
+	buf []byte
+}
+
+func (m *fileMatchWriter) Write(p []byte) (int, error) {
+	if cap(m.buf) < len(p) {
+		m.buf = make([]byte, len(p))
+	}
+	buf := m.buf[:len(p)]
+	if n, _ := m.ref.ReadAt(buf, m.n); n < len(p) || !bytes.Equal(p, buf) {
+		return 0, errOutputDiffers
+	}
+	m.n += int64(len(p))
+	return len(p), nil
+}
+
+// mayBeUnchanged reports whether data looks already processed: none of its
+// EXIF segments links IFD0 to an IFD1. Only the segment headers are read.
+// Segment visitors are only called once per segment, so they disable the
+// comparing pass.
+func (o *options) mayBeUnchanged(data []byte) bool {
+	if o.conformance || o.fault != 0 || len(o.visitors) > 0 {
+		return false
+	}
+	sc := jpegseg.NewScanner(data)
+	for sc.Next() {
+		s := sc.Segment()
+		if s.Marker != spec.APP1 || !isExifSegment(s.Payload()) {
+			continue
+		}
+		t, err := parseTIFF(s.Payload()[len(exifHeader):])
+		if err != nil {
+			return false
+		}
+		if ifd0, err := t.ReadIFD(t.IFD0Offset()); err != nil || ifd0.Next != 0 {
+			return false
+		}
+	}
+	return sc.Err() == nil
+}
+
+// sameFile reports whether the paths name the same existing file.
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && os.SameFile(ia, ib)
+}
@@ -0,0 +1,253 @@
+// Package vectors provides canonical test vectors for exifremovethumbnail.
+//
+// Each Vector holds a small synthetic JPEG, the Policy applied to it and the
+// exact output and result expected from ExifRemoveThumbnailBytes. The data is
+// plain Go so that ports of this package to other languages can load it and
+// validate compatibility byte for byte.
+package vectors
+
+import exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+
+// Vector is a single input with its expected output and result.
+type Vector struct {
+	Name        string
+	Description string
+	// Policy is applied with exifremovethumbnail.WithPolicy.
+	Policy exifremovethumbnail.Policy
+	Input  []byte
+	Output []byte
+	// HadThumbnail, ThumbnailSize, RemovedTags and Warnings are the expected
+	// fields of ExifRemoveThumbnailResult.
+	HadThumbnail  bool
+	ThumbnailSize int64
+	RemovedTags   []exifremovethumbnail.TagRef
+	Warnings      []string
+}
+
+// All returns every vector. The slices are shared; callers must not modify them.
+func All() []Vector {
+	return vectors
+}
+
+var vectors = []Vector{
+	{
+		Name:        "no-exif",
+		Description: "JFIF only; nothing to remove and the file is unchanged.",
+		Policy:      exifremovethumbnail.DefaultPolicy,
+		Input: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34,
+			0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		Output: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34,
+			0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		HadThumbnail:  false,
+		ThumbnailSize: 0,
+	},
+	{
+		Name:        "thumbnail-big-endian",
+		Description: "Big-endian EXIF with a JPEG thumbnail at the end of the TIFF block.",
+		Policy:      exifremovethumbnail.DefaultPolicy,
+		Input: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x62, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x4D, 0x4D,
+			0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x02, 0x01, 0x0F, 0x00, 0x02, 0x00, 0x00, 0x00, 0x06,
+			0x00, 0x00, 0x00, 0x50, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x06, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x26, 0x00, 0x03, 0x01, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x06,
+			0x00, 0x00, 0x02, 0x01, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x56, 0x02, 0x02,
+			0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x4D, 0x61,
+			0x6B, 0x65, 0x72, 0x00, 0xFF, 0xD8, 0xFF, 0xD9, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00,
+			0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		Output: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x34, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x4D, 0x4D,
+			0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x02, 0x01, 0x0F, 0x00, 0x02, 0x00, 0x00, 0x00, 0x06,
+			0x00, 0x00, 0x00, 0x26, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x06, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x4D, 0x61, 0x6B, 0x65, 0x72, 0x00, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01,
+			0x00, 0x00, 0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		HadThumbnail:  true,
+		ThumbnailSize: 46,
+	},
+	{
+		Name:        "thumbnail-little-endian",
+		Description: "Little-endian EXIF with an Exif IFD and a JPEG thumbnail.",
+		Policy:      exifremovethumbnail.DefaultPolicy,
+		Input: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x88, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x49, 0x49,
+			0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, 0x02, 0x00, 0x0F, 0x01, 0x02, 0x00, 0x06, 0x00, 0x00, 0x00,
+			0x62, 0x00, 0x00, 0x00, 0x69, 0x87, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x26, 0x00, 0x00, 0x00,
+			0x38, 0x00, 0x00, 0x00, 0x01, 0x00, 0x03, 0x90, 0x02, 0x00, 0x14, 0x00, 0x00, 0x00, 0x68, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x03, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00,
+			0x06, 0x00, 0x00, 0x00, 0x01, 0x02, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x7C, 0x00, 0x00, 0x00,
+			0x02, 0x02, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x4D, 0x61, 0x6B, 0x65, 0x72, 0x00, 0x32, 0x30, 0x32, 0x34, 0x3A, 0x30, 0x31, 0x3A, 0x30, 0x32,
+			0x20, 0x30, 0x33, 0x3A, 0x30, 0x34, 0x3A, 0x30, 0x35, 0x00, 0xFF, 0xD8, 0xFF, 0xD9, 0xFF, 0xDA,
+			0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		Output: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x5A, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x49, 0x49,
+			0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, 0x02, 0x00, 0x0F, 0x01, 0x02, 0x00, 0x06, 0x00, 0x00, 0x00,
+			0x38, 0x00, 0x00, 0x00, 0x69, 0x87, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x26, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x03, 0x90, 0x02, 0x00, 0x14, 0x00, 0x00, 0x00, 0x3E, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x4D, 0x61, 0x6B, 0x65, 0x72, 0x00, 0x32, 0x30, 0x32, 0x34,
+			0x3A, 0x30, 0x31, 0x3A, 0x30, 0x32, 0x20, 0x30, 0x33, 0x3A, 0x30, 0x34, 0x3A, 0x30, 0x35, 0x00,
+			0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF,
+			0xD9,
+		},
+		HadThumbnail:  true,
+		ThumbnailSize: 46,
+	},
+	{
+		Name:        "thumbnail-before-values",
+		Description: "The thumbnail precedes the value data, which must be moved up with its offsets fixed.",
+		Policy:      exifremovethumbnail.DefaultPolicy,
+		Input: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x6C, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x4D, 0x4D,
+			0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x02, 0x01, 0x0F, 0x00, 0x02, 0x00, 0x00, 0x00, 0x06,
+			0x00, 0x00, 0x00, 0x54, 0x01, 0x31, 0x00, 0x02, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x5A,
+			0x00, 0x00, 0x00, 0x26, 0x00, 0x03, 0x01, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x06,
+			0x00, 0x00, 0x02, 0x01, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x50, 0x02, 0x02,
+			0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xD8,
+			0xFF, 0xD9, 0x4D, 0x61, 0x6B, 0x65, 0x72, 0x00, 0x53, 0x6F, 0x66, 0x74, 0x77, 0x61, 0x72, 0x65,
+			0x00, 0x00, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00,
+			0x56, 0xFF, 0xD9,
+		},
+		Output: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x3D, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x4D, 0x4D,
+			0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x02, 0x01, 0x0F, 0x00, 0x02, 0x00, 0x00, 0x00, 0x06,
+			0x00, 0x00, 0x00, 0x26, 0x01, 0x31, 0x00, 0x02, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x2C,
+			0x00, 0x00, 0x00, 0x00, 0x4D, 0x61, 0x6B, 0x65, 0x72, 0x00, 0x53, 0x6F, 0x66, 0x74, 0x77, 0x61,
+			0x72, 0x65, 0x00, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34, 0xFF,
+			0x00, 0x56, 0xFF, 0xD9,
+		},
+		HadThumbnail:  true,
+		ThumbnailSize: 47,
+	},
+	{
+		Name:        "keep-thumbnail",
+		Description: "The zero Policy keeps everything, including the thumbnail.",
+		Policy:      exifremovethumbnail.Policy{},
+		Input: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x56, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x4D, 0x4D,
+			0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x01, 0x01, 0x0F, 0x00, 0x02, 0x00, 0x00, 0x00, 0x06,
+			0x00, 0x00, 0x00, 0x44, 0x00, 0x00, 0x00, 0x1A, 0x00, 0x03, 0x01, 0x03, 0x00, 0x03, 0x00, 0x00,
+			0x00, 0x01, 0x00, 0x06, 0x00, 0x00, 0x02, 0x01, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+			0x00, 0x4A, 0x02, 0x02, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00,
+			0x00, 0x00, 0x4D, 0x61, 0x6B, 0x65, 0x72, 0x00, 0xFF, 0xD8, 0xFF, 0xD9, 0xFF, 0xDA, 0x00, 0x08,
+			0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		Output: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x56, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x4D, 0x4D,
+			0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x01, 0x01, 0x0F, 0x00, 0x02, 0x00, 0x00, 0x00, 0x06,
+			0x00, 0x00, 0x00, 0x44, 0x00, 0x00, 0x00, 0x1A, 0x00, 0x03, 0x01, 0x03, 0x00, 0x03, 0x00, 0x00,
+			0x00, 0x01, 0x00, 0x06, 0x00, 0x00, 0x02, 0x01, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+			0x00, 0x4A, 0x02, 0x02, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00,
+			0x00, 0x00, 0x4D, 0x61, 0x6B, 0x65, 0x72, 0x00, 0xFF, 0xD8, 0xFF, 0xD9, 0xFF, 0xDA, 0x00, 0x08,
+			0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		HadThumbnail:  true,
+		ThumbnailSize: 0,
+	},
+	{
+		Name:        "remove-gps",
+		Description: "GPS IFD removed together with the thumbnail.",
+		Policy:      exifremovethumbnail.Policy{RemoveThumbnail: true, RemoveGPS: true},
+		Input: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x80, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x4D, 0x4D,
+			0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x02, 0x01, 0x0F, 0x00, 0x02, 0x00, 0x00, 0x00, 0x06,
+			0x00, 0x00, 0x00, 0x62, 0x88, 0x25, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x26,
+			0x00, 0x00, 0x00, 0x38, 0x00, 0x01, 0x00, 0x1B, 0x00, 0x02, 0x00, 0x00, 0x00, 0x0B, 0x00, 0x00,
+			0x00, 0x68, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0x01, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x06, 0x00, 0x00, 0x02, 0x01, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x74,
+			0x02, 0x02, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00,
+			0x4D, 0x61, 0x6B, 0x65, 0x72, 0x00, 0x47, 0x50, 0x53, 0x2D, 0x4D, 0x45, 0x54, 0x48, 0x4F, 0x44,
+			0x00, 0x00, 0xFF, 0xD8, 0xFF, 0xD9, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00,
+			0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		Output: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x46, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x4D, 0x4D,
+			0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x01, 0x01, 0x0F, 0x00, 0x02, 0x00, 0x00, 0x00, 0x06,
+			0x00, 0x00, 0x00, 0x38, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x4D, 0x61, 0x6B, 0x65, 0x72, 0x00, 0xFF, 0xDA, 0x00, 0x08,
+			0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		HadThumbnail:  true,
+		ThumbnailSize: 58,
+		RemovedTags:   []exifremovethumbnail.TagRef{{IFD: exifremovethumbnail.IFD0, ID: 0x8825}},
+	},
+	{
+		Name:        "strip-all-keep-orientation",
+		Description: "EXIF replaced by a minimal segment holding only Orientation.",
+		Policy:      exifremovethumbnail.Policy{RemoveThumbnail: true, StripAllExif: true, KeepOrientation: true},
+		Input: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x62, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x4D, 0x4D,
+			0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x02, 0x01, 0x0F, 0x00, 0x02, 0x00, 0x00, 0x00, 0x06,
+			0x00, 0x00, 0x00, 0x50, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x08, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x26, 0x00, 0x03, 0x01, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x06,
+			0x00, 0x00, 0x02, 0x01, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x56, 0x02, 0x02,
+			0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x4D, 0x61,
+			0x6B, 0x65, 0x72, 0x00, 0xFF, 0xD8, 0xFF, 0xD9, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00,
+			0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		Output: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xE1, 0x00, 0x22, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00, 0x4D, 0x4D,
+			0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x01, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00,
+			0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		HadThumbnail:  true,
+		ThumbnailSize: 46,
+	},
+	{
+		Name:        "remove-comments",
+		Description: "COM segments removed.",
+		Policy:      exifremovethumbnail.Policy{RemoveThumbnail: true, RemoveComments: true},
+		Input: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xFE, 0x00, 0x0B, 0x61, 0x20, 0x63, 0x6F, 0x6D, 0x6D, 0x65, 0x6E,
+			0x74, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34, 0xFF, 0x00, 0x56,
+			0xFF, 0xD9,
+		},
+		Output: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34,
+			0xFF, 0x00, 0x56, 0xFF, 0xD9,
+		},
+		HadThumbnail:  false,
+		ThumbnailSize: 0,
+	},
+	{
+		Name:        "trailing-data",
+		Description: "Data after EOI is copied and reported as a warning.",
+		Policy:      exifremovethumbnail.DefaultPolicy,
+		Input: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34,
+			0xFF, 0x00, 0x56, 0xFF, 0xD9, 0x54, 0x52, 0x41, 0x49, 0x4C, 0x45, 0x52,
+		},
+		Output: []byte{
+			0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01,
+			0x00, 0x01, 0x00, 0x00, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00, 0x12, 0x34,
+			0xFF, 0x00, 0x56, 0xFF, 0xD9, 0x54, 0x52, 0x41, 0x49, 0x4C, 0x45, 0x52,
+		},
+		HadThumbnail:  false,
+		ThumbnailSize: 0,
+		Warnings:      []string{"7 bytes of trailing data after EOI"},
+	},
+}
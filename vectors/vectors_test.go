@@ -0,0 +1,35 @@
+package vectors_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+	"github.com/ideamans/go-exif-remove-thumbnail/vectors"
+)
+
+func TestVectors(t *testing.T) {
+	require.NotEmpty(t, vectors.All())
+	for _, v := range vectors.All() {
+		t.Run(v.Name, func(t *testing.T) {
+			out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(v.Input, exifremovethumbnail.WithPolicy(v.Policy))
+			require.NoError(t, err)
+			require.Equal(t, v.Output, out)
+			require.Equal(t, v.HadThumbnail, res.HadThumbnail)
+			require.Equal(t, v.ThumbnailSize, res.ThumbnailSize)
+			require.Equal(t, v.RemovedTags, res.RemovedTags)
+			require.Equal(t, v.Warnings, res.Warnings)
+			require.Equal(t, int64(len(v.Input)), res.BeforeSize)
+			require.Equal(t, int64(len(v.Output)), res.AfterSize)
+		})
+	}
+
+	t.Run("名前は一意", func(t *testing.T) {
+		seen := map[string]bool{}
+		for _, v := range vectors.All() {
+			require.False(t, seen[v.Name], v.Name)
+			seen[v.Name] = true
+		}
+	})
+}
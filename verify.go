@@ -0,0 +1,228 @@
+package exifremovethumbnail
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"sync"
+	"time"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/jpegseg"
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+// VerifyError reports that a rewrite changed the decoded pixels or that an
+// image could not be decoded for verification.
+type VerifyError struct {
+	// Decoder names the decoder that detected the difference, e.g. "image/jpeg" or "libjpeg".
+	Decoder string
+	msg     string
+}
+
+func (e *VerifyError) Error() string {
+	return e.Decoder + ": " + e.msg
+}
+
+// ErrImageDataChanged is returned by WithVerifyImageDataUnchanged when the
+// output's SOS..EOI region differs from the input's.
+var ErrImageDataChanged = errors.New("image data changed")
+
+// ErrInvalidOutput is returned by WithValidateOutput when the output cannot be
+// read back.
+var ErrInvalidOutput = errors.New("output is not readable")
+
+// validateOutput decodes the JPEG header of data and parses every EXIF block in
+// it, including extended EXIF continued over several segments.
+func validateOutput(data []byte) error {
+	if _, err := jpeg.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+	}
+	segments, _, err := jpegseg.Split(data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+	}
+	var blocks [][]byte
+	for _, s := range segments {
+		if s.Marker != spec.APP1 || !isExifSegment(s.Payload()) {
+			continue
+		}
+		body := s.Payload()[len(exifHeader):]
+		if isTIFFHeader(body) || len(blocks) == 0 {
+			blocks = append(blocks, append([]byte{}, body...))
+		} else {
+			blocks[len(blocks)-1] = append(blocks[len(blocks)-1], body...)
+		}
+	}
+	for _, b := range blocks {
+		t, err := parseTIFF(b)
+		if err == nil {
+			_, err = t.ifds()
+		}
+		if err != nil {
+			return fmt.Errorf("%w: EXIF: %v", ErrInvalidOutput, err)
+		}
+	}
+	return nil
+}
+
+// ScanHash returns the hex SHA-256 digest of the SOS..EOI region of JPEG data,
+// or "" if there is no SOS marker. It is InputScanHash and OutputScanHash of
+// ExifRemoveThumbnailResult; equal digests prove the compressed image data is
+// identical without decoding it.
+func ScanHash(data []byte) string {
+	_, sos, err := jpegseg.Split(data)
+	if err != nil || !bytes.HasPrefix(data[sos:], []byte{0xFF, 0xDA}) {
+		return ""
+	}
+	eoi := &eoiTracker{end: -1, h: sha256.New()}
+	eoi.Write(data[sos:])
+	return hex.EncodeToString(eoi.h.Sum(nil))
+}
+
+// pixelDecoder decodes JPEG data into a comparable form.
+type pixelDecoder struct {
+	name   string
+	decode func(data []byte) (pixels, error)
+}
+
+// pixels is decoded image data. Two images are equal when every field is equal.
+type pixels struct {
+	width, height int
+	layout        string
+	planes        [][]byte
+}
+
+var (
+	pixelDecodersMu sync.Mutex
+	// pixelDecoders lists the decoders VerifyPixels runs. libjpeg is added when
+	// built with cgo and the libjpeg build tag.
+	pixelDecoders = []pixelDecoder{{name: "image/jpeg", decode: decodeGoJPEG}}
+)
+
+// VerifyPixels decodes before and after with every available decoder and
+// reports a *VerifyError unless they yield identical pixels.
+// Go's image/jpeg is always used; libjpeg(-turbo) is used as well when the
+// package is built with cgo and the libjpeg build tag.
+func VerifyPixels(before, after []byte) error {
+	pixelDecodersMu.Lock()
+	decoders := append([]pixelDecoder{}, pixelDecoders...)
+	pixelDecodersMu.Unlock()
+	for _, d := range decoders {
+		want, err := d.decode(before)
+		if err != nil {
+			return &VerifyError{Decoder: d.name, msg: "failed to decode input: " + err.Error()}
+		}
+		got, err := d.decode(after)
+		if err != nil {
+			return &VerifyError{Decoder: d.name, msg: "failed to decode output: " + err.Error()}
+		}
+		if !want.equal(got) {
+			return &VerifyError{Decoder: d.name, msg: "decoded pixels differ"}
+		}
+	}
+	return nil
+}
+
+// PixelHash decodes JPEG data with image/jpeg and returns the hex SHA-256
+// digest of the decoded pixels, dimensions and layout. Equal digests prove the
+// visible image is identical even when the compressed data differs. Unlike
+// VerifyPixels it needs only one of the images at a time, so a pipeline can
+// record the digest of its inputs and check its outputs against it later.
+func PixelHash(data []byte) (string, error) {
+	p, err := decodeGoJPEG(data)
+	if err != nil {
+		return "", &VerifyError{Decoder: "image/jpeg", msg: "failed to decode: " + err.Error()}
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%dx%d %s\n", p.width, p.height, p.layout)
+	for _, plane := range p.planes {
+		h.Write(plane)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyDecoders returns the names of the decoders used by VerifyPixels.
+func VerifyDecoders() []string {
+	pixelDecodersMu.Lock()
+	defer pixelDecodersMu.Unlock()
+	names := make([]string, len(pixelDecoders))
+	for i, d := range pixelDecoders {
+		names[i] = d.name
+	}
+	return names
+}
+
+// registerPixelDecoder adds a decoder to VerifyPixels.
+func registerPixelDecoder(name string, decode func([]byte) (pixels, error)) {
+	pixelDecodersMu.Lock()
+	defer pixelDecodersMu.Unlock()
+	pixelDecoders = append(pixelDecoders, pixelDecoder{name: name, decode: decode})
+}
+
+func (p pixels) equal(q pixels) bool {
+	if p.width != q.width || p.height != q.height || p.layout != q.layout || len(p.planes) != len(q.planes) {
+		return false
+	}
+	for i := range p.planes {
+		if !bytes.Equal(p.planes[i], q.planes[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeGoJPEG decodes data with image/jpeg.
+func decodeGoJPEG(data []byte) (pixels, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return pixels{}, err
+	}
+	b := img.Bounds()
+	p := pixels{width: b.Dx(), height: b.Dy()}
+	switch m := img.(type) {
+	case *image.YCbCr:
+		p.layout = "ycbcr-" + m.SubsampleRatio.String()
+		p.planes = [][]byte{m.Y, m.Cb, m.Cr}
+	case *image.Gray:
+		p.layout = "gray"
+		p.planes = [][]byte{m.Pix}
+	case *image.CMYK:
+		p.layout = "cmyk"
+		p.planes = [][]byte{m.Pix}
+	default:
+		rgba := make([]byte, 0, b.Dx()*b.Dy()*8)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, a := img.At(x, y).RGBA()
+				rgba = append(rgba, byte(r>>8), byte(r), byte(g>>8), byte(g), byte(bl>>8), byte(bl), byte(a>>8), byte(a))
+			}
+		}
+		p.layout = "rgba64"
+		p.planes = [][]byte{rgba}
+	}
+	return p, nil
+}
+
+// skipVerify returns why pixel verification should be skipped under the soft
+// limits, or "" to run it. elapsed is the time spent so far.
+func (o *options) skipVerify(data []byte, elapsed time.Duration) string {
+	if o.softTime > 0 && elapsed >= o.softTime {
+		return fmt.Sprintf("soft time limit of %v reached", o.softTime)
+	}
+	if o.softMemory > 0 {
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return ""
+		}
+		// Input and output are decoded at the same time, with up to 4 bytes per pixel each.
+		need := int64(cfg.Width) * int64(cfg.Height) * 4 * 2
+		if need > o.softMemory {
+			return fmt.Sprintf("decoding needs about %d bytes, over the soft memory limit of %d", need, o.softMemory)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+//go:build cgo && libjpeg
+
+package exifremovethumbnail
+
+/*
+#cgo LDFLAGS: -ljpeg
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <setjmp.h>
+#include <jpeglib.h>
+
+struct verify_error_mgr {
+	struct jpeg_error_mgr pub;
+	jmp_buf jmp;
+	char msg[JMSG_LENGTH_MAX];
+};
+
+static void verify_error_exit(j_common_ptr cinfo) {
+	struct verify_error_mgr *err = (struct verify_error_mgr *)cinfo->err;
+	(*cinfo->err->format_message)(cinfo, err->msg);
+	longjmp(err->jmp, 1);
+}
+
+// verify_decode decodes a JPEG image into a newly allocated buffer of
+// width*height*components bytes. It returns NULL and fills msg on error.
+static unsigned char *verify_decode(unsigned char *data, unsigned long size,
+		int *width, int *height, int *components, char *msg) {
+	struct jpeg_decompress_struct cinfo;
+	struct verify_error_mgr err;
+	unsigned char *volatile buf = NULL;
+
+	cinfo.err = jpeg_std_error(&err.pub);
+	err.pub.error_exit = verify_error_exit;
+	if (setjmp(err.jmp)) {
+		strncpy(msg, err.msg, JMSG_LENGTH_MAX);
+		jpeg_destroy_decompress(&cinfo);
+		free(buf);
+		return NULL;
+	}
+	jpeg_create_decompress(&cinfo);
+	jpeg_mem_src(&cinfo, data, size);
+	jpeg_read_header(&cinfo, TRUE);
+	jpeg_start_decompress(&cinfo);
+
+	size_t stride = (size_t)cinfo.output_width * cinfo.output_components;
+	buf = malloc(stride * cinfo.output_height);
+	if (buf == NULL) {
+		strncpy(msg, "out of memory", JMSG_LENGTH_MAX);
+		jpeg_destroy_decompress(&cinfo);
+		return NULL;
+	}
+	while (cinfo.output_scanline < cinfo.output_height) {
+		JSAMPROW row = buf + stride * cinfo.output_scanline;
+		jpeg_read_scanlines(&cinfo, &row, 1);
+	}
+	*width = cinfo.output_width;
+	*height = cinfo.output_height;
+	*components = cinfo.output_components;
+	jpeg_finish_decompress(&cinfo);
+	jpeg_destroy_decompress(&cinfo);
+	return buf;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+func init() {
+	registerPixelDecoder("libjpeg", decodeLibJPEG)
+}
+
+// decodeLibJPEG decodes data with the system libjpeg(-turbo).
+func decodeLibJPEG(data []byte) (pixels, error) {
+	if len(data) == 0 {
+		return pixels{}, errors.New("empty input")
+	}
+	cdata := C.CBytes(data)
+	defer C.free(cdata)
+	var width, height, components C.int
+	msg := (*C.char)(C.calloc(C.JMSG_LENGTH_MAX, 1))
+	defer C.free(unsafe.Pointer(msg))
+	buf := C.verify_decode((*C.uchar)(cdata), C.ulong(len(data)), &width, &height, &components, msg)
+	if buf == nil {
+		return pixels{}, errors.New(C.GoString(msg))
+	}
+	defer C.free(unsafe.Pointer(buf))
+	n := int(width) * int(height) * int(components)
+	return pixels{
+		width:  int(width),
+		height: int(height),
+		layout: fmt.Sprintf("components-%d", components),
+		planes: [][]byte{C.GoBytes(unsafe.Pointer(buf), C.int(n))},
+	}, nil
+}
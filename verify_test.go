@@ -0,0 +1,214 @@
+package exifremovethumbnail_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestVerifyPixels(t *testing.T) {
+	t.Run("image/jpegは常に使われる", func(t *testing.T) {
+		require.Contains(t, exifremovethumbnail.VerifyDecoders(), "image/jpeg")
+	})
+
+	t.Run("テスト画像すべてで画素が変わらない", func(t *testing.T) {
+		files, err := filepath.Glob(filepath.Join("testdata", "*.jpg"))
+		require.NoError(t, err)
+		for _, file := range files {
+			if filepath.Base(file) == "actual_png.jpg" {
+				continue
+			}
+			inData, err := os.ReadFile(file)
+			require.NoError(t, err)
+			for _, opts := range [][]exifremovethumbnail.Option{
+				nil,
+				{exifremovethumbnail.WithStripAllExif()},
+				{exifremovethumbnail.WithRemoveGPS(), exifremovethumbnail.WithStripComments()},
+			} {
+				opts = append(opts, exifremovethumbnail.WithVerifyPixels())
+				_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, opts...)
+				require.NoError(t, err, file)
+			}
+		}
+	})
+
+	t.Run("画素が変わればVerifyError", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		outData, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+		require.NoError(t, err)
+		// 画像データの中ほどを1バイト書き換える
+		broken := append([]byte{}, outData...)
+		broken[len(broken)-1000] ^= 0x55
+
+		err = exifremovethumbnail.VerifyPixels(inData, broken)
+		require.Error(t, err)
+		_, ok := err.(*exifremovethumbnail.VerifyError)
+		require.True(t, ok, "VerifyErrorであるべき")
+	})
+
+	t.Run("デコードできない入力はVerifyError", func(t *testing.T) {
+		pngData, err := os.ReadFile(filepath.Join("testdata", "actual_png.jpg"))
+		require.NoError(t, err)
+		err = exifremovethumbnail.VerifyPixels(pngData, pngData)
+		_, ok := err.(*exifremovethumbnail.VerifyError)
+		require.True(t, ok, "VerifyErrorであるべき")
+	})
+}
+
+func TestSoftLimits(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	// skipped は検証スキップの警告があるかを返す
+	skipped := func(result exifremovethumbnail.ExifRemoveThumbnailResult) bool {
+		for _, w := range result.Warnings {
+			if strings.HasPrefix(w, "pixel verification skipped") {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("メモリ上限を超えると検証を省略して警告", func(t *testing.T) {
+		outData, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithVerifyPixels(),
+			exifremovethumbnail.WithSoftLimits(1024, 0))
+		require.NoError(t, err)
+		require.NotEmpty(t, outData)
+		require.True(t, skipped(result))
+	})
+
+	t.Run("時間上限に達していれば検証を省略して警告", func(t *testing.T) {
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithVerifyPixels(),
+			exifremovethumbnail.WithSoftLimits(0, time.Nanosecond))
+		require.NoError(t, err)
+		require.True(t, skipped(result))
+	})
+
+	t.Run("上限内なら検証する", func(t *testing.T) {
+		_, result, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData,
+			exifremovethumbnail.WithVerifyPixels(),
+			exifremovethumbnail.WithSoftLimits(1<<30, time.Hour))
+		require.NoError(t, err)
+		require.False(t, skipped(result))
+	})
+}
+
+func TestVerifyImageDataUnchanged(t *testing.T) {
+	verify := exifremovethumbnail.WithVerifyImageDataUnchanged()
+
+	t.Run("テスト画像すべてで画像データが変わらない", func(t *testing.T) {
+		for _, name := range []string{"metadata_basic_exif.jpg", "metadata_full_exif.jpg", "metadata_gps.jpg", "metadata_none.jpg", "thumbnail_embedded.jpg", "thumbnail_none.jpg"} {
+			inData, err := os.ReadFile(filepath.Join("testdata", name))
+			require.NoError(t, err)
+			for _, opts := range [][]exifremovethumbnail.Option{
+				{verify},
+				{verify, exifremovethumbnail.WithStripAllExif()},
+				{verify, exifremovethumbnail.WithConformance()},
+			} {
+				_, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, opts...)
+				require.NoError(t, err, name)
+				require.NotEmpty(t, res.InputScanHash)
+				require.Equal(t, res.InputScanHash, res.OutputScanHash)
+			}
+		}
+	})
+
+	t.Run("EOIを補うと検出される", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		_, _, err = exifremovethumbnail.ExifRemoveThumbnailBytes(inData[:len(inData)-2], verify, exifremovethumbnail.WithConformance())
+		require.ErrorIs(t, err, exifremovethumbnail.ErrImageDataChanged)
+	})
+}
+
+func TestValidateOutput(t *testing.T) {
+	validate := exifremovethumbnail.WithValidateOutput()
+
+	t.Run("テスト画像すべての出力を読み戻せる", func(t *testing.T) {
+		for _, name := range []string{"metadata_basic_exif.jpg", "metadata_full_exif.jpg", "metadata_gps.jpg", "metadata_none.jpg", "thumbnail_embedded.jpg", "thumbnail_none.jpg"} {
+			inData, err := os.ReadFile(filepath.Join("testdata", name))
+			require.NoError(t, err)
+			for _, opts := range [][]exifremovethumbnail.Option{
+				{validate},
+				{validate, exifremovethumbnail.WithStripAllExif(), exifremovethumbnail.WithKeepOrientation()},
+				{validate, exifremovethumbnail.WithCompactExif()},
+			} {
+				_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, opts...)
+				require.NoError(t, err, name)
+			}
+		}
+	})
+
+	t.Run("画像データのない出力はエラー", func(t *testing.T) {
+		inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		// EXIFの直後で切れていてもWithAllowTruncatedなら出力はできる
+		damaged := inData[:8607]
+		_, _, err = exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, exifremovethumbnail.WithAllowTruncated())
+		require.NoError(t, err)
+		_, _, err = exifremovethumbnail.ExifRemoveThumbnailBytes(damaged, exifremovethumbnail.WithAllowTruncated(), validate)
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInvalidOutput)
+	})
+
+	t.Run("読めないEXIFを含む出力はエラーで書き込まない", func(t *testing.T) {
+		inData := jpegWithExif(t, append([]byte("Exif\x00\x00"), "MM\x00*\x00\x00\xFF\xFF"...))
+		lenient := exifremovethumbnail.WithParseMode(exifremovethumbnail.ParseLenient)
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, lenient)
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		inPath, outPath := filepath.Join(dir, "in.jpg"), filepath.Join(dir, "out.jpg")
+		require.NoError(t, os.WriteFile(inPath, inData, 0644))
+		_, err = exifremovethumbnail.ExifRemoveThumbnail(inPath, outPath, lenient, validate)
+		require.ErrorIs(t, err, exifremovethumbnail.ErrInvalidOutput)
+		_, err = os.Stat(outPath)
+		require.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestImageHashes(t *testing.T) {
+	t.Run("出力のハッシュは入力と一致する", func(t *testing.T) {
+		for _, name := range []string{"metadata_basic_exif.jpg", "metadata_full_exif.jpg", "metadata_gps.jpg", "metadata_none.jpg", "thumbnail_embedded.jpg", "thumbnail_none.jpg"} {
+			inData, err := os.ReadFile(filepath.Join("testdata", name))
+			require.NoError(t, err)
+			outData, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithStripAllExif())
+			require.NoError(t, err)
+
+			require.Equal(t, res.InputScanHash, exifremovethumbnail.ScanHash(inData), name)
+			require.Equal(t, res.InputScanHash, exifremovethumbnail.ScanHash(outData), name)
+			want, err := exifremovethumbnail.PixelHash(inData)
+			require.NoError(t, err)
+			got, err := exifremovethumbnail.PixelHash(outData)
+			require.NoError(t, err)
+			require.Equal(t, want, got, name)
+		}
+	})
+
+	t.Run("別の画像はハッシュが異なる", func(t *testing.T) {
+		a, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+		require.NoError(t, err)
+		b, err := os.ReadFile(filepath.Join("testdata", "metadata_none.jpg"))
+		require.NoError(t, err)
+		require.NotEqual(t, exifremovethumbnail.ScanHash(a), exifremovethumbnail.ScanHash(b))
+		hashA, err := exifremovethumbnail.PixelHash(a)
+		require.NoError(t, err)
+		hashB, err := exifremovethumbnail.PixelHash(b)
+		require.NoError(t, err)
+		require.NotEqual(t, hashA, hashB)
+	})
+
+	t.Run("画像データがなければ空またはエラー", func(t *testing.T) {
+		require.Empty(t, exifremovethumbnail.ScanHash([]byte{0xFF, 0xD8, 0xFF, 0xD9}))
+		_, err := exifremovethumbnail.PixelHash([]byte("not a jpeg"))
+		var verifyErr *exifremovethumbnail.VerifyError
+		require.ErrorAs(t, err, &verifyErr)
+	})
+}
@@ -0,0 +1,81 @@
+package exifremovethumbnail
+
+import (
+	"fmt"
+
+	"github.com/ideamans/go-exif-remove-thumbnail/spec"
+)
+
+// SegmentAction tells what happens to a segment passed to a SegmentVisitor.
+type SegmentAction int
+
+const (
+	// SegmentKeep passes the segment on to the regular processing.
+	SegmentKeep SegmentAction = iota
+	// SegmentDrop removes the segment. It is reported in RemovedSegments.
+	SegmentDrop
+	// SegmentReplace passes the returned payload on to the regular
+	// processing in place of the segment's own.
+	SegmentReplace
+)
+
+// VisitedSegment is a marker segment in front of the image data.
+type VisitedSegment struct {
+	Marker uint16
+	// Name is the segment name as used by WithKeepSegments, or the marker
+	// name such as "DQT" or "COM" for other segments.
+	Name Segment
+	// Offset is the position of the marker in the input.
+	Offset int64
+	// Payload is the segment data after the length field. Extended EXIF
+	// split over several APP1 segments is passed as one payload. It is only
+	// valid during the call and must not be modified.
+	Payload []byte
+}
+
+// SegmentVisitor inspects the marker segments of a JPEG file one by one and
+// decides whether each is kept, dropped or replaced, for custom metadata
+// handling on top of the parser. Visitors see a segment before the options
+// do: a kept or replaced segment is still subject to them, so a replaced
+// EXIF segment still loses its thumbnail.
+type SegmentVisitor interface {
+	VisitSegment(s VisitedSegment) (action SegmentAction, replacement []byte)
+}
+
+// SegmentVisitorFunc adapts a function to the SegmentVisitor interface.
+type SegmentVisitorFunc func(s VisitedSegment) (SegmentAction, []byte)
+
+// VisitSegment calls f(s).
+func (f SegmentVisitorFunc) VisitSegment(s VisitedSegment) (SegmentAction, []byte) {
+	return f(s)
+}
+
+// WithSegmentVisitor calls v for every marker segment in front of the image
+// data, in file order. Several visitors run in the order given, each seeing
+// the payload left by the one before. Visitors are not called for the
+// segments of files left unchanged by WithSkipRiskyMakerNote. A visitor shared
+// through options used concurrently must be safe for concurrent use.
+func WithSegmentVisitor(v SegmentVisitor) Option {
+	return func(o *options) {
+		o.visitors = append(o.visitors, v)
+	}
+}
+
+// visitSegment runs the visitors over a segment. It returns the payload to
+// go on with, or false when the segment is dropped.
+func (o *options) visitSegment(marker uint16, offset int64, segmentData []byte) ([]byte, bool, error) {
+	for _, v := range o.visitors {
+		action, replacement := v.VisitSegment(VisitedSegment{Marker: marker, Name: markerName(marker, segmentData), Offset: offset, Payload: segmentData})
+		switch action {
+		case SegmentDrop:
+			return nil, false, nil
+		case SegmentReplace:
+			if len(replacement) > maxSegmentPayload && !(marker == spec.APP1 && isExifSegment(replacement)) {
+				return nil, false, fmt.Errorf("segment 0x%04X at offset %d replaced with %d bytes, more than a segment holds", marker, offset, len(replacement))
+			}
+			// The regular processing may modify the payload in place.
+			segmentData = append([]byte{}, replacement...)
+		}
+	}
+	return segmentData, true, nil
+}
@@ -0,0 +1,110 @@
+package exifremovethumbnail_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	exifremovethumbnail "github.com/ideamans/go-exif-remove-thumbnail"
+)
+
+func TestSegmentVisitor(t *testing.T) {
+	inData, err := os.ReadFile(filepath.Join("testdata", "thumbnail_embedded.jpg"))
+	require.NoError(t, err)
+	want, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData)
+	require.NoError(t, err)
+
+	t.Run("全セグメントを順に一度ずつ訪問する", func(t *testing.T) {
+		report, err := exifremovethumbnail.Analyze(inData)
+		require.NoError(t, err)
+		var visited []exifremovethumbnail.SegmentSize
+		visitor := exifremovethumbnail.SegmentVisitorFunc(func(s exifremovethumbnail.VisitedSegment) (exifremovethumbnail.SegmentAction, []byte) {
+			visited = append(visited, exifremovethumbnail.SegmentSize{Name: s.Name, Offset: s.Offset, Size: int64(len(s.Payload) + 4)})
+			return exifremovethumbnail.SegmentKeep, nil
+		})
+		out, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithSegmentVisitor(visitor))
+		require.NoError(t, err)
+		require.Equal(t, want, out)
+		// SOIは長さを持たないので訪問されない
+		require.Equal(t, report.Segments[1:], visited)
+
+		visited = nil
+		path := filepath.Join(t.TempDir(), "out.jpg")
+		_, err = exifremovethumbnail.ExifRemoveThumbnail(filepath.Join("testdata", "thumbnail_embedded.jpg"), path, exifremovethumbnail.WithSegmentVisitor(visitor))
+		require.NoError(t, err)
+		require.Equal(t, report.Segments[1:], visited)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	})
+
+	t.Run("削除", func(t *testing.T) {
+		visitor := exifremovethumbnail.SegmentVisitorFunc(func(s exifremovethumbnail.VisitedSegment) (exifremovethumbnail.SegmentAction, []byte) {
+			if s.Name == exifremovethumbnail.SegmentJFIF {
+				return exifremovethumbnail.SegmentDrop, nil
+			}
+			return exifremovethumbnail.SegmentKeep, nil
+		})
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithSegmentVisitor(visitor))
+		require.NoError(t, err)
+		expected, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithDropSegments(exifremovethumbnail.SegmentJFIF))
+		require.NoError(t, err)
+		require.Equal(t, expected, out)
+		require.Equal(t, int64(18), res.RemovedSegments[exifremovethumbnail.SegmentJFIF])
+	})
+
+	t.Run("置き換えた後もオプションが適用される", func(t *testing.T) {
+		var jfif []byte
+		visitor := exifremovethumbnail.SegmentVisitorFunc(func(s exifremovethumbnail.VisitedSegment) (exifremovethumbnail.SegmentAction, []byte) {
+			switch s.Name {
+			case exifremovethumbnail.SegmentJFIF:
+				jfif = append([]byte{}, s.Payload...)
+				jfif[len(jfif)-1] = 0x01 // サムネイルの高さとして書き換え
+				return exifremovethumbnail.SegmentReplace, jfif
+			case exifremovethumbnail.SegmentExif:
+				return exifremovethumbnail.SegmentReplace, append([]byte{}, s.Payload...)
+			}
+			return exifremovethumbnail.SegmentKeep, nil
+		})
+		out, res, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithSegmentVisitor(visitor))
+		require.NoError(t, err)
+		require.True(t, res.HadThumbnail)
+		require.Len(t, out, len(want))
+		require.True(t, bytes.Contains(out, jfif))
+		require.Equal(t, want[20:], out[20:])
+		require.Equal(t, exifremovethumbnail.ScanHash(inData), exifremovethumbnail.ScanHash(out))
+	})
+
+	t.Run("複数の訪問者は順に適用される", func(t *testing.T) {
+		var seen []byte
+		replace := exifremovethumbnail.SegmentVisitorFunc(func(s exifremovethumbnail.VisitedSegment) (exifremovethumbnail.SegmentAction, []byte) {
+			if s.Name == exifremovethumbnail.SegmentJFIF {
+				return exifremovethumbnail.SegmentReplace, []byte("JFIF\x00replaced")
+			}
+			return exifremovethumbnail.SegmentKeep, nil
+		})
+		inspect := exifremovethumbnail.SegmentVisitorFunc(func(s exifremovethumbnail.VisitedSegment) (exifremovethumbnail.SegmentAction, []byte) {
+			if s.Marker == 0xFFE0 {
+				seen = append([]byte{}, s.Payload...)
+			}
+			return exifremovethumbnail.SegmentKeep, nil
+		})
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithSegmentVisitor(replace), exifremovethumbnail.WithSegmentVisitor(inspect))
+		require.NoError(t, err)
+		require.Equal(t, []byte("JFIF\x00replaced"), seen)
+	})
+
+	t.Run("大きすぎる置き換えはエラー", func(t *testing.T) {
+		visitor := exifremovethumbnail.SegmentVisitorFunc(func(s exifremovethumbnail.VisitedSegment) (exifremovethumbnail.SegmentAction, []byte) {
+			if s.Name == exifremovethumbnail.SegmentJFIF {
+				return exifremovethumbnail.SegmentReplace, make([]byte, 0x10000)
+			}
+			return exifremovethumbnail.SegmentKeep, nil
+		})
+		_, _, err := exifremovethumbnail.ExifRemoveThumbnailBytes(inData, exifremovethumbnail.WithSegmentVisitor(visitor))
+		require.Error(t, err)
+	})
+}